@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -41,19 +42,191 @@ type ZaProxy struct {
 }
 
 type Analyzer struct {
-	Image   string          `json:"image"`
-	Name    string          `json:"name"`
-	Target  string          `json:"target,omitempty"`
-	Service *corev1.Service `json:"service,omitempty"`
+	Image          string          `json:"image"`
+	Name           string          `json:"name"`
+	Target         string          `json:"target,omitempty"`
+	Service        *corev1.Service `json:"service,omitempty"`
+	Authentication *Authentication `json:"authentication,omitempty"`
+	Scope          *Scope          `json:"scope,omitempty"`
+	// AjaxSpider runs the AJAX spider after the traditional spider
+	AjaxSpider bool `json:"ajaxSpider,omitempty"`
+	// ScanPolicy is the name of a DastScanPolicy used by the active scan
+	ScanPolicy string `json:"scanPolicy,omitempty"`
+	// Scripts are loaded and enabled in ZAP before the scan
+	Scripts []Script `json:"scripts,omitempty"`
+	// MaxDuration of the whole scan, results are partial if it is exceeded
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+	Timeouts    *Timeouts        `json:"timeouts,omitempty"`
+	// FailOn fails the analyzer job if the number of alerts exceeds the thresholds
+	FailOn *Thresholds `json:"failOn,omitempty"`
+	// HostAliases resolve the hosts of the target in a ZAP sidecar of the analyzer job instead of the shared ZAP,
+	// e.g. to scan an ingress host with its own Host header and SNI through the ingress controller
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+	// Ingress is the scanned host of an ingress, the results are associated with the ingress and its backend services
+	Ingress *IngressTarget `json:"ingress,omitempty"`
+	// TargetSelector selects the scanned services instead of Target, an analyzer job is run for every selected service and port
+	TargetSelector *TargetSelector `json:"targetSelector,omitempty"`
 }
 
+// TargetSelector selects the services scanned by a Dast with shared settings
+type TargetSelector struct {
+	// NamespaceSelector selects the namespaces of the services, the namespace of the Dast is used if it isn't set.
+	// Only the namespace of the Dast is selected, unless the operator allows the Dast to scan other namespaces.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// ServiceSelector selects the services in the namespaces, every service is selected if it isn't set
+	ServiceSelector *metav1.LabelSelector `json:"serviceSelector,omitempty"`
+	// MaxConcurrent limits the number of analyzer jobs running at once, not limited if 0
+	// +kubebuilder:validation:Minimum=0
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+}
+
+// IngressTarget is a host of an ingress scanned through the ingress controller
+type IngressTarget struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	UID       types.UID `json:"uid,omitempty"`
+	Host      string    `json:"host"`
+	// Backends are the names of the backend services of the host
+	Backends []string `json:"backends,omitempty"`
+}
+
+// Thresholds defines the maximum number of alerts per risk level, unset levels aren't checked
+type Thresholds struct {
+	High          *int `json:"high,omitempty"`
+	Medium        *int `json:"medium,omitempty"`
+	Low           *int `json:"low,omitempty"`
+	Informational *int `json:"informational,omitempty"`
+}
+
+// Timeouts limits the duration of the scan stages, a stage is stopped when its timeout is exceeded
+type Timeouts struct {
+	Spider     *metav1.Duration `json:"spider,omitempty"`
+	AjaxSpider *metav1.Duration `json:"ajaxSpider,omitempty"`
+	ActiveScan *metav1.Duration `json:"activeScan,omitempty"`
+}
+
+// ScriptType is the type of a ZAP script
+// +kubebuilder:validation:Enum=httpsender;active;passive;proxy;authentication
+type ScriptType string
+
+// Script references a ZAP script stored in a ConfigMap.
+// The ConfigMap has to be in the namespace of ZAP, it is mounted into the ZAP deployment of the Dast.
+type Script struct {
+	Name string     `json:"name"`
+	Type ScriptType `json:"type"`
+	// Engine of the script, defaults to Oracle Nashorn
+	Engine string `json:"engine,omitempty"`
+	// ConfigMap is the name of the ConfigMap holding the script
+	ConfigMap string `json:"configMap"`
+	// Key of the script in the ConfigMap, defaults to the name of the script
+	Key string `json:"key,omitempty"`
+}
+
+// Scope restricts the spider and the active scan to the matching URLs
+type Scope struct {
+	// Include is a list of regexes of URLs in scope, defaults to every URL under the target
+	Include []string `json:"include,omitempty"`
+	// Exclude is a list of regexes of URLs which must not be touched
+	Exclude []string `json:"exclude,omitempty"`
+	// Technologies used by the target, e.g. Db.PostgreSQL, OS.Linux, Language.Go
+	Technologies []string `json:"technologies,omitempty"`
+}
+
+// AuthenticationType defines how the analyzer logs in to the target
+// +kubebuilder:validation:Enum=form;json;header;oauth2
+type AuthenticationType string
+
+const (
+	// FormAuthentication posts a login form to LoginURL
+	FormAuthentication AuthenticationType = "form"
+	// JSONAuthentication posts a JSON login request to LoginURL
+	JSONAuthentication AuthenticationType = "json"
+	// HeaderAuthentication sends a static header (e.g. bearer token) with every request
+	HeaderAuthentication AuthenticationType = "header"
+	// OAuth2Authentication obtains a bearer token with the OAuth2 client credentials flow
+	OAuth2Authentication AuthenticationType = "oauth2"
+)
+
+// Authentication holds the settings of an authenticated scan.
+// Credentials are read from the referenced secret, which has to be in the namespace of the analyzer job.
+// The secret may contain the username, password, token, client_id and client_secret keys.
+type Authentication struct {
+	Type AuthenticationType `json:"type"`
+	// SecretName references the secret holding the credentials
+	SecretName string `json:"secretName"`
+	// LoginURL is the login endpoint for form and json authentication
+	LoginURL string `json:"loginUrl,omitempty"`
+	// LoginRequestData is the login request body, {%username%} and {%password%} are replaced by ZAP
+	LoginRequestData string `json:"loginRequestData,omitempty"`
+	// LoggedInIndicator is a regex matching responses of authenticated requests
+	LoggedInIndicator string `json:"loggedInIndicator,omitempty"`
+	// LoggedOutIndicator is a regex matching responses of unauthenticated requests
+	LoggedOutIndicator string `json:"loggedOutIndicator,omitempty"`
+	// HeaderName is the header carrying the token, defaults to Authorization
+	HeaderName string `json:"headerName,omitempty"`
+	// TokenURL is the OAuth2 token endpoint
+	TokenURL string `json:"tokenUrl,omitempty"`
+	// Scopes requested in the OAuth2 client credentials flow
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// Condition types of the Dast status
+const (
+	// ScanPassed is true when the analyzer job completed and the results are below the thresholds
+	ScanPassed = "ScanPassed"
+	// ScanFailed is true when the analyzer job failed, timed out or the results exceeded the thresholds
+	ScanFailed = "ScanFailed"
+)
+
+// Condition reasons of the Dast status
+const (
+	ReasonScanCompleted     = "ScanCompleted"
+	ReasonThresholdExceeded = "ThresholdExceeded"
+	ReasonTimeout           = "Timeout"
+	ReasonInterrupted       = "Interrupted"
+	ReasonError             = "Error"
+	ReasonScanInProgress    = "ScanInProgress"
+	// ReasonPending is the reason of selected targets waiting for a free analyzer slot
+	ReasonPending = "Pending"
+	// ReasonQueued is the reason of scans waiting in the scan queue of the operator
+	ReasonQueued = "Queued"
+	// ReasonInvalidAnnotation is the reason of scans, which aren't started because of an invalid annotation
+	ReasonInvalidAnnotation = "InvalidAnnotation"
+)
+
 // DastStatus defines the observed state of Dast
 type DastStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastScanID is the id of the latest stored scan of the service
+	LastScanID string `json:"lastScanID,omitempty"`
+	// NewHigh is the number of high alerts of the latest scan, which weren't reported by the previous scan
+	NewHigh int `json:"newHigh,omitempty"`
+	// Targets are the results of the services selected by the target selector
+	Targets []TargetStatus `json:"targets,omitempty"`
+	// QueuePosition is the position of the analyzer job in the scan queue of the operator while it's queued
+	QueuePosition int `json:"queuePosition,omitempty"`
+}
+
+// TargetStatus is the result of a service and port selected by the target selector
+type TargetStatus struct {
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	Port      int32  `json:"port"`
+	Job       string `json:"job,omitempty"`
+	// Phase is Pending, Queued, ScanInProgress, ScanPassed or ScanFailed
+	Phase   string `json:"phase"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// ScanID is the id of the stored scan of the target
+	ScanID string `json:"scanID,omitempty"`
+	// QueuePosition is the position of the analyzer job in the scan queue of the operator while it's queued
+	QueuePosition int `json:"queuePosition,omitempty"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 
 // Dast is the Schema for the dasts API
 type Dast struct {
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DastBaselineSpec defines the desired state of DastBaseline
+type DastBaselineSpec struct {
+	// Service is the name of the service in the namespace of the baseline
+	Service string `json:"service"`
+	// ScanID is the id of the scan the alerts were accepted from, the ids of the scans of the ports are comma separated
+	ScanID string `json:"scanID,omitempty"`
+	// AcceptedBy is the user, who accepted the alerts
+	AcceptedBy string `json:"acceptedBy,omitempty"`
+	// ExpiresAt is the time after the baseline isn't applied anymore, the baseline never expires if not set
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// Alerts are the accepted alerts, which aren't counted by the threshold checks
+	Alerts []BaselineAlert `json:"alerts,omitempty"`
+}
+
+// BaselineAlert identifies an accepted alert by plugin id, URL, method and parameter
+type BaselineAlert struct {
+	PluginID string `json:"pluginId"`
+	URL      string `json:"url"`
+	Method   string `json:"method,omitempty"`
+	Param    string `json:"param,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Risk     string `json:"risk,omitempty"`
+}
+
+// DastBaselineStatus defines the observed state of DastBaseline
+type DastBaselineStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Service",type=string,JSONPath=`.spec.service`
+// +kubebuilder:printcolumn:name="Accepted By",type=string,JSONPath=`.spec.acceptedBy`
+// +kubebuilder:printcolumn:name="Expires",type=string,format=date-time,JSONPath=`.spec.expiresAt`
+
+// DastBaseline is the Schema for the dastbaselines API
+type DastBaseline struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DastBaselineSpec   `json:"spec"`
+	Status DastBaselineStatus `json:"status,omitempty"`
+}
+
+// Expired reports whether the baseline is expired at the given time
+func (b *DastBaseline) Expired(now metav1.Time) bool {
+	return b.Spec.ExpiresAt != nil && !now.Before(b.Spec.ExpiresAt)
+}
+
+// +kubebuilder:object:root=true
+
+// DastBaselineList contains a list of DastBaseline
+type DastBaselineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DastBaseline `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DastBaseline{}, &DastBaselineList{})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotifierType is the format of the notification payload
+// +kubebuilder:validation:Enum=slack;teams;webhook
+type NotifierType string
+
+const (
+	// NotifierSlack sends Slack compatible incoming webhook messages
+	NotifierSlack NotifierType = "slack"
+	// NotifierTeams sends Microsoft Teams compatible connector cards
+	NotifierTeams NotifierType = "teams"
+	// NotifierWebhook sends the event as JSON
+	NotifierWebhook NotifierType = "webhook"
+)
+
+// NotificationEvent is an event, which triggers notifications
+// +kubebuilder:validation:Enum=ScanFinished;AdmissionDenied
+type NotificationEvent string
+
+const (
+	// EventScanFinished is sent when an analyzer job finished
+	EventScanFinished NotificationEvent = "ScanFinished"
+	// EventAdmissionDenied is sent when the ingress webhook denied an ingress
+	EventAdmissionDenied NotificationEvent = "AdmissionDenied"
+)
+
+// DastNotifierSpec defines the desired state of DastNotifier
+type DastNotifierSpec struct {
+	Type NotifierType `json:"type"`
+	// URLSecretRef is the secret holding the webhook URL
+	URLSecretRef SecretKeyRef `json:"urlSecretRef"`
+	// Events triggering the notification, every event if empty
+	Events []NotificationEvent `json:"events,omitempty"`
+	// Filters of the notifications
+	Filters NotifierFilters `json:"filters,omitempty"`
+	// Template is a Go template of the message, the event is passed to it
+	Template string `json:"template,omitempty"`
+}
+
+// SecretKeyRef selects a key of a secret in the namespace of the notifier
+type SecretKeyRef struct {
+	Name string `json:"name"`
+	// Key in the secret, url if not set
+	Key string `json:"key,omitempty"`
+}
+
+// NotifierFilters decide whether an event is notified
+type NotifierFilters struct {
+	// MinRisk skips scans without alerts of this or higher risk level
+	// +kubebuilder:validation:Enum=High;Medium;Low;Informational
+	MinRisk string `json:"minRisk,omitempty"`
+	// OnlyNew considers only the alerts, which weren't reported by the previous scan
+	OnlyNew bool `json:"onlyNew,omitempty"`
+	// OnlyFailures skips passed scans
+	OnlyFailures bool `json:"onlyFailures,omitempty"`
+}
+
+// DastNotifierStatus defines the observed state of DastNotifier
+type DastNotifierStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+
+// DastNotifier is the Schema for the dastnotifiers API
+type DastNotifier struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DastNotifierSpec   `json:"spec"`
+	Status DastNotifierStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DastNotifierList contains a list of DastNotifier
+type DastNotifierList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DastNotifier `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DastNotifier{}, &DastNotifierList{})
+}
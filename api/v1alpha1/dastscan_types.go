@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DastScanSpec identifies the scanned object in the namespace of the DastScan
+type DastScanSpec struct {
+	// Kind of the scanned object, Service or Ingress
+	Kind string `json:"kind"`
+	// Name of the scanned object
+	Name string `json:"name"`
+}
+
+// DastScanStatus defines the observed state of DastScan
+type DastScanStatus struct {
+	// Conditions are the ScanPassed and ScanFailed conditions of the latest scan run
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// LastScanID is the id of the latest stored scan of the object
+	LastScanID string `json:"lastScanID,omitempty"`
+	// NewHigh is the number of high alerts of the latest scan, which weren't reported by the previous scan
+	NewHigh int `json:"newHigh,omitempty"`
+	// QueuePosition is the position of the analyzer job in the scan queue of the operator while it's queued
+	QueuePosition int `json:"queuePosition,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+// +kubebuilder:printcolumn:name="Name",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="Passed",type=string,JSONPath=`.status.conditions[?(@.type=="ScanPassed")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="ScanPassed")].reason`
+
+// DastScan is the Schema for the dastscans API, it records the scan status of an annotated service or ingress.
+// It's created by the operator and owned by the scanned object.
+type DastScan struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DastScanSpec   `json:"spec"`
+	Status DastScanStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DastScanList contains a list of DastScan
+type DastScanList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DastScan `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DastScan{}, &DastScanList{})
+}
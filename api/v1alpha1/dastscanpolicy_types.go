@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AttackStrength of an active scan rule
+// +kubebuilder:validation:Enum=LOW;MEDIUM;HIGH;INSANE
+type AttackStrength string
+
+// AlertThreshold of an active scan rule
+// +kubebuilder:validation:Enum=OFF;LOW;MEDIUM;HIGH
+type AlertThreshold string
+
+// DastScanPolicySpec defines the desired state of DastScanPolicy
+type DastScanPolicySpec struct {
+	// AttackStrength is the default attack strength of the policy
+	AttackStrength AttackStrength `json:"attackStrength,omitempty"`
+	// AlertThreshold is the default alert threshold of the policy
+	AlertThreshold AlertThreshold `json:"alertThreshold,omitempty"`
+	// Categories overrides the settings of scanner categories
+	Categories []ScanPolicyRule `json:"categories,omitempty"`
+	// Scanners overrides the settings of individual scanners
+	Scanners []ScanPolicyRule `json:"scanners,omitempty"`
+	// DisabledScanners is a list of scanner IDs which are not run
+	DisabledScanners []string `json:"disabledScanners,omitempty"`
+}
+
+// ScanPolicyRule sets attack strength and alert threshold of a scanner or a category
+type ScanPolicyRule struct {
+	// ID of the scanner or the category
+	ID             string         `json:"id"`
+	AttackStrength AttackStrength `json:"attackStrength,omitempty"`
+	AlertThreshold AlertThreshold `json:"alertThreshold,omitempty"`
+}
+
+// DastScanPolicyStatus defines the observed state of DastScanPolicy
+type DastScanPolicyStatus struct {
+}
+
+// +kubebuilder:object:root=true
+
+// DastScanPolicy is the Schema for the dastscanpolicies API
+type DastScanPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DastScanPolicySpec   `json:"spec"`
+	Status DastScanPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DastScanPolicyList contains a list of DastScanPolicy
+type DastScanPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DastScanPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DastScanPolicy{}, &DastScanPolicyList{})
+}
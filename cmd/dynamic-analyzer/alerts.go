@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+// noAlerts is the watermark of a ZAP instance without alerts
+const noAlerts = -1
+
+// lastAlertID returns the id of the latest alert of the ZAP session, noAlerts if there are no alerts.
+// Alerts raised by the scan have higher ids, so alerts of previous scans of the target are told apart.
+func lastAlertID(client zap.Interface) (int, error) {
+	resp, err := client.Core().NumberOfAlerts("", "")
+	if err := zapError(resp, err); err != nil {
+		return 0, fmt.Errorf("failed to get number of alerts: %v", err)
+	}
+	count, err := strconv.Atoi(fmt.Sprint(resp["numberOfAlerts"]))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected number of alerts response: %v", resp)
+	}
+	if count == 0 {
+		return noAlerts, nil
+	}
+	// alerts are listed in the order of their ids
+	resp, err = client.Core().Alerts("", strconv.Itoa(count-1), "1", "")
+	if err := zapError(resp, err); err != nil {
+		return 0, fmt.Errorf("failed to get the latest alert: %v", err)
+	}
+	alerts, _ := resp["alerts"].([]interface{})
+	if len(alerts) == 0 {
+		return noAlerts, nil
+	}
+	return alertID(alerts[0])
+}
+
+// scanAlerts returns the alerts of the target raised after the watermark alert id.
+// ZAP matches the alerts by URL prefix, so the alerts of other ports of the host are skipped.
+func scanAlerts(client zap.Interface, after int) ([]map[string]interface{}, error) {
+	resp, err := client.Core().Alerts(target, "", "", "")
+	if err := zapError(resp, err); err != nil {
+		return nil, fmt.Errorf("failed to get alerts: %v", err)
+	}
+	items, _ := resp["alerts"].([]interface{})
+	alerts := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		id, err := alertID(item)
+		if err != nil {
+			return nil, err
+		}
+		alert := item.(map[string]interface{})
+		if id > after && sameSite(target, fmt.Sprint(alert["url"])) {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+// sameSite reports whether the URL has the scheme, host and port of the target
+func sameSite(target, rawURL string) bool {
+	t, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return t.Scheme == u.Scheme && t.Hostname() == u.Hostname() && sitePort(t) == sitePort(u)
+}
+
+// sitePort returns the port of the URL, the default port of the scheme if it isn't set
+func sitePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// summarize returns the number of alerts per risk level
+func summarize(alerts []map[string]interface{}) map[string]int {
+	summary := map[string]int{
+		"High":          0,
+		"Medium":        0,
+		"Low":           0,
+		"Informational": 0,
+	}
+	for _, alert := range alerts {
+		summary[fmt.Sprint(alert["risk"])]++
+	}
+	return summary
+}
+
+func alertID(item interface{}) (int, error) {
+	alert, ok := item.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("unexpected alert: %v", item)
+	}
+	id, err := strconv.Atoi(fmt.Sprint(alert["id"]))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected alert id: %v", alert["id"])
+	}
+	return id, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestLastAlertID(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"core/view/numberOfAlerts/": {`{"numberOfAlerts":"0"}`, `{"numberOfAlerts":"3"}`},
+		"core/view/alerts/":         {`{"alerts":[{"id":"7","risk":"High"}]}`},
+	})
+	defer fake.close()
+
+	if id, err := lastAlertID(client); err != nil || id != noAlerts {
+		t.Errorf("expected no alerts, got %d, %v", id, err)
+	}
+	if id, err := lastAlertID(client); err != nil || id != 7 {
+		t.Errorf("expected 7, got %d, %v", id, err)
+	}
+	if calls := fake.called("core/view/alerts/"); len(calls) != 1 || calls[0].Get("start") != "2" || calls[0].Get("count") != "1" {
+		t.Errorf("unexpected alert queries %v", calls)
+	}
+}
+
+func TestScanAlerts(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"core/view/alerts/": {`{"alerts":[
+			{"id":"3","risk":"High","url":"http://app.default.svc.cluster.local:80/"},
+			{"id":"5","risk":"High","url":"http://app.default.svc.cluster.local/"},
+			{"id":"8","risk":"Medium","url":"http://app.default.svc.cluster.local:80/search"},
+			{"id":"9","risk":"Low","url":"http://app.default.svc.cluster.local/"},
+			{"id":"10","risk":"High","url":"http://app.default.svc.cluster.local:8080/"}
+		]}`},
+	})
+	defer fake.close()
+	target, failOn = "http://app.default.svc.cluster.local:80", "high=0,medium=0"
+	defer func() { target, failOn = "", "" }()
+
+	// the alerts of the previous scans of the target are up to id 5, and the alerts of the other port aren't counted
+	alerts, err := scanAlerts(client, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := summarize(alerts)
+	if summary["High"] != 0 || summary["Medium"] != 1 || summary["Low"] != 1 {
+		t.Errorf("unexpected summary %v", summary)
+	}
+	if calls := fake.called("core/view/alerts/"); len(calls) != 1 || calls[0].Get("baseurl") != target {
+		t.Errorf("unexpected alert queries %v", calls)
+	}
+
+	message, err := checkThresholds(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message != "alerts above threshold: Medium: 1 > 0" {
+		t.Errorf("unexpected message %q", message)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+const (
+	userName = "dast"
+	// tokenTimeout limits the token request of the oauth2 authentication
+	tokenTimeout = 30 * time.Second
+)
+
+// tokenClient requests the tokens of the oauth2 authentication
+var tokenClient = &http.Client{Timeout: tokenTimeout}
+
+var authType string
+var loginURL string
+var loginData string
+var loggedInIndicator string
+var loggedOutIndicator string
+var authHeader string
+var tokenURL string
+var scopes string
+
+func addAuthFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&authType, "auth-type", "", "Authentication type: form, json, header or oauth2")
+	cmd.Flags().StringVar(&loginURL, "login-url", "", "Login URL for form and json authentication")
+	cmd.Flags().StringVar(&loginData, "login-data", "", "Login request data for form and json authentication")
+	cmd.Flags().StringVar(&loggedInIndicator, "logged-in-indicator", "", "Regex matching responses of authenticated requests")
+	cmd.Flags().StringVar(&loggedOutIndicator, "logged-out-indicator", "", "Regex matching responses of unauthenticated requests")
+	cmd.Flags().StringVar(&authHeader, "auth-header", "Authorization", "Header carrying the token for header and oauth2 authentication")
+	cmd.Flags().StringVar(&tokenURL, "token-url", "", "OAuth2 token URL")
+	cmd.Flags().StringVar(&scopes, "scopes", "", "Comma separated list of OAuth2 scopes")
+}
+
+// userAuthentication reports whether the authentication type needs a ZAP context and user
+func userAuthentication() bool {
+	return authType == "form" || authType == "json"
+}
+
+// setupAuthentication configures ZAP according to the auth flags.
+// Form and json authentication adds the user of zapCtx, which the scans are run as.
+// Header and oauth2 authentication adds a header rule, which is removed by cleanup.
+func setupAuthentication(ctx context.Context, client zap.Interface, zapCtx *zapContext, cleanup *teardown) error {
+	switch authType {
+	case "":
+		return nil
+	case "form":
+		return setupUserAuthentication(client, zapCtx, "formBasedAuthentication")
+	case "json":
+		return setupUserAuthentication(client, zapCtx, "jsonBasedAuthentication")
+	case "header":
+		token := os.Getenv("DAST_AUTH_TOKEN")
+		if token == "" {
+			return errors.New("missing token for header authentication")
+		}
+		return addHeaderRule(client, cleanup, runName("auth"), authHeader, token)
+	case "oauth2":
+		token, err := clientCredentialsToken(ctx)
+		if err != nil {
+			return err
+		}
+		return addHeaderRule(client, cleanup, runName("auth"), authHeader, "Bearer "+token)
+	default:
+		return fmt.Errorf("unsupported authentication type: %s", authType)
+	}
+}
+
+func setupUserAuthentication(client zap.Interface, zapCtx *zapContext, method string) error {
+	username := os.Getenv("DAST_AUTH_USERNAME")
+	password := os.Getenv("DAST_AUTH_PASSWORD")
+	if username == "" || password == "" {
+		return errors.New("missing username or password for " + authType + " authentication")
+	}
+	if loginURL == "" {
+		return errors.New("missing login url for " + authType + " authentication")
+	}
+	if zapCtx == nil {
+		return errors.New("missing zap context for " + authType + " authentication")
+	}
+
+	params := url.Values{}
+	params.Set("loginUrl", loginURL)
+	if loginData != "" {
+		params.Set("loginRequestData", loginData)
+	}
+	if err := zapError(client.Authentication().SetAuthenticationMethod(zapCtx.id, method, params.Encode())); err != nil {
+		return err
+	}
+	if loggedInIndicator != "" {
+		if err := zapError(client.Authentication().SetLoggedInIndicator(zapCtx.id, loggedInIndicator)); err != nil {
+			return err
+		}
+	}
+	if loggedOutIndicator != "" {
+		if err := zapError(client.Authentication().SetLoggedOutIndicator(zapCtx.id, loggedOutIndicator)); err != nil {
+			return err
+		}
+	}
+
+	// the user belongs to the context of the scan, it's removed with the context
+	resp, err := client.Users().NewUser(zapCtx.id, userName)
+	if err := zapError(resp, err); err != nil {
+		return err
+	}
+	userID, ok := resp["userId"].(string)
+	if !ok {
+		return fmt.Errorf("unexpected response on creating user: %v", resp)
+	}
+	credentials := url.Values{}
+	credentials.Set("username", username)
+	credentials.Set("password", password)
+	if err := zapError(client.Users().SetAuthenticationCredentials(zapCtx.id, userID, credentials.Encode())); err != nil {
+		return err
+	}
+	if err := zapError(client.Users().SetUserEnabled(zapCtx.id, userID, "true")); err != nil {
+		return err
+	}
+	// the spiders and the active scan are run as the user, the global forced user mode of ZAP isn't needed
+	zapCtx.userID = userID
+
+	fmt.Println("Authentication configured for user: " + username)
+	return nil
+}
+
+// addHeaderRule adds a replacer rule, so ZAP sends the header with the requests to the target.
+// The rule is named after the scan and removed by cleanup, the rule of a previous attempt of the scan is replaced.
+func addHeaderRule(client zap.Interface, cleanup *teardown, description, header, value string) error {
+	remove := func() error {
+		return zapError(zapRequest(client, "replacer/action/removeRule/", map[string]string{
+			"description": description,
+		}))
+	}
+	if err := remove(); err != nil && !hasCode(err, "does_not_exist") {
+		return err
+	}
+	err := zapError(zapRequest(client, "replacer/action/addRule/", map[string]string{
+		"description": description,
+		"enabled":     "true",
+		"matchType":   "REQ_HEADER",
+		"matchRegex":  "false",
+		"matchString": header,
+		"replacement": value,
+		// replacer rules apply to every request of ZAP, the rule is restricted to the target
+		"url": regexp.QuoteMeta(target) + ".*",
+	}))
+	if err != nil {
+		return err
+	}
+	cleanup.add("header rule "+description, remove)
+	fmt.Println("Header configured: " + header)
+	return nil
+}
+
+// clientCredentialsToken requests an access token using the OAuth2 client credentials flow.
+// The request is stopped when ctx is done or tokenTimeout is exceeded.
+func clientCredentialsToken(ctx context.Context) (string, error) {
+	clientID := os.Getenv("DAST_AUTH_CLIENT_ID")
+	clientSecret := os.Getenv("DAST_AUTH_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" || tokenURL == "" {
+		return "", errors.New("missing client id, client secret or token url for oauth2 authentication")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if scopes != "" {
+		form.Set("scope", strings.ReplaceAll(scopes, ",", " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+	resp, err := tokenClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("token response doesn't contain access_token")
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHeaderAuthentication(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"replacer/action/removeRule/": {`{"code":"does_not_exist","message":"Does Not Exist"}`, `{"Result":"OK"}`},
+	})
+	defer fake.close()
+	authType, authHeader, target, runID = "header", "Authorization", "http://app.default.svc.cluster.local:80", "run-1"
+	defer func() { authType, target, runID = "", "", "" }()
+	os.Setenv("DAST_AUTH_TOKEN", "secret")
+	defer os.Unsetenv("DAST_AUTH_TOKEN")
+
+	var cleanup teardown
+	if err := setupAuthentication(context.Background(), client, nil, &cleanup); err != nil {
+		t.Fatal(err)
+	}
+	rules := fake.called("replacer/action/addRule/")
+	if len(rules) != 1 {
+		t.Fatalf("expected a header rule, got %v", rules)
+	}
+	if rules[0].Get("description") != "dast-auth-run-1" || rules[0].Get("replacement") != "secret" {
+		t.Errorf("unexpected header rule: %v", rules[0])
+	}
+	if url := rules[0].Get("url"); url != `http://app\.default\.svc\.cluster\.local:80.*` {
+		t.Errorf("header rule isn't restricted to the target: %s", url)
+	}
+
+	cleanup.run()
+	removed := fake.called("replacer/action/removeRule/")
+	if len(removed) != 2 || removed[1].Get("description") != "dast-auth-run-1" {
+		t.Errorf("header rule isn't removed: %v", removed)
+	}
+}
+
+func TestHeaderAuthenticationError(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"replacer/action/addRule/": {`{"code":"already_exists","message":"Already Exists"}`},
+	})
+	defer fake.close()
+	authType, runID = "header", "run-1"
+	defer func() { authType, runID = "", "" }()
+	os.Setenv("DAST_AUTH_TOKEN", "secret")
+	defer os.Unsetenv("DAST_AUTH_TOKEN")
+
+	var cleanup teardown
+	if err := setupAuthentication(context.Background(), client, nil, &cleanup); !hasCode(err, "already_exists") {
+		t.Errorf("expected already_exists error, got %v", err)
+	}
+	if len(cleanup) != 0 {
+		t.Error("rule, which wasn't added, is removed")
+	}
+}
+
+func TestFormAuthentication(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"users/action/newUser/": {`{"userId":"3"}`},
+	})
+	defer fake.close()
+	authType, loginURL = "form", "http://app/login"
+	defer func() { authType, loginURL = "", "" }()
+	os.Setenv("DAST_AUTH_USERNAME", "user")
+	os.Setenv("DAST_AUTH_PASSWORD", "password")
+	defer os.Unsetenv("DAST_AUTH_USERNAME")
+	defer os.Unsetenv("DAST_AUTH_PASSWORD")
+
+	zapCtx := &zapContext{name: "dast-context-run-1", id: "2"}
+	var cleanup teardown
+	if err := setupAuthentication(context.Background(), client, zapCtx, &cleanup); err != nil {
+		t.Fatal(err)
+	}
+	if zapCtx.userID != "3" {
+		t.Errorf("expected user 3, got %q", zapCtx.userID)
+	}
+	if calls := fake.called("forcedUser/action/setForcedUserModeEnabled/"); len(calls) != 0 {
+		t.Error("forced user mode of ZAP is enabled")
+	}
+}
+
+func TestClientCredentialsToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token"}`))
+	}))
+	defer server.Close()
+	tokenURL, scopes = server.URL, "read,write"
+	defer func() { tokenURL, scopes = "", "" }()
+	os.Setenv("DAST_AUTH_CLIENT_ID", "client")
+	os.Setenv("DAST_AUTH_CLIENT_SECRET", "secret")
+	defer os.Unsetenv("DAST_AUTH_CLIENT_ID")
+	defer os.Unsetenv("DAST_AUTH_CLIENT_SECRET")
+
+	token, err := clientCredentialsToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token" {
+		t.Errorf("expected token, got %q", token)
+	}
+}
+
+func TestClientCredentialsTokenTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	tokenURL = server.URL
+	defer func() { tokenURL = "" }()
+	os.Setenv("DAST_AUTH_CLIENT_ID", "client")
+	os.Setenv("DAST_AUTH_CLIENT_SECRET", "secret")
+	defer os.Unsetenv("DAST_AUTH_CLIENT_ID")
+	defer os.Unsetenv("DAST_AUTH_CLIENT_SECRET")
+
+	// the hanging token endpoint is given up on when the scan is stopped
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := clientCredentialsToken(ctx); err == nil {
+		t.Error("token of a hanging endpoint is returned")
+	}
+}
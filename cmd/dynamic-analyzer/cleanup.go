@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var runID string
+
+// teardown collects the functions removing the configuration added to the shared ZAP instance by the scan
+type teardown []func()
+
+func addRunIDFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&runID, "run-id", os.Getenv("DAST_RUN_ID"), "Unique id of the scan run, the configuration added to ZAP is named after it. The hostname is used if empty.")
+}
+
+// runName returns the name of a ZAP object owned by the scan run
+func runName(kind string) string {
+	return "dast-" + kind + "-" + runID
+}
+
+// add registers a function removing a ZAP object, failures are only logged
+func (t *teardown) add(description string, remove func() error) {
+	*t = append(*t, func() {
+		if err := remove(); err != nil {
+			fmt.Printf("failed to remove %s: %v\n", description, err)
+		}
+	})
+}
+
+// run calls the registered functions in reverse order
+func (t teardown) run() {
+	for i := len(t) - 1; i >= 0; i-- {
+		t[i]()
+	}
+}
+
+// apiError is an error response of the ZAP API
+type apiError struct {
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("zap api error: %s %s", e.code, e.message)
+}
+
+// hasCode reports whether err is a ZAP API error with the code
+func hasCode(err error, code string) bool {
+	var e *apiError
+	return errors.As(err, &e) && e.code == code
+}
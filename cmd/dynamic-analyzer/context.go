@@ -0,0 +1,123 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+var includeRegexes []string
+var excludeRegexes []string
+var technologies []string
+var ajaxSpider bool
+
+// zapContext holds the ids of the ZAP context and the forced user
+type zapContext struct {
+	name   string
+	id     string
+	userID string
+}
+
+func addScopeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&includeRegexes, "include", nil, "Regex of URLs included in the scan, can be repeated")
+	cmd.Flags().StringArrayVar(&excludeRegexes, "exclude", nil, "Regex of URLs excluded from the scan, can be repeated")
+	cmd.Flags().StringSliceVar(&technologies, "technologies", nil, "Comma separated list of technologies used by the target, e.g. Db.PostgreSQL,OS.Linux,Language.Go")
+}
+
+// setupContext creates the ZAP context of the scan, which is named after the run and removed by cleanup.
+// It returns nil if neither scope nor user authentication is configured.
+func setupContext(client zap.Interface, cleanup *teardown) (*zapContext, error) {
+	if len(includeRegexes) == 0 && len(excludeRegexes) == 0 && len(technologies) == 0 && !userAuthentication() {
+		return nil, nil
+	}
+
+	contextName := runName("context")
+	remove := func() error {
+		return zapError(client.Context().RemoveContext(contextName))
+	}
+	// the context of a previous attempt of the run
+	if err := remove(); err != nil && !hasCode(err, "context_not_found") {
+		return nil, err
+	}
+	resp, err := client.Context().NewContext(contextName)
+	if err := zapError(resp, err); err != nil {
+		return nil, err
+	}
+	contextID, ok := resp["contextId"].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response on creating context: %v", resp)
+	}
+	cleanup.add("context "+contextName, remove)
+
+	includes := includeRegexes
+	if len(includes) == 0 {
+		includes = []string{regexp.QuoteMeta(target) + ".*"}
+	}
+	for _, regex := range includes {
+		if err := zapError(client.Context().IncludeInContext(contextName, regex)); err != nil {
+			return nil, err
+		}
+	}
+	for _, regex := range excludeRegexes {
+		if err := zapError(client.Context().ExcludeFromContext(contextName, regex)); err != nil {
+			return nil, err
+		}
+	}
+	if len(technologies) > 0 {
+		if err := zapError(client.Context().ExcludeAllContextTechnologies(contextName)); err != nil {
+			return nil, err
+		}
+		if err := zapError(client.Context().IncludeContextTechnologies(contextName, strings.Join(technologies, ","))); err != nil {
+			return nil, err
+		}
+	}
+	if err := zapError(client.Context().SetContextInScope(contextName, "true")); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Context created, include: %v exclude: %v\n", includes, excludeRegexes)
+	return &zapContext{
+		name: contextName,
+		id:   contextID,
+	}, nil
+}
+
+// zapRequest calls ZAP API components, which aren't covered by zap-api-go
+func zapRequest(client zap.Interface, path string, params map[string]string) (map[string]interface{}, error) {
+	c, ok := client.(*zap.Client)
+	if !ok {
+		return nil, errors.New("unsupported zap client")
+	}
+	return c.Request(path, params)
+}
+
+// zapError returns the error reported in the ZAP API response
+func zapError(resp map[string]interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	if code, ok := resp["code"]; ok {
+		return &apiError{code: fmt.Sprint(code), message: fmt.Sprint(resp["message"])}
+	}
+	return nil
+}
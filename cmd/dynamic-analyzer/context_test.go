@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSetupContext(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"context/action/removeContext/": {`{"code":"context_not_found","message":"Context Not Found"}`, `{"Result":"OK"}`},
+		"context/action/newContext/":    {`{"contextId":"1"}`, `{"contextId":"2"}`},
+	})
+	defer fake.close()
+	excludeRegexes, target = []string{".*logout.*"}, "http://app.default.svc.cluster.local:80"
+	defer func() { excludeRegexes, target, runID = nil, "", "" }()
+
+	for i, run := range []string{"app-1", "app-2"} {
+		runID = run
+		var cleanup teardown
+		zapCtx, err := setupContext(client, &cleanup)
+		if err != nil {
+			t.Fatalf("run %s: %v", run, err)
+		}
+		if zapCtx.name != "dast-context-"+run {
+			t.Errorf("run %s: unexpected context name %s", run, zapCtx.name)
+		}
+		created := fake.called("context/action/newContext/")
+		if len(created) != i+1 || created[i].Get("contextName") != zapCtx.name {
+			t.Errorf("run %s: unexpected contexts: %v", run, created)
+		}
+		cleanup.run()
+	}
+
+	removed := fake.called("context/action/removeContext/")
+	var names []string
+	for _, call := range removed {
+		names = append(names, call.Get("contextName"))
+	}
+	expected := []string{"dast-context-app-1", "dast-context-app-1", "dast-context-app-2", "dast-context-app-2"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected removed contexts %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected removed contexts %v, got %v", expected, names)
+		}
+	}
+	if includes := fake.called("context/action/includeInContext/"); len(includes) != 2 || includes[0].Get("regex") != `http://app\.default\.svc\.cluster\.local:80.*` {
+		t.Errorf("unexpected includes: %v", includes)
+	}
+}
+
+func TestSetupContextError(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"context/action/removeContext/": {`{"code":"context_not_found","message":"Context Not Found"}`},
+		"context/action/newContext/":    {`{"code":"already_exists","message":"Already Exists"}`},
+	})
+	defer fake.close()
+	excludeRegexes, runID = []string{".*logout.*"}, "app-1"
+	defer func() { excludeRegexes, runID = nil, "" }()
+
+	var cleanup teardown
+	if _, err := setupContext(client, &cleanup); !hasCode(err, "already_exists") {
+		t.Errorf("expected already_exists error, got %v", err)
+	}
+}
+
+func TestSetupContextWithoutScope(t *testing.T) {
+	client, fake := newFakeZAP(t, nil)
+	defer fake.close()
+
+	var cleanup teardown
+	zapCtx, err := setupContext(client, &cleanup)
+	if err != nil || zapCtx != nil {
+		t.Errorf("expected no context, got %v %v", zapCtx, err)
+	}
+	if len(fake.calls) != 0 {
+		t.Errorf("unexpected calls: %v", fake.calls)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// exitCodeError is returned when the scan failed
+	exitCodeError = 1
+	// exitCodeTimeout is returned when the scan was stopped by a timeout, the printed results are partial
+	exitCodeTimeout = 3
+	// exitCodeInterrupted is returned when the scan was stopped by SIGTERM or SIGINT
+	exitCodeInterrupted = 143
+
+	maxBackoff         = 1 * time.Minute
+	maxConsecutiveErrs = 10
+)
+
+// errStageTimeout is returned when a single stage exceeded its timeout
+var errStageTimeout = errors.New("stage timeout exceeded")
+
+var maxDuration time.Duration
+var spiderTimeout time.Duration
+var ajaxSpiderTimeout time.Duration
+var activeScanTimeout time.Duration
+
+var interrupted = make(chan struct{})
+
+func addTimeoutFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "Maximum duration of the whole scan, 0 means no limit")
+	cmd.Flags().DurationVar(&spiderTimeout, "spider-timeout", 0, "Maximum duration of the spider, 0 means no limit")
+	cmd.Flags().DurationVar(&ajaxSpiderTimeout, "ajax-spider-timeout", 0, "Maximum duration of the AJAX spider, 0 means no limit")
+	cmd.Flags().DurationVar(&activeScanTimeout, "active-scan-timeout", 0, "Maximum duration of the active scan, 0 means no limit")
+}
+
+// scanContext returns a context which is done when the max duration is exceeded or SIGTERM is received
+func scanContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timeoutCancel := context.CancelFunc(func() {})
+	if maxDuration > 0 {
+		ctx, timeoutCancel = context.WithTimeout(ctx, maxDuration)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Printf("Received %s, stopping scans\n", sig)
+			close(interrupted)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		timeoutCancel()
+		cancel()
+	}
+}
+
+// waitFor polls the progress of a scan stage until it reaches 100.
+// API errors are retried with exponential backoff.
+// It returns errStageTimeout if the stage timeout is exceeded and the error of ctx if ctx is done.
+func waitFor(ctx context.Context, stage string, timeout, interval time.Duration, progress func() (int, error)) error {
+	stageCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	wait := interval
+	errs := 0
+	for {
+		select {
+		case <-stageCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errStageTimeout
+		case <-time.After(wait):
+		}
+
+		p, err := progress()
+		if err != nil {
+			errs++
+			if errs >= maxConsecutiveErrs {
+				return fmt.Errorf("%s failed after %d attempts: %v", stage, errs, err)
+			}
+			wait *= 2
+			if wait > maxBackoff {
+				wait = maxBackoff
+			}
+			fmt.Printf("%s status failed, retrying in %s: %v\n", stage, wait, err)
+			continue
+		}
+		errs = 0
+		wait = interval
+
+		fmt.Printf("%s progress : %d\n", stage, p)
+		if p >= 100 {
+			return nil
+		}
+	}
+}
+
+// scanID returns the id of the started scan
+func scanID(resp map[string]interface{}, err error) (string, error) {
+	if err := zapError(resp, err); err != nil {
+		return "", err
+	}
+	id, ok := resp["scan"].(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected response on starting scan: %v", resp)
+	}
+	return id, nil
+}
+
+// scanProgress returns the progress from a status response
+func scanProgress(resp map[string]interface{}, err error) (int, error) {
+	if err := zapError(resp, err); err != nil {
+		return 0, err
+	}
+	status, ok := resp["status"].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected status response: %v", resp)
+	}
+	return strconv.Atoi(status)
+}
+
+// exitCode returns the exit code of the analyzer after the scan is finished
+func exitCode(ctx context.Context, timedOut, thresholdExceeded bool) int {
+	select {
+	case <-interrupted:
+		return exitCodeInterrupted
+	default:
+	}
+	if thresholdExceeded {
+		return exitCodeThresholdExceeded
+	}
+	if timedOut || ctx.Err() != nil {
+		return exitCodeTimeout
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScanContext(t *testing.T) {
+	maxDuration = 10 * time.Millisecond
+	defer func() { maxDuration = 0 }()
+
+	ctx, cancel := scanContext()
+	defer cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("max duration isn't applied")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", ctx.Err())
+	}
+
+	maxDuration = time.Hour
+	ctx, cancel = scanContext()
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("context isn't canceled: %v", ctx.Err())
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	ctx := context.Background()
+
+	progress := 0
+	if err := waitFor(ctx, "Spider", 0, time.Millisecond, func() (int, error) {
+		progress += 50
+		return progress, nil
+	}); err != nil {
+		t.Errorf("finished stage failed: %v", err)
+	}
+
+	if err := waitFor(ctx, "Spider", 10*time.Millisecond, time.Millisecond, func() (int, error) {
+		return 0, nil
+	}); err != errStageTimeout {
+		t.Errorf("expected stage timeout, got %v", err)
+	}
+
+	errs := 0
+	if err := waitFor(ctx, "Spider", 0, time.Microsecond, func() (int, error) {
+		errs++
+		return 0, errors.New("connection refused")
+	}); err == nil || errs != maxConsecutiveErrs {
+		t.Errorf("expected failure after %d errors, got %v after %d", maxConsecutiveErrs, err, errs)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := waitFor(canceled, "Spider", time.Hour, time.Millisecond, func() (int, error) {
+		return 0, nil
+	}); err != context.Canceled {
+		t.Errorf("expected canceled, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+var scanPolicyName string
+var scanPolicyConfig string
+
+// activeScanPolicy is the policy of the active scan, the policy created for the run if the policy is defined
+var activeScanPolicy string
+
+// scanPolicy is the JSON form of the DastScanPolicy spec
+type scanPolicy struct {
+	AttackStrength   string           `json:"attackStrength,omitempty"`
+	AlertThreshold   string           `json:"alertThreshold,omitempty"`
+	Categories       []scanPolicyRule `json:"categories,omitempty"`
+	Scanners         []scanPolicyRule `json:"scanners,omitempty"`
+	DisabledScanners []string         `json:"disabledScanners,omitempty"`
+}
+
+type scanPolicyRule struct {
+	ID             string `json:"id"`
+	AttackStrength string `json:"attackStrength,omitempty"`
+	AlertThreshold string `json:"alertThreshold,omitempty"`
+}
+
+func addScanPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&scanPolicyName, "scan-policy", "", "Name of the active scan policy")
+	cmd.Flags().StringVar(&scanPolicyConfig, "scan-policy-config", "", "Scan policy definition in JSON, the policy is created in ZAP if defined")
+}
+
+// setupScanPolicy creates the scan policy of the run in ZAP, which is named after the run and removed by cleanup.
+// The policy is used by name if it isn't defined.
+func setupScanPolicy(client zap.Interface, cleanup *teardown) error {
+	activeScanPolicy = scanPolicyName
+	if scanPolicyName == "" || scanPolicyConfig == "" {
+		return nil
+	}
+
+	policy := scanPolicy{}
+	if err := json.Unmarshal([]byte(scanPolicyConfig), &policy); err != nil {
+		return fmt.Errorf("invalid scan policy config: %v", err)
+	}
+
+	policyName := runName("policy")
+	remove := func() error {
+		return zapError(client.Ascan().RemoveScanPolicy(policyName))
+	}
+	resp, err := client.Ascan().ScanPolicyNames()
+	if err := zapError(resp, err); err != nil {
+		return err
+	}
+	if names, ok := resp["scanPolicyNames"].([]interface{}); ok {
+		for _, name := range names {
+			// the policy of a previous attempt of the run
+			if name == policyName {
+				if err := remove(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := zapError(client.Ascan().AddScanPolicy(policyName, policy.AlertThreshold, policy.AttackStrength)); err != nil {
+		return err
+	}
+	cleanup.add("scan policy "+policyName, remove)
+	activeScanPolicy = policyName
+	for _, category := range policy.Categories {
+		if category.AttackStrength != "" {
+			if _, err := client.Ascan().SetPolicyAttackStrength(category.ID, category.AttackStrength, policyName); err != nil {
+				return err
+			}
+		}
+		if category.AlertThreshold != "" {
+			if _, err := client.Ascan().SetPolicyAlertThreshold(category.ID, category.AlertThreshold, policyName); err != nil {
+				return err
+			}
+		}
+	}
+	for _, scanner := range policy.Scanners {
+		if scanner.AttackStrength != "" {
+			if _, err := client.Ascan().SetScannerAttackStrength(scanner.ID, scanner.AttackStrength, policyName); err != nil {
+				return err
+			}
+		}
+		if scanner.AlertThreshold != "" {
+			if _, err := client.Ascan().SetScannerAlertThreshold(scanner.ID, scanner.AlertThreshold, policyName); err != nil {
+				return err
+			}
+		}
+	}
+	if len(policy.DisabledScanners) > 0 {
+		if _, err := client.Ascan().DisableScanners(strings.Join(policy.DisabledScanners, ","), policyName); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Scan policy %s created as %s\n", scanPolicyName, policyName)
+	return nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSetupScanPolicy(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"ascan/view/scanPolicyNames/": {`{"scanPolicyNames":["Default Policy","policy","dast-policy-app-1"]}`},
+	})
+	defer fake.close()
+	scanPolicyName, scanPolicyConfig, runID = "policy", `{"categories":[{"id":"0","attackStrength":"HIGH"}]}`, "app-1"
+	defer func() { scanPolicyName, scanPolicyConfig, runID, activeScanPolicy = "", "", "", "" }()
+
+	var cleanup teardown
+	if err := setupScanPolicy(client, &cleanup); err != nil {
+		t.Fatal(err)
+	}
+	if activeScanPolicy != "dast-policy-app-1" {
+		t.Errorf("unexpected active scan policy %s", activeScanPolicy)
+	}
+	// only the policy left over by a previous attempt of the run is removed
+	if removed := fake.called("ascan/action/removeScanPolicy/"); len(removed) != 1 || removed[0].Get("scanPolicyName") != "dast-policy-app-1" {
+		t.Errorf("unexpected removed policies %v", removed)
+	}
+	if added := fake.called("ascan/action/addScanPolicy/"); len(added) != 1 || added[0].Get("scanPolicyName") != "dast-policy-app-1" {
+		t.Errorf("unexpected added policies %v", added)
+	}
+	if strengths := fake.called("ascan/action/setPolicyAttackStrength/"); len(strengths) != 1 || strengths[0].Get("scanPolicyName") != "dast-policy-app-1" {
+		t.Errorf("unexpected attack strengths %v", strengths)
+	}
+
+	cleanup.run()
+	if removed := fake.called("ascan/action/removeScanPolicy/"); len(removed) != 2 || removed[1].Get("scanPolicyName") != "dast-policy-app-1" {
+		t.Errorf("policy of the run isn't removed by cleanup %v", removed)
+	}
+}
+
+func TestSetupScanPolicyByName(t *testing.T) {
+	client, fake := newFakeZAP(t, nil)
+	defer fake.close()
+	scanPolicyName = "Default Policy"
+	defer func() { scanPolicyName, activeScanPolicy = "", "" }()
+
+	var cleanup teardown
+	if err := setupScanPolicy(client, &cleanup); err != nil {
+		t.Fatal(err)
+	}
+	if activeScanPolicy != "Default Policy" || len(fake.calls) != 0 || len(cleanup) != 0 {
+		t.Errorf("existing policy %s is modified: %v", activeScanPolicy, fake.calls)
+	}
+}
@@ -17,11 +17,10 @@ limitations under the License.
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -31,7 +30,6 @@ import (
 var zapAddr string
 var target string
 var apiKey string
-var serve bool
 var openapiURL string
 
 func NewScannerCmd() *cobra.Command {
@@ -39,14 +37,22 @@ func NewScannerCmd() *cobra.Command {
 		Use:   "scanner",
 		Short: "Scanner application using Zap",
 		Run: func(cmd *cobra.Command, args []string) {
-			scanner()
+			os.Exit(run(scanner))
 		},
 	}
 
 	cmd.Flags().StringVarP(&zapAddr, "zap-proxy", "p", "http://127.0.0.1:8080", "Zap proxy address")
 	cmd.Flags().StringVarP(&target, "target", "t", "http://127.0.0.1:8090/target", "Target address")
 	cmd.Flags().StringVarP(&apiKey, "apikey", "a", os.Getenv("ZAPAPIKEY"), "Zap api key")
-	cmd.Flags().BoolVarP(&serve, "serve", "s", false, "serve results")
+	cmd.Flags().BoolVar(&ajaxSpider, "ajax-spider", false, "Run the AJAX spider after the traditional spider")
+	addRunIDFlags(cmd)
+	addAuthFlags(cmd)
+	addSidecarFlags(cmd)
+	addScopeFlags(cmd)
+	addScanPolicyFlags(cmd)
+	addScriptFlags(cmd)
+	addTimeoutFlags(cmd)
+	addThresholdFlags(cmd)
 
 	return cmd
 }
@@ -56,7 +62,10 @@ func NewApiScannerCmd() *cobra.Command {
 		Use:   "apiscan",
 		Short: "API scanner application using Zap",
 		Run: func(cmd *cobra.Command, args []string) {
-			apiScanner()
+			if len(scripts) == 0 {
+				scripts = defaultAPIScripts
+			}
+			os.Exit(run(apiScanner))
 		},
 	}
 
@@ -64,7 +73,14 @@ func NewApiScannerCmd() *cobra.Command {
 	cmd.Flags().StringVarP(&zapAddr, "zap-proxy", "p", "http://127.0.0.1:8080", "Zap proxy address")
 	cmd.Flags().StringVarP(&target, "target", "t", "http://127.0.0.1:8090/target", "Target address")
 	cmd.Flags().StringVarP(&apiKey, "apikey", "a", os.Getenv("ZAPAPIKEY"), "Zap api key")
-	cmd.Flags().BoolVarP(&serve, "serve", "s", false, "serve results")
+	addRunIDFlags(cmd)
+	addAuthFlags(cmd)
+	addSidecarFlags(cmd)
+	addScopeFlags(cmd)
+	addScanPolicyFlags(cmd)
+	addScriptFlags(cmd)
+	addTimeoutFlags(cmd)
+	addThresholdFlags(cmd)
 
 	return cmd
 }
@@ -75,131 +91,265 @@ func init() {
 
 }
 
-func scanner() {
-	cfg := &zap.Config{
+// run sets up the scan in ZAP, runs it and evaluates the results. It returns the exit code of the analyzer.
+// The configuration added to ZAP by the scan is removed before returning.
+func run(scan func(ctx context.Context, client zap.Interface, zapCtx *zapContext) (bool, error)) int {
+	ctx, cancel := scanContext()
+	defer cancel()
+
+	if runID == "" {
+		runID, _ = os.Hostname()
+	}
+	client, err := zap.NewClient(&zap.Config{
 		Proxy:  zapAddr,
 		APIKey: apiKey,
-	}
-	client, err := zap.NewClient(cfg)
+	})
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		return exitCodeError
 	}
-
-	// Start spidering the target
-	fmt.Println("Spider : " + target)
-	resp, err := client.Spider().Scan(target, "", "", "", "")
-	if err != nil {
-		log.Fatal(err)
+	defer stopSidecar(client)
+	if err := waitForSidecar(ctx, client); err != nil {
+		log.Print(err)
+		return exitCodeError
 	}
 
-	// The scan now returns a scan id to support concurrent scanning
-	scanid := resp["scan"].(string)
-	for {
-		time.Sleep(1000 * time.Millisecond)
-		resp, _ = client.Spider().Status(scanid)
-		progress, _ := strconv.Atoi(resp["status"].(string))
-		if progress >= 100 {
-			break
-		}
+	var cleanup teardown
+	// cleanup is filled by setupScan, so it has to be evaluated when run returns
+	defer func() { cleanup.run() }()
+	zapCtx, err := setupScan(ctx, client, &cleanup)
+	if err != nil {
+		log.Print(err)
+		return exitCodeError
 	}
-	fmt.Println("Spider complete")
-
-	// Give the passive scanner a chance to complete
-	time.Sleep(2000 * time.Millisecond)
-
-	fmt.Println("Active scan : " + target)
-	resp, err = client.Ascan().Scan(target, "True", "False", "", "", "", "")
+	after, err := lastAlertID(client)
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		return exitCodeError
 	}
-	// The scan now returns a scan id to support concurrent scanning
-	scanid = resp["scan"].(string)
-	for {
-		time.Sleep(5000 * time.Millisecond)
-		resp, _ = client.Ascan().Status(scanid)
-		progress, _ := strconv.Atoi(resp["status"].(string))
-		fmt.Printf("Active Scan progress : %d\n", progress)
-		if progress >= 100 {
-			break
-		}
+	timedOut, err := scan(ctx, client, zapCtx)
+	if err != nil {
+		log.Print(err)
+		return exitCodeError
 	}
-	fmt.Println("Active Scan complete")
-	fmt.Println("Alerts:")
-	alerts, err := client.Core().Alerts(target, "", "", "")
+
+	summary, err := printResults(client, after)
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		return exitCodeError
 	}
-	summary, err := client.Core().AlertsSummary(target)
+	exceeded, err := evaluateResults(summary, after)
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		return exitCodeError
 	}
-	fmt.Printf("alerts: %v", alerts)
-	fmt.Printf("summary: %v", summary)
-	jsonString, err := json.Marshal(alerts)
+	return exitCode(ctx, timedOut, exceeded)
+}
+
+// scanner spiders and actively scans the target, it reports whether a stage was stopped by a timeout
+func scanner(ctx context.Context, client zap.Interface, zapCtx *zapContext) (bool, error) {
+	timedOut := false
+
+	// Start spidering the target
+	fmt.Println("Spider : " + target)
+	// The scan now returns a scan id to support concurrent scanning
+	scanid, err := scanID(spider(client, zapCtx))
 	if err != nil {
-		log.Fatal(err)
+		return timedOut, err
 	}
-	if serve {
-		serveResults(jsonString)
+	err = waitFor(ctx, "Spider", spiderTimeout, 1000*time.Millisecond, func() (int, error) {
+		return scanProgress(client.Spider().Status(scanid))
+	})
+	if err := stopOnTimeout(err, "Spider", &timedOut, func() error {
+		return zapError(client.Spider().Stop(scanid))
+	}); err != nil {
+		return timedOut, err
 	}
-}
+	fmt.Println("Spider complete")
 
-func apiScanner() {
-	cfg := &zap.Config{
-		Proxy:  zapAddr,
-		APIKey: apiKey,
+	if ajaxSpider && ctx.Err() == nil {
+		fmt.Println("AJAX Spider : " + target)
+		if err := zapError(ajaxSpiderScan(client, zapCtx)); err != nil {
+			return timedOut, err
+		}
+		err = waitFor(ctx, "AJAX Spider", ajaxSpiderTimeout, 1000*time.Millisecond, func() (int, error) {
+			resp, err := zapRequest(client, "ajaxSpider/view/status/", nil)
+			if err := zapError(resp, err); err != nil {
+				return 0, err
+			}
+			if resp["status"] == "running" {
+				return 0, nil
+			}
+			return 100, nil
+		})
+		if err := stopOnTimeout(err, "AJAX Spider", &timedOut, func() error {
+			return zapError(zapRequest(client, "ajaxSpider/action/stop/", nil))
+		}); err != nil {
+			return timedOut, err
+		}
+		fmt.Println("AJAX Spider complete")
 	}
-	client, err := zap.NewClient(cfg)
-	if err != nil {
-		log.Fatal(err)
+
+	// Give the passive scanner a chance to complete
+	time.Sleep(2000 * time.Millisecond)
+
+	if ctx.Err() == nil {
+		fmt.Println("Active scan : " + target)
+		scanid, err = scanID(activeScan(client, zapCtx))
+		if err != nil {
+			return timedOut, err
+		}
+		err = waitFor(ctx, "Active Scan", activeScanTimeout, 5000*time.Millisecond, func() (int, error) {
+			return scanProgress(client.Ascan().Status(scanid))
+		})
+		if err := stopOnTimeout(err, "Active Scan", &timedOut, func() error {
+			return zapError(client.Ascan().Stop(scanid))
+		}); err != nil {
+			return timedOut, err
+		}
+		fmt.Println("Active Scan complete")
 	}
+	return timedOut, nil
+}
 
-	// Enable scripts
-	fmt.Println("Loading scripsts...")
-	client.Script().Load("Alert_on_HTTP_Response_Code_Errors.js", "httpsender", "Oracle Nashorn", "/home/zap/.ZAP_D/scripts/scripts/httpsender/Alert_on_HTTP_Response_Code_Errors.js", "", "")
-	client.Script().Enable("Alert_on_HTTP_Response_Code_Errors.js")
-	client.Script().Load("Alert_on_Unexpected_Content_Types.js", "httpsender", "Oracle Nashorn", "/home/zap/.ZAP_D/scripts/scripts/httpsender/Alert_on_Unexpected_Content_Types.js", "", "")
-	client.Script().Enable("Alert_on_Unexpected_Content_Types.js")
+// apiScanner imports the OpenAPI definition and actively scans the target, it reports whether the scan was stopped by a timeout
+func apiScanner(ctx context.Context, client zap.Interface, zapCtx *zapContext) (bool, error) {
+	timedOut := false
 
 	fmt.Println("Importing openapi URL...")
-	_, err = client.Openapi().ImportUrl(openapiURL, target)
-	if err != nil {
-		log.Fatal(err)
+	if err := zapError(client.Openapi().ImportUrl(openapiURL, target)); err != nil {
+		return timedOut, err
 	}
 	urls, err := client.Core().Urls(target)
 	if err != nil {
-		log.Fatal(err)
+		return timedOut, err
 	}
 
 	if len(urls) == 0 {
 		log.Print("Failed to import any URLs")
 	}
 
-	resp, err := client.Ascan().Scan(target, "True", "False", "", "", "", "")
+	// The scan now returns a scan id to support concurrent scanning
+	scanid, err := scanID(activeScan(client, zapCtx))
 	if err != nil {
-		log.Fatal(err)
+		return timedOut, err
 	}
-	// The scan now returns a scan id to support concurrent scanning
-	scanid := resp["scan"].(string)
-	for {
-		time.Sleep(5000 * time.Millisecond)
-		resp, _ = client.Ascan().Status(scanid)
-		progress, _ := strconv.Atoi(resp["status"].(string))
-		fmt.Printf("Active API Scan progress : %d\n", progress)
-		if progress >= 100 {
-			break
-		}
+	err = waitFor(ctx, "Active API Scan", activeScanTimeout, 5000*time.Millisecond, func() (int, error) {
+		return scanProgress(client.Ascan().Status(scanid))
+	})
+	if err := stopOnTimeout(err, "Active API Scan", &timedOut, func() error {
+		return zapError(client.Ascan().Stop(scanid))
+	}); err != nil {
+		return timedOut, err
 	}
 	fmt.Println("Active API Scan complete")
-	fmt.Println("Alerts:")
-	alerts, err := client.Core().Alerts(target, "", "", "")
+	return timedOut, nil
+}
+
+// setupScan configures context, authentication, scan policy and scripts.
+// The per-scan configuration is registered in cleanup.
+func setupScan(ctx context.Context, client zap.Interface, cleanup *teardown) (*zapContext, error) {
+	zapCtx, err := setupContext(client, cleanup)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if err := setupAuthentication(ctx, client, zapCtx, cleanup); err != nil {
+		return nil, err
+	}
+	if err := setupScanPolicy(client, cleanup); err != nil {
+		return nil, err
+	}
+	if err := loadScripts(client, cleanup); err != nil {
+		return nil, err
+	}
+	return zapCtx, nil
+}
+
+// stopOnTimeout stops the stage if it was interrupted by a timeout or a signal.
+// Other errors are returned.
+func stopOnTimeout(err error, stage string, timedOut *bool, stop func() error) error {
+	if err == nil {
+		return nil
+	}
+	if err != errStageTimeout && err != context.DeadlineExceeded && err != context.Canceled {
+		return err
+	}
+	fmt.Printf("%s stopped: %v\n", stage, err)
+	*timedOut = true
+	if err := stop(); err != nil {
+		fmt.Printf("failed to stop %s: %v\n", stage, err)
 	}
-	summary, err := client.Core().AlertsSummary(target)
+	return nil
+}
+
+// printResults prints the alerts raised by the scan and returns their number per risk level
+func printResults(client zap.Interface, after int) (map[string]int, error) {
+	fmt.Println("Alerts:")
+	alerts, err := scanAlerts(client, after)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	if err := writeAlerts(alerts); err != nil {
+		return nil, err
+	}
+	summary := summarize(alerts)
 	fmt.Printf("alerts: %v", alerts)
 	fmt.Printf("summary: %v", summary)
+	return summary, nil
+}
+
+// evaluateResults checks the --fail-on thresholds and reports whether they are exceeded
+func evaluateResults(summary map[string]int, after int) (bool, error) {
+	message, err := checkThresholds(summary)
+	if err != nil {
+		return false, err
+	}
+	if message != "" {
+		fmt.Println("\nScan failed, " + message)
+	} else {
+		fmt.Println("\nScan passed")
+	}
+	writeTerminationMessage(summary, message, after)
+	return message != "", nil
+}
+
+func spider(client zap.Interface, zapCtx *zapContext) (map[string]interface{}, error) {
+	switch {
+	case zapCtx == nil:
+		return client.Spider().Scan(target, "", "", "", "")
+	case zapCtx.userID != "":
+		return client.Spider().ScanAsUser(zapCtx.id, zapCtx.userID, target, "", "", "")
+	default:
+		return client.Spider().Scan(target, "", "", zapCtx.name, "")
+	}
+}
+
+func ajaxSpiderScan(client zap.Interface, zapCtx *zapContext) (map[string]interface{}, error) {
+	switch {
+	case zapCtx == nil:
+		return zapRequest(client, "ajaxSpider/action/scan/", map[string]string{"url": target})
+	case zapCtx.userID != "":
+		return zapRequest(client, "ajaxSpider/action/scanAsUser/", map[string]string{
+			"contextName": zapCtx.name,
+			"userName":    userName,
+			"url":         target,
+		})
+	default:
+		return zapRequest(client, "ajaxSpider/action/scan/", map[string]string{
+			"url":         target,
+			"inScope":     "true",
+			"contextName": zapCtx.name,
+		})
+	}
+}
+
+func activeScan(client zap.Interface, zapCtx *zapContext) (map[string]interface{}, error) {
+	switch {
+	case zapCtx == nil:
+		return client.Ascan().Scan(target, "True", "False", activeScanPolicy, "", "", "")
+	case zapCtx.userID != "":
+		return client.Ascan().ScanAsUser(target, zapCtx.id, zapCtx.userID, "True", activeScanPolicy, "", "")
+	default:
+		return client.Ascan().Scan(target, "True", "True", activeScanPolicy, "", "", zapCtx.id)
+	}
 }
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+func TestRescan(t *testing.T) {
+	_, fake := newFakeZAP(t, map[string][]string{
+		// the second run finds the context of the run left over, like after an interrupted attempt
+		"context/action/removeContext/": {`{"code":"context_not_found","message":"Context Not Found"}`, `{"Result":"OK"}`},
+		"context/action/newContext/":    {`{"contextId":"1"}`, `{"contextId":"2"}`},
+		"core/view/numberOfAlerts/":     {`{"numberOfAlerts":"0"}`, `{"numberOfAlerts":"2"}`},
+		"core/view/alerts/": {
+			// alerts of the first run
+			`{"alerts":[
+				{"id":"0","risk":"High","url":"http://app.default.svc.cluster.local:80/"},
+				{"id":"1","risk":"Medium","url":"http://app.default.svc.cluster.local:80/search"}
+			]}`,
+			// latest alert before the second run
+			`{"alerts":[{"id":"1","risk":"Medium","url":"http://app.default.svc.cluster.local:80/search"}]}`,
+			// alerts of both runs, the vulnerabilities of the first run are fixed
+			`{"alerts":[
+				{"id":"0","risk":"High","url":"http://app.default.svc.cluster.local:80/"},
+				{"id":"1","risk":"Medium","url":"http://app.default.svc.cluster.local:80/search"},
+				{"id":"2","risk":"Low","url":"http://app.default.svc.cluster.local:80/"}
+			]}`,
+		},
+	})
+	defer fake.close()
+	zapAddr, target, failOn, runID = fake.server.URL, "http://app.default.svc.cluster.local:80", "high=0,medium=0", "app-1"
+	excludeRegexes = []string{".*logout.*"}
+	defer func() { zapAddr, target, failOn, runID, excludeRegexes = "", "", "", "", nil }()
+
+	var contexts []string
+	scan := func(ctx context.Context, client zap.Interface, zapCtx *zapContext) (bool, error) {
+		contexts = append(contexts, zapCtx.id)
+		return false, nil
+	}
+	if code := run(scan); code != exitCodeThresholdExceeded {
+		t.Errorf("first run exited with %d", code)
+	}
+	if code := run(scan); code != 0 {
+		t.Errorf("second run with fresh results exited with %d", code)
+	}
+	if len(contexts) != 2 || contexts[0] != "1" || contexts[1] != "2" {
+		t.Errorf("unexpected contexts of the runs %v", contexts)
+	}
+	if removed := fake.called("context/action/removeContext/"); len(removed) != 4 {
+		t.Errorf("expected the context to be removed before and after both runs, got %v", removed)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+var scripts []string
+
+// defaultAPIScripts are loaded by the API scanner if no script is defined
+var defaultAPIScripts = []string{
+	"Alert_on_HTTP_Response_Code_Errors.js,httpsender,Oracle Nashorn,/home/zap/.ZAP_D/scripts/scripts/httpsender/Alert_on_HTTP_Response_Code_Errors.js",
+	"Alert_on_Unexpected_Content_Types.js,httpsender,Oracle Nashorn,/home/zap/.ZAP_D/scripts/scripts/httpsender/Alert_on_Unexpected_Content_Types.js",
+}
+
+func addScriptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&scripts, "script", nil, "Script to load in name,type,engine,file format, can be repeated")
+}
+
+// loadScripts loads and enables the scripts in ZAP. The scripts are named after the scan run,
+// a script left by a previous attempt of the run is replaced, and they are removed by cleanup.
+func loadScripts(client zap.Interface, cleanup *teardown) error {
+	if len(scripts) > 0 {
+		fmt.Println("Loading scripts...")
+	}
+	for _, script := range scripts {
+		fields := strings.SplitN(script, ",", 4)
+		if len(fields) != 4 {
+			return fmt.Errorf("invalid script definition: %s", script)
+		}
+		name, scriptType, engine, file := runName("script")+"-"+fields[0], fields[1], fields[2], fields[3]
+
+		if err := zapError(client.Script().Remove(name)); err != nil && !hasCode(err, "does_not_exist") {
+			return fmt.Errorf("failed to remove stale script %s: %v", name, err)
+		}
+		if err := zapError(client.Script().Load(name, scriptType, engine, file, "", "")); err != nil {
+			return fmt.Errorf("failed to load script %s from %s: %v", name, file, err)
+		}
+		cleanup.add("script "+name, func() error {
+			return zapError(client.Script().Remove(name))
+		})
+		if err := zapError(client.Script().Enable(name)); err != nil {
+			return fmt.Errorf("failed to enable script %s: %v", name, err)
+		}
+		fmt.Printf("Script loaded: %s (%s)\n", name, scriptType)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestLoadScripts(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"script/action/remove/": {`{"code":"does_not_exist","message":"Does Not Exist"}`, `{"Result":"OK"}`},
+		"script/action/load/":   {`{"Result":"OK"}`},
+		"script/action/enable/": {`{"Result":"OK"}`},
+	})
+	defer fake.close()
+	scripts = []string{"errors.js,httpsender,Oracle Nashorn,/scripts/errors.js"}
+	defer func() { scripts, runID = nil, "" }()
+
+	// the same ZAP instance is used by consecutive scans
+	for _, run := range []string{"app-1", "app-2"} {
+		runID = run
+		var cleanup teardown
+		if err := loadScripts(client, &cleanup); err != nil {
+			t.Fatalf("run %s: %v", run, err)
+		}
+		cleanup.run()
+	}
+
+	loaded := fake.called("script/action/load/")
+	if len(loaded) != 2 || loaded[0].Get("scriptName") != "dast-script-app-1-errors.js" || loaded[1].Get("scriptName") != "dast-script-app-2-errors.js" {
+		t.Errorf("unexpected loaded scripts: %v", loaded)
+	}
+	if enabled := fake.called("script/action/enable/"); len(enabled) != 2 || enabled[1].Get("scriptName") != "dast-script-app-2-errors.js" {
+		t.Errorf("unexpected enabled scripts: %v", enabled)
+	}
+	var removed []string
+	for _, call := range fake.called("script/action/remove/") {
+		removed = append(removed, call.Get("scriptName"))
+	}
+	expected := []string{"dast-script-app-1-errors.js", "dast-script-app-1-errors.js", "dast-script-app-2-errors.js", "dast-script-app-2-errors.js"}
+	if len(removed) != len(expected) {
+		t.Fatalf("expected removed scripts %v, got %v", expected, removed)
+	}
+	for i := range expected {
+		if removed[i] != expected[i] {
+			t.Errorf("expected removed scripts %v, got %v", expected, removed)
+		}
+	}
+}
+
+func TestLoadScriptsError(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"script/action/remove/": {`{"code":"does_not_exist","message":"Does Not Exist"}`},
+		"script/action/load/":   {`{"code":"bad_external_data","message":"Bad External Data"}`},
+	})
+	defer fake.close()
+	scripts, runID = []string{"errors.js,httpsender,Oracle Nashorn,/scripts/missing.js"}, "app-1"
+	defer func() { scripts, runID = nil, "" }()
+
+	var cleanup teardown
+	if err := loadScripts(client, &cleanup); err == nil {
+		t.Error("failed load isn't reported")
+	}
+	if len(cleanup) != 0 {
+		t.Error("cleanup is registered for a script that isn't loaded")
+	}
+}
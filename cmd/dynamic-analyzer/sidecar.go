@@ -0,0 +1,102 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+const (
+	zapStartTimeout = 5 * time.Minute
+	// alertsLogPrefix marks the log line of the alerts of the run, it has to match results.AlertsLogPrefix of the operator
+	alertsLogPrefix = "dast-alerts: "
+)
+
+// alertsLog is where the alerts of the run are written to
+var alertsLog io.Writer = os.Stdout
+
+// zapStartInterval is the interval of polling the ZAP sidecar until it's started
+var zapStartInterval = 5 * time.Second
+
+var sidecar bool
+
+func addSidecarFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&sidecar, "sidecar", false, "ZAP is a sidecar of the analyzer, it's waited for before the scan and shut down after it")
+}
+
+// waitForSidecar waits until the ZAP sidecar answers API requests
+func waitForSidecar(ctx context.Context, client zap.Interface) error {
+	if !sidecar {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, zapStartTimeout)
+	defer cancel()
+	for {
+		err := zapError(client.Core().Version())
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("ZAP sidecar isn't started: %v", err)
+		case <-time.After(zapStartInterval):
+		}
+	}
+}
+
+// stopSidecar shuts down the ZAP sidecar, so the pod of the analyzer job completes
+func stopSidecar(client zap.Interface) {
+	if !sidecar {
+		return
+	}
+	if err := zapError(client.Core().Shutdown()); err != nil {
+		fmt.Printf("failed to shut down ZAP sidecar: %v\n", err)
+	}
+}
+
+// writeAlerts writes the alerts of the run to the log, if ZAP is a sidecar.
+// The sidecar is shut down with the alerts after the scan, so the operator reads them from the log of the analyzer.
+func writeAlerts(alerts []map[string]interface{}) error {
+	if !sidecar {
+		return nil
+	}
+	content, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alerts: %v", err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("failed to compress alerts: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to compress alerts: %v", err)
+	}
+	_, err = fmt.Fprintf(alertsLog, "\n%s%s\n", alertsLogPrefix, base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return err
+}
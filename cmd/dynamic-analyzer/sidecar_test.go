@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSidecar(t *testing.T) {
+	client, fake := newFakeZAP(t, map[string][]string{
+		"core/view/version/": {`{"code":"bad_view","message":"starting"}`, `{"version":"2.10.0"}`},
+	})
+	defer fake.close()
+	sidecar, zapStartInterval = true, time.Millisecond
+	defer func() { sidecar, zapStartInterval = false, 5*time.Second }()
+
+	if err := waitForSidecar(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.called("core/view/version/"); len(calls) != 2 {
+		t.Errorf("sidecar is polled %d times", len(calls))
+	}
+	stopSidecar(client)
+	if calls := fake.called("core/action/shutdown/"); len(calls) != 1 {
+		t.Errorf("sidecar is shut down %d times", len(calls))
+	}
+}
+
+func TestWriteAlerts(t *testing.T) {
+	var log bytes.Buffer
+	alertsLog = &log
+	defer func() { alertsLog, sidecar = os.Stdout, false }()
+	alerts := []map[string]interface{}{{"id": "3", "risk": "High", "url": "http://shop.example.com/"}}
+
+	if err := writeAlerts(alerts); err != nil || log.Len() != 0 {
+		t.Errorf("alerts of the shared ZAP are written: %q %v", log.String(), err)
+	}
+
+	sidecar = true
+	if err := writeAlerts(alerts); err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSpace(log.String())
+	if !strings.HasPrefix(line, alertsLogPrefix) {
+		t.Fatalf("unexpected log %q", line)
+	}
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, alertsLogPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []map[string]interface{}
+	if err := json.Unmarshal(content, &written); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 1 || written[0]["id"] != "3" {
+		t.Errorf("unexpected written alerts %v", written)
+	}
+}
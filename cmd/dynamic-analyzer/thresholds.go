@@ -0,0 +1,111 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// exitCodeThresholdExceeded is returned when the alerts exceed the --fail-on thresholds
+	exitCodeThresholdExceeded = 2
+
+	terminationLogPath = "/dev/termination-log"
+)
+
+var failOn string
+
+// riskLevels maps the accepted threshold names to ZAP risk levels
+var riskLevels = map[string]string{
+	"high":          "High",
+	"medium":        "Medium",
+	"low":           "Low",
+	"informational": "Informational",
+}
+
+func addThresholdFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&failOn, "fail-on", "", "Alert thresholds failing the scan, e.g. high=0,medium=5")
+}
+
+func parseThresholds(value string) (map[string]int, error) {
+	thresholds := map[string]int{}
+	if value == "" {
+		return thresholds, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid threshold: %s", item)
+		}
+		risk, ok := riskLevels[strings.ToLower(kv[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown risk level: %s", kv[0])
+		}
+		max, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold for %s: %v", kv[0], err)
+		}
+		thresholds[risk] = max
+	}
+	return thresholds, nil
+}
+
+// checkThresholds compares the alerts summary with the --fail-on thresholds.
+// It returns a message describing the exceeded thresholds, or an empty string.
+func checkThresholds(summary map[string]int) (string, error) {
+	thresholds, err := parseThresholds(failOn)
+	if err != nil {
+		return "", err
+	}
+
+	var exceeded []string
+	for _, risk := range []string{"High", "Medium", "Low", "Informational"} {
+		max, ok := thresholds[risk]
+		if !ok {
+			continue
+		}
+		if count := summary[risk]; count > max {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d > %d", risk, count, max))
+		}
+	}
+	if len(exceeded) == 0 {
+		return "", nil
+	}
+	return "alerts above threshold: " + strings.Join(exceeded, ", "), nil
+}
+
+// writeTerminationMessage reports the summary of the scan to Kubernetes.
+// The operator fetches the alerts raised after the alertsAfter alert id as the alerts of the scan.
+func writeTerminationMessage(summary map[string]int, message string, after int) {
+	result := map[string]interface{}{
+		"alertsSummary": summary,
+		"message":       message,
+		"alertsAfter":   after,
+	}
+	content, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	// the file only exists when running in a pod
+	_ = ioutil.WriteFile(terminationLogPath, content, 0644)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+// fakeZAP serves the ZAP API as an HTTP proxy and records the API calls
+type fakeZAP struct {
+	mu     sync.Mutex
+	server *httptest.Server
+	// responses are returned in order for the API path, the last one is repeated
+	responses map[string][]string
+	calls     []apiCall
+}
+
+type apiCall struct {
+	path  string
+	query url.Values
+}
+
+// newFakeZAP starts a fake ZAP, it has to be closed by the test
+func newFakeZAP(t *testing.T, responses map[string][]string) (zap.Interface, *fakeZAP) {
+	fake := &fakeZAP{responses: responses}
+	fake.server = httptest.NewServer(fake)
+	client, err := zap.NewClient(&zap.Config{Proxy: fake.server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, fake
+}
+
+func (f *fakeZAP) close() {
+	f.server.Close()
+}
+
+func (f *fakeZAP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/JSON/")
+	f.calls = append(f.calls, apiCall{path: path, query: r.URL.Query()})
+	response := `{"Result":"OK"}`
+	if responses := f.responses[path]; len(responses) > 0 {
+		response = responses[0]
+		if len(responses) > 1 {
+			f.responses[path] = responses[1:]
+		}
+	}
+	fmt.Fprint(w, response)
+}
+
+// called returns the calls of the API path
+func (f *fakeZAP) called(path string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []url.Values
+	for _, call := range f.calls {
+		if call.path == path {
+			calls = append(calls, call.query)
+		}
+	}
+	return calls
+}
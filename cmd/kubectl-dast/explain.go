@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	"github.com/banzaicloud/dast-operator/webhooks"
+)
+
+var filename string
+var operatorNamespace string
+var user string
+var groups []string
+
+func NewExplainCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain ingress NAME | explain -f FILE",
+		Short: "Explain whether the webhooks would admit an ingress",
+		Long: `Evaluate the scan results of the backend services of an ingress the same way as the validating webhooks do on create.
+It's a dry-run, nothing is changed in the cluster. The config of the webhooks is read from the config published by the operator.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return explain(cmd.Context(), args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&filename, "filename", "f", "", "Manifest of the explained object, which doesn't have to exist in the cluster")
+	cmd.Flags().StringVar(&operatorNamespace, "operator-namespace", "", "Namespace of the operator, whose webhook config is used. It's looked up in every namespace if not set")
+	cmd.Flags().StringVar(&user, "user", "", "User creating the object, break-glass overrides are authorized for this user")
+	cmd.Flags().StringSliceVar(&groups, "group", nil, "Groups of the user creating the object")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(NewExplainCmd())
+}
+
+func explain(ctx context.Context, args []string) error {
+	s, err := newSession()
+	if err != nil {
+		return err
+	}
+	obj, err := explainedObject(ctx, s, args)
+	if err != nil {
+		return err
+	}
+
+	config := webhooks.ValidatorConfig{
+		Client: s.client,
+		Store:  s.store,
+		Log:    log.NullLogger{},
+	}
+	if err := webhooks.LoadConfig(ctx, s.client, operatorNamespace, &config); err != nil {
+		return err
+	}
+
+	explanation := webhooks.Explain(ctx, config, obj, authenticationv1.UserInfo{Username: user, Groups: groups})
+	decision := "denied"
+	if explanation.Allowed {
+		decision = "allowed"
+	}
+	fmt.Printf("%s %s/%s would be %s (enforcement mode %s)\n", obj.GetKind(), obj.GetNamespace(), obj.GetName(), decision, explanation.Mode)
+	if explanation.Message != "" {
+		fmt.Printf("Reason: %s\n", explanation.Message)
+	}
+	for _, warning := range explanation.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+	if len(explanation.Backends) > 0 {
+		fmt.Println("Backend services:")
+		for _, backend := range explanation.Backends {
+			namespace := backend["namespace"]
+			if namespace == "" {
+				namespace = obj.GetNamespace()
+			}
+			fmt.Printf("  %s/%s:%s\n", namespace, backend["name"], backend["port"])
+		}
+	}
+	return nil
+}
+
+// explainedObject reads the object from the manifest file, or gets the ingress from the cluster
+func explainedObject(ctx context.Context, s *session, args []string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	if filename != "" {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to read %s", filename)
+		}
+		if err := yaml.Unmarshal(content, &obj.Object); err != nil {
+			return nil, errors.WrapIff(err, "failed to parse %s", filename)
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(s.namespace)
+		}
+		return obj, nil
+	}
+
+	if len(args) != 2 || !strings.HasPrefix(strings.ToLower(args[0]), "ing") {
+		return nil, errors.New("explain requires ingress NAME or a manifest file")
+	}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"})
+	if err := s.client.Get(ctx, types.NamespacedName{Name: args[1], Namespace: s.namespace}, obj); err != nil {
+		return nil, errors.WrapIff(err, "failed to get ingress %s/%s", s.namespace, args[1])
+	}
+	return obj, nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+var scanID string
+var port int32
+var risk string
+var newOnly bool
+
+func NewFindingsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "findings SERVICE",
+		Short: "Show the findings of the latest scans of the ports of a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showFindings(cmd.Context(), args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&scanID, "scan", "", "ID of the scan, the latest scans if not set")
+	cmd.Flags().Int32Var(&port, "port", 0, "Port of the service, every scanned port if not set")
+	cmd.Flags().StringVar(&risk, "risk", "", "Show only the findings of the risk level, e.g. High")
+	cmd.Flags().BoolVar(&newOnly, "new", false, "Show only the findings, which weren't reported by the previous scan")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(NewFindingsCmd())
+}
+
+func showFindings(ctx context.Context, service string) error {
+	s, err := newSession()
+	if err != nil {
+		return err
+	}
+	scans, err := s.scans(ctx, service, scanID, port)
+	if err != nil {
+		return err
+	}
+	baseline, err := results.GetBaseline(ctx, s.client, s.namespace, service)
+	if err != nil {
+		return err
+	}
+	suppressed := map[string]bool{}
+	if baseline != nil {
+		for _, alert := range baseline.Spec.Alerts {
+			suppressed[results.Alert{PluginID: alert.PluginID, URL: alert.URL, Method: alert.Method, Param: alert.Param}.Key()] = true
+		}
+	}
+
+	for i, scan := range scans {
+		if i > 0 {
+			fmt.Println()
+		}
+		alerts := scan.Alerts
+		if newOnly {
+			alerts = nil
+			if scan.Diff != nil {
+				alerts = scan.Diff.New
+			}
+		}
+
+		fmt.Printf("Scan %s of %s/%s: %s\n", scan.ID, scan.Namespace, subject(scan), scan.Target)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "RISK\tPLUGIN\tNAME\tMETHOD\tURL\tPARAM\tSUPPRESSED")
+		for _, alert := range alerts {
+			if risk != "" && !strings.EqualFold(alert.Risk, risk) {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n", alert.Risk, alert.PluginID, alert.Name, alert.Method, alert.URL, alert.Param, suppressed[alert.Key()])
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -16,20 +16,6 @@ limitations under the License.
 
 package main
 
-import (
-	"net/http"
-)
-
-type scanResult struct {
-	result []byte
-}
-
-func serveResults(result []byte) {
-	scanResult := &scanResult{result: result}
-	http.ListenAndServe(":3000", scanResult)
-}
-
-func (res *scanResult) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Write(res.result)
-
+func main() {
+	execute()
 }
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+var reportFormat string
+var output string
+
+func NewReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report SERVICE",
+		Short: "Download the ZAP report of the latest scan of a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return downloadReport(cmd.Context(), args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&scanID, "scan", "", "ID of the scan, the latest scan if not set")
+	cmd.Flags().Int32Var(&port, "port", 0, "Port of the service, required if several ports are scanned")
+	cmd.Flags().StringVarP(&reportFormat, "format", "f", results.ReportHTML, "Format of the report: html, xml, json or md")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File the report is written to, the standard output if not set")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(NewReportCmd())
+}
+
+func downloadReport(ctx context.Context, service string) error {
+	s, err := newSession()
+	if err != nil {
+		return err
+	}
+	id := scanID
+	if id == "" {
+		scans, err := s.scans(ctx, service, "", port)
+		if err != nil {
+			return err
+		}
+		if len(scans) > 1 {
+			ports := make([]string, 0, len(scans))
+			for _, scan := range scans {
+				ports = append(ports, strconv.Itoa(int(scan.Port)))
+			}
+			return errors.Errorf("service %s/%s has scans of ports %s, select one with --port", s.namespace, service, strings.Join(ports, ", "))
+		}
+		id = scans[0].ID
+	}
+	report, err := s.store.Report(ctx, s.namespace, service, id, reportFormat)
+	if err != nil {
+		return errors.WrapIff(err, "failed to get the %s report of scan %s", reportFormat, id)
+	}
+
+	if output == "" {
+		_, err := os.Stdout.Write(report)
+		return err
+	}
+	if err := ioutil.WriteFile(output, report, 0644); err != nil {
+		return errors.WrapIff(err, "failed to write report to %s", output)
+	}
+	fmt.Fprintf(os.Stderr, "%s report of scan %s written to %s\n", reportFormat, id, output)
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+)
+
+func NewRescanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rescan [dast|service|ingress]/NAME",
+		Short: "Start a new run of the scan of a Dast, service or ingress",
+		Long:  `Start a new run of the scan by setting the rescan annotation to the current time, the kind is service if it isn't set`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rescan(cmd.Context(), args[0])
+		},
+	}
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(NewRescanCmd())
+}
+
+func rescan(ctx context.Context, arg string) error {
+	s, err := newSession()
+	if err != nil {
+		return err
+	}
+
+	kind, name := "service", arg
+	if parts := strings.SplitN(arg, "/", 2); len(parts) == 2 {
+		kind, name = strings.ToLower(parts[0]), parts[1]
+	}
+	var obj interface {
+		runtime.Object
+		metav1.Object
+	}
+	switch kind {
+	case "dast", "dasts":
+		obj = &securityv1alpha1.Dast{}
+	case "service", "services", "svc":
+		obj = &corev1.Service{}
+	case "ingress", "ingresses", "ing":
+		obj = &networkingv1.Ingress{}
+	default:
+		return errors.Errorf("unsupported kind %q, must be dast, service or ingress", kind)
+	}
+	if err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: s.namespace}, obj); err != nil {
+		return errors.WrapIff(err, "failed to get %s %s/%s", kind, s.namespace, name)
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject())
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[analyzer.RescanAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	obj.SetAnnotations(annotations)
+	if err := s.client.Patch(ctx, obj, patch); err != nil {
+		return errors.WrapIff(err, "failed to annotate %s %s/%s", kind, s.namespace, name)
+	}
+	fmt.Printf("%s %s/%s rescan requested at %s\n", kind, s.namespace, name, annotations[analyzer.RescanAnnotation])
+	return nil
+}
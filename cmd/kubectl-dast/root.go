@@ -0,0 +1,142 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+var kubeconfig string
+var kubeContext string
+var namespace string
+
+var rootCmd = &cobra.Command{
+	Use:          "kubectl-dast",
+	Short:        "Manage the scans of the DAST operator",
+	Long:         `kubectl plugin listing the scans and findings of the DAST operator, triggering rescans, downloading reports, suppressing findings and explaining the decisions of the webhooks`,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "context", "", "Name of the kubeconfig context")
+	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "Namespace, the namespace of the kubeconfig context if not set")
+}
+
+func execute() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// session is the client of the cluster with the namespace of the command
+type session struct {
+	client    client.Client
+	namespace string
+	store     results.Store
+}
+
+// newSession creates a client from the kubeconfig, the results are read from the cluster without cache
+func newSession() (*session, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to load kubeconfig")
+	}
+	ns := namespace
+	if ns == "" {
+		if ns, _, err = clientConfig.Namespace(); err != nil {
+			return nil, errors.WrapIf(err, "failed to get the namespace of the kubeconfig context")
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := securityv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to create client")
+	}
+	return &session{
+		client:    c,
+		namespace: ns,
+		store:     results.NewConfigMapStore(c, c, 0),
+	}, nil
+}
+
+// scans returns the scan of the service with alerts if id is set, the latest scans of the ports of the service otherwise.
+// Only the latest scan of the port is returned if port is set.
+func (s *session) scans(ctx context.Context, service, id string, port int32) ([]*results.Scan, error) {
+	if id != "" {
+		scan, err := s.store.Get(ctx, s.namespace, service, id)
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to get scan %s of service %s/%s", id, s.namespace, service)
+		}
+		return []*results.Scan{scan}, nil
+	}
+	scans, err := results.LatestPerPort(ctx, s.store, s.namespace, service)
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to get the latest scans of service %s/%s", s.namespace, service)
+	}
+	if port != 0 {
+		var ofPort []*results.Scan
+		for _, scan := range scans {
+			if scan.Port == port {
+				ofPort = append(ofPort, scan)
+			}
+		}
+		scans = ofPort
+	}
+	if len(scans) == 0 {
+		return nil, errors.Errorf("service %s/%s has no stored scans%s", s.namespace, service, portSuffix(port))
+	}
+	return scans, nil
+}
+
+// portSuffix describes the port in messages, empty if the port isn't set
+func portSuffix(port int32) string {
+	if port == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" of port %d", port)
+}
+
+// subject describes the scanned service or ingress host of the scan
+func subject(scan *results.Scan) string {
+	if scan.Ingress != "" {
+		return fmt.Sprintf("ingress/%s (%s)", scan.Ingress, scan.Host)
+	}
+	return scan.Service
+}
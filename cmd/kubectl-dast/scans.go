@@ -0,0 +1,137 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+var allNamespaces bool
+var history bool
+
+func NewScansCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scans [SERVICE]",
+		Short: "List the stored scans with status and alert counts",
+		Long:  `List the latest stored scan of every scanned port of the services and of every ingress host, or of the given service`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listScans(cmd.Context(), args)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List the scans of every namespace")
+	cmd.Flags().BoolVar(&history, "history", false, "List every stored scan, not only the latest ones")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(NewScansCmd())
+}
+
+func listScans(ctx context.Context, args []string) error {
+	s, err := newSession()
+	if err != nil {
+		return err
+	}
+
+	var subjects []types.NamespacedName
+	if len(args) == 1 {
+		subjects = append(subjects, types.NamespacedName{Name: args[0], Namespace: s.namespace})
+	} else {
+		opts := []client.ListOption{client.MatchingLabels{results.ScanLabel: "true"}}
+		if !allNamespaces {
+			opts = append(opts, client.InNamespace(s.namespace))
+		}
+		var configMaps corev1.ConfigMapList
+		if err := s.client.List(ctx, &configMaps, opts...); err != nil {
+			return errors.WrapIf(err, "failed to list scans")
+		}
+		seen := map[types.NamespacedName]bool{}
+		for _, configMap := range configMaps.Items {
+			key := types.NamespacedName{Name: configMap.GetLabels()[results.ServiceLabel], Namespace: configMap.GetNamespace()}
+			if !seen[key] {
+				seen[key] = true
+				subjects = append(subjects, key)
+			}
+		}
+		sort.Slice(subjects, func(i, j int) bool {
+			return subjects[i].String() < subjects[j].String()
+		})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tSUBJECT\tPORT\tSCAN\tSTATUS\tHIGH\tMEDIUM\tLOW\tINFORMATIONAL\tNEW HIGH\tCOMPLETED")
+	for _, key := range subjects {
+		scans, err := s.store.List(ctx, key.Namespace, key.Name)
+		if err != nil {
+			return err
+		}
+		if !history {
+			scans = latestPerPort(scans)
+		}
+		for i := range scans {
+			scan := &scans[i]
+			status := "Passed"
+			if !scan.Passed {
+				status = "Failed"
+				if scan.Reason != "" {
+					status = fmt.Sprintf("Failed (%s)", scan.Reason)
+				}
+			}
+			completed := ""
+			if scan.CompletionTime != nil {
+				completed = scan.CompletionTime.Format(time.RFC3339)
+			}
+			port := ""
+			if scan.Port != 0 {
+				port = strconv.Itoa(int(scan.Port))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n", scan.Namespace, subject(scan), port, scan.ID, status,
+				scan.Summary["High"], scan.Summary["Medium"], scan.Summary["Low"], scan.Summary["Informational"], scan.NewHigh, completed)
+		}
+	}
+	return w.Flush()
+}
+
+// latestPerPort keeps the latest scan of every port of the listed scans, which are ordered by completion time
+func latestPerPort(scans []results.Scan) []results.Scan {
+	seen := map[int32]bool{}
+	var latest []results.Scan
+	for _, scan := range scans {
+		if !seen[scan.Port] {
+			seen[scan.Port] = true
+			latest = append(latest, scan)
+		}
+	}
+	return latest
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+// suppressPollInterval is the period of checking whether the operator accepted the suppressed findings
+const suppressPollInterval = time.Second
+
+var pluginID string
+var alertURL string
+var alertParam string
+var suppressWait time.Duration
+
+func NewSuppressCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "suppress SERVICE",
+		Short: "Suppress findings of a service by adding them to its baseline",
+		Long: `Add the findings of the latest scans of the ports of the service matching the filters to the baseline of the service, so they aren't counted by the threshold checks.
+The findings are accepted through the accept-baseline label of the service, which requires the accept verb on dastbaselines.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return suppress(cmd.Context(), args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&scanID, "scan", "", "ID of the scan, the latest scans if not set")
+	cmd.Flags().Int32Var(&port, "port", 0, "Port of the service, every scanned port if not set")
+	cmd.Flags().StringVar(&pluginID, "plugin", "", "Plugin ID of the suppressed findings")
+	cmd.Flags().StringVar(&alertURL, "url", "", "URL of the suppressed findings, every URL if not set")
+	cmd.Flags().StringVar(&alertParam, "param", "", "Parameter of the suppressed findings, every parameter if not set")
+	cmd.Flags().DurationVar(&suppressWait, "wait", 30*time.Second, "Time to wait for the operator to accept the findings, 0 doesn't wait")
+	_ = cmd.MarkFlagRequired("plugin")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(NewSuppressCmd())
+}
+
+func suppress(ctx context.Context, service string) error {
+	s, err := newSession()
+	if err != nil {
+		return err
+	}
+	filter := results.AlertFilter{ScanID: scanID, Port: port, PluginID: pluginID, URL: alertURL, Param: alertParam}
+	return s.suppress(ctx, service, filter, suppressWait)
+}
+
+// suppress asks the operator to add the findings matching the filter to the baseline of the service.
+// The service is labeled for baseline acceptance with the filter, so the baseline webhook authorizes the accept verb and records the accepting user.
+func (s *session) suppress(ctx context.Context, service string, filter results.AlertFilter, wait time.Duration) error {
+	scans, err := s.scans(ctx, service, filter.ScanID, filter.Port)
+	if err != nil {
+		return err
+	}
+	var alerts []results.Alert
+	var ids []string
+	for _, scan := range scans {
+		ids = append(ids, scan.ID)
+		for _, alert := range scan.Alerts {
+			if filter.Matches(alert) {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	if len(alerts) == 0 {
+		return errors.Errorf("scan %s has no findings matching the filters", strings.Join(ids, ","))
+	}
+
+	value, err := json.Marshal(filter)
+	if err != nil {
+		return errors.WrapIf(err, "failed to marshal the filter of the findings")
+	}
+	var svc corev1.Service
+	if err := s.client.Get(ctx, types.NamespacedName{Name: service, Namespace: s.namespace}, &svc); err != nil {
+		return errors.WrapIff(err, "failed to get service %s/%s", s.namespace, service)
+	}
+	patch := client.MergeFrom(svc.DeepCopy())
+	labels := svc.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[results.AcceptBaselineLabel] = "true"
+	svc.SetLabels(labels)
+	annotations := svc.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[results.AcceptAlertsAnnotation] = string(value)
+	svc.SetAnnotations(annotations)
+	if err := s.client.Patch(ctx, &svc, patch); err != nil {
+		return errors.WrapIff(err, "failed to request the suppression on service %s/%s", s.namespace, service)
+	}
+	fmt.Printf("suppression of %d findings requested on service %s/%s\n", len(alerts), s.namespace, service)
+	if wait <= 0 {
+		return nil
+	}
+	return s.waitForBaseline(ctx, service, alerts, wait)
+}
+
+// waitForBaseline waits until the operator processed the acceptance and removed the accept-baseline label,
+// and checks that the suppressed findings are in the baseline
+func (s *session) waitForBaseline(ctx context.Context, service string, alerts []results.Alert, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(suppressPollInterval)
+	defer ticker.Stop()
+	for {
+		var svc corev1.Service
+		if err := s.client.Get(ctx, types.NamespacedName{Name: service, Namespace: s.namespace}, &svc); err != nil {
+			return errors.WrapIff(err, "failed to get service %s/%s", s.namespace, service)
+		}
+		if svc.GetLabels()[results.AcceptBaselineLabel] != "true" {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Errorf("the operator didn't accept the findings in %s, check the events of service %s/%s", timeout, s.namespace, service)
+		case <-ticker.C:
+		}
+	}
+
+	var baseline securityv1alpha1.DastBaseline
+	err := s.client.Get(ctx, types.NamespacedName{Name: service, Namespace: s.namespace}, &baseline)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.WrapIf(err, "failed to get baseline")
+	}
+	accepted := map[string]bool{}
+	for _, alert := range baseline.Spec.Alerts {
+		accepted[results.Alert{PluginID: alert.PluginID, URL: alert.URL, Method: alert.Method, Param: alert.Param}.Key()] = true
+	}
+	for _, alert := range alerts {
+		if !accepted[alert.Key()] {
+			return errors.Errorf("the findings weren't accepted, check the events of service %s/%s", s.namespace, service)
+		}
+	}
+	fmt.Printf("findings suppressed in the baseline %s/%s accepted by %s\n", s.namespace, service, baseline.Spec.AcceptedBy)
+	if baseline.Expired(metav1.Now()) {
+		fmt.Fprintf(os.Stderr, "warning: the baseline expired at %s, it isn't applied\n", baseline.Spec.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
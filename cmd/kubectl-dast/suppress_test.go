@@ -0,0 +1,144 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func newTestSession(t *testing.T, objs ...runtime.Object) *session {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := securityv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+	s := &session{client: c, namespace: "default", store: results.NewConfigMapStore(c, c, 0)}
+
+	now := time.Now()
+	for i, scan := range []*results.Scan{
+		{ID: "80-1", Namespace: "default", Service: "app", Port: 80, Alerts: []results.Alert{
+			{PluginID: "40012", URL: "http://app.default.svc:80/", Param: "q", Risk: results.RiskHigh},
+			{PluginID: "10020", URL: "http://app.default.svc:80/"},
+		}},
+		{ID: "8080-1", Namespace: "default", Service: "app", Port: 8080, Alerts: []results.Alert{
+			{PluginID: "40012", URL: "http://app.default.svc:8080/", Param: "id", Risk: results.RiskHigh},
+		}},
+	} {
+		scan.CompletionTime = &metav1.Time{Time: now.Add(time.Duration(i) * time.Minute)}
+		if err := s.store.Save(context.Background(), scan, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func testService() *corev1.Service {
+	return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+}
+
+func TestSuppress(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSession(t, testService())
+
+	// no matching findings don't request the acceptance
+	if err := s.suppress(ctx, "app", results.AlertFilter{PluginID: "90000"}, 0); err == nil {
+		t.Error("suppression without matching findings is requested")
+	}
+	var service corev1.Service
+	if err := s.client.Get(ctx, client.ObjectKey{Name: "app", Namespace: "default"}, &service); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.Labels[results.AcceptBaselineLabel]; ok {
+		t.Error("service is labeled for acceptance without matching findings")
+	}
+
+	filter := results.AlertFilter{Port: 80, PluginID: "40012"}
+	if err := s.suppress(ctx, "app", filter, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: "app", Namespace: "default"}, &service); err != nil {
+		t.Fatal(err)
+	}
+	if service.Labels[results.AcceptBaselineLabel] != "true" {
+		t.Errorf("service isn't labeled for acceptance: %v", service.Labels)
+	}
+	// the baseline is written by the operator after the webhook authorized the acceptance
+	if _, ok := service.Annotations[results.AcceptedByAnnotation]; ok {
+		t.Error("accepting user is set by the plugin")
+	}
+	parsed, err := results.ParseAlertFilter(service.Annotations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed == nil || *parsed != filter {
+		t.Errorf("unexpected filter %v, expected %v", parsed, filter)
+	}
+	var baselines securityv1alpha1.DastBaselineList
+	if err := s.client.List(ctx, &baselines); err != nil {
+		t.Fatal(err)
+	}
+	if len(baselines.Items) != 0 {
+		t.Errorf("baseline is written by the plugin: %v", baselines.Items)
+	}
+}
+
+func TestWaitForBaseline(t *testing.T) {
+	ctx := context.Background()
+	alerts := []results.Alert{{PluginID: "40012", URL: "http://app.default.svc:80/", Param: "q"}}
+	baseline := &securityv1alpha1.DastBaseline{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: securityv1alpha1.DastBaselineSpec{
+			Service:    "app",
+			AcceptedBy: "alice",
+			Alerts:     []securityv1alpha1.BaselineAlert{{PluginID: "40012", URL: "http://app.default.svc:80/", Param: "q"}},
+		},
+	}
+
+	s := newTestSession(t, testService(), baseline)
+	if err := s.waitForBaseline(ctx, "app", alerts, time.Second); err != nil {
+		t.Errorf("accepted findings are reported as failed: %v", err)
+	}
+
+	// the operator removed the label, but rejected the acceptance
+	s = newTestSession(t, testService())
+	if err := s.waitForBaseline(ctx, "app", alerts, time.Second); err == nil {
+		t.Error("rejected findings are reported as suppressed")
+	}
+
+	// the operator didn't process the acceptance
+	labeled := testService()
+	labeled.Labels = map[string]string{results.AcceptBaselineLabel: "true"}
+	s = newTestSession(t, labeled, baseline)
+	if err := s.waitForBaseline(ctx, "app", alerts, 10*time.Millisecond); err == nil {
+		t.Error("unprocessed acceptance is reported as suppressed")
+	}
+}
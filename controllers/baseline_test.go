@@ -0,0 +1,161 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func TestAcceptBaseline(t *testing.T) {
+	tests := []struct {
+		name       string
+		acceptedBy string
+		accepted   bool
+	}{
+		{name: "authorized", acceptedBy: "admin", accepted: true},
+		{name: "not authorized by the webhook"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   "default",
+				UID:         "uid",
+				Labels:      map[string]string{results.AcceptBaselineLabel: "true"},
+				Annotations: map[string]string{},
+			}}
+			if test.acceptedBy != "" {
+				service.Annotations[results.AcceptedByAnnotation] = test.acceptedBy
+			}
+			c := newFakeClient(t, service.DeepCopy())
+			store := results.NewConfigMapStore(c, c, 0)
+			scan := &results.Scan{ID: "1", Namespace: "default", Service: "app", Alerts: []results.Alert{
+				{PluginID: "10021", Name: "X-Content-Type-Options Header Missing", Risk: "Low", URL: "http://app/"},
+			}}
+			if err := store.Save(ctx, scan, nil); err != nil {
+				t.Fatal(err)
+			}
+			r := &ServiceReconciler{Client: c, Log: zap.New(), Results: store}
+
+			if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, service); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.acceptBaseline(ctx, service, r.Log); err != nil {
+				t.Fatal(err)
+			}
+
+			var baseline securityv1alpha1.DastBaseline
+			err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, &baseline)
+			if test.accepted {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if baseline.Spec.AcceptedBy != test.acceptedBy || baseline.Spec.ScanID != "1" || len(baseline.Spec.Alerts) != 1 {
+					t.Errorf("unexpected baseline %+v", baseline.Spec)
+				}
+			} else if client.IgnoreNotFound(err) != nil || err == nil {
+				t.Errorf("baseline is accepted without authorization: %v", err)
+			}
+
+			if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, service); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := service.Labels[results.AcceptBaselineLabel]; ok {
+				t.Errorf("accept-baseline label isn't removed")
+			}
+			if _, ok := service.Annotations[results.AcceptedByAnnotation]; ok {
+				t.Errorf("accepted-by annotation isn't removed")
+			}
+		})
+	}
+}
+
+func TestAcceptFilteredAlerts(t *testing.T) {
+	ctx := context.Background()
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:      "app",
+		Namespace: "default",
+		UID:       "uid",
+		Labels:    map[string]string{results.AcceptBaselineLabel: "true"},
+		Annotations: map[string]string{
+			results.AcceptedByAnnotation:   "alice",
+			results.AcceptAlertsAnnotation: `{"port":80,"pluginId":"40012"}`,
+		},
+	}}
+	current := &securityv1alpha1.DastBaseline{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: securityv1alpha1.DastBaselineSpec{
+			Service:    "app",
+			ScanID:     "0",
+			AcceptedBy: "admin",
+			Alerts:     []securityv1alpha1.BaselineAlert{{PluginID: "10021", URL: "http://app/"}},
+		},
+	}
+	c := newFakeClient(t, service.DeepCopy(), current)
+	store := results.NewConfigMapStore(c, c, 0)
+	for _, scan := range []*results.Scan{
+		{ID: "80-1", Namespace: "default", Service: "app", Port: 80, Alerts: []results.Alert{
+			{PluginID: "40012", Name: "XSS", Risk: results.RiskHigh, URL: "http://app/search", Param: "q"},
+			{PluginID: "10020", Name: "X-Frame-Options Header Not Set", Risk: "Medium", URL: "http://app/"},
+		}},
+		{ID: "8080-1", Namespace: "default", Service: "app", Port: 8080, Alerts: []results.Alert{
+			{PluginID: "40012", Name: "XSS", Risk: results.RiskHigh, URL: "http://app:8080/search", Param: "q"},
+		}},
+	} {
+		if err := store.Save(ctx, scan, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &ServiceReconciler{Client: c, Log: zap.New(), Results: store}
+
+	if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, service); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.acceptBaseline(ctx, service, r.Log); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the matching alert is added to the accepted ones
+	var baseline securityv1alpha1.DastBaseline
+	if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, &baseline); err != nil {
+		t.Fatal(err)
+	}
+	if baseline.Spec.AcceptedBy != "alice" || len(baseline.Spec.Alerts) != 2 {
+		t.Fatalf("unexpected baseline %+v", baseline.Spec)
+	}
+	if alert := baseline.Spec.Alerts[1]; alert.PluginID != "40012" || alert.URL != "http://app/search" {
+		t.Errorf("unexpected accepted alert %+v", alert)
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, service); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.Annotations[results.AcceptAlertsAnnotation]; ok {
+		t.Errorf("accept-alerts annotation isn't removed")
+	}
+}
@@ -18,17 +18,28 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/exporter"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/notifier"
 	"github.com/banzaicloud/dast-operator/pkg/resources"
 	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
 	"github.com/banzaicloud/dast-operator/pkg/resources/zaproxy"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+	"github.com/banzaicloud/dast-operator/pkg/scheduler"
 )
 
 // DastReconciler reconciles a Dast object
@@ -36,14 +47,33 @@ type DastReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Results stores the results of finished service scans, optional
+	Results results.Store
+	// Notifier sends notifications of finished scans, optional
+	Notifier *notifier.Notifier
+	// Exporters push the stored scans to vulnerability management systems in the background
+	Exporters *exporter.Queue
+	// Logs reads the alerts of the scans run by a ZAP sidecar from the analyzer logs
+	Logs k8sutil.PodLogs
+	// Scheduler limits the number of running analyzer jobs, optional
+	Scheduler *scheduler.Scheduler
+	// JobHistory is the number of analyzer jobs of previous runs kept when a scan is run again
+	JobHistory int
+	// Recorder records events of Dasts with invalid annotations, optional
+	Recorder record.EventRecorder
+	// ClusterScanNamespaces are the namespaces of the Dasts, which can select services in other namespaces and store their results.
+	// Other Dasts only scan and store results in their own namespace.
+	ClusterScanNamespaces []string
 }
 
 // +kubebuilder:rbac:groups=security.banzaicloud.io,resources=dasts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=security.banzaicloud.io,resources=dasts/status,verbs=get;update;patch;watch
+// +kubebuilder:rbac:groups=security.banzaicloud.io,resources=dastscanpolicies,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;create;list;update;patch;watch
-// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;list;update;patch;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;create;list;update;patch;watch;delete
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;create;list;update;patch;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;list;update;patch;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 
 func (r *DastReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -63,9 +93,6 @@ func (r *DastReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	reconcilers := []resources.ComponentReconciler{
 		zaproxy.New(r.Client, &dast),
 	}
-	if dast.Spec.Analyzer.Name != "" {
-		reconcilers = append(reconcilers, analyzer.New(r.Client, &dast))
-	}
 
 	for _, rec := range reconcilers {
 		err := rec.Reconcile(log)
@@ -73,11 +100,117 @@ func (r *DastReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return ctrl.Result{}, err
 		}
 	}
+
+	if dast.Spec.Analyzer.TargetSelector == nil && dast.Spec.Analyzer.Name == "" {
+		return ctrl.Result{}, nil
+	}
+	priority, err := k8sutil.GetScanPriority(&dast)
+	if err != nil {
+		log.Error(err, "scan isn't started")
+		analyzer.SetScanConditions(&dast.Status.Conditions, invalidAnnotation(r.Recorder, &dast, err))
+		return ctrl.Result{}, r.Status().Update(ctx, &dast)
+	}
+	if dast.Spec.Analyzer.TargetSelector != nil {
+		return r.reconcileTargets(ctx, &dast, priority, log)
+	}
+	admitted, position, err := admitScan(ctx, r.Client, r.Scheduler, &dast, dast.GetNamespace(), priority, r.JobHistory)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !admitted {
+		return ctrl.Result{RequeueAfter: scheduler.RetryInterval}, r.updateQueueStatus(ctx, &dast, position)
+	}
+	if err := analyzer.New(r.Client, &dast).Reconcile(log); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateScanStatus(ctx, &dast); err != nil {
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
+// updateQueueStatus records the position of the queued analyzer job of the Dast
+func (r *DastReconciler) updateQueueStatus(ctx context.Context, dast *securityv1alpha1.Dast, position int) error {
+	dast.Status.QueuePosition = position
+	analyzer.SetScanConditions(&dast.Status.Conditions, &analyzer.ScanResult{
+		Reason:  securityv1alpha1.ReasonQueued,
+		Message: fmt.Sprintf("analyzer job is queued at position %d", position),
+	})
+	return r.Status().Update(ctx, dast)
+}
+
+// updateScanStatus maps the outcome of the analyzer job to the status conditions
+func (r *DastReconciler) updateScanStatus(ctx context.Context, dast *securityv1alpha1.Dast) error {
+	var job batchv1.Job
+	if err := r.Get(ctx, types.NamespacedName{Name: analyzer.JobName(dast), Namespace: dast.Namespace}, &job); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	result, err := analyzer.GetScanResult(r.Client, &job)
+	if err != nil {
+		return err
+	}
+	if result.Finished && r.subjectAllowed(dast) {
+		scan, err := recordScan(ctx, r.Client, r.Results, r.Notifier, r.Exporters, r.Logs, dast, &job, result, r.Log)
+		if err != nil {
+			return err
+		}
+		if scan != nil {
+			dast.Status.LastScanID = scan.ID
+			dast.Status.NewHigh = scan.NewHigh
+		}
+	}
+
+	dast.Status.QueuePosition = 0
+	finished := meta.IsStatusConditionTrue(dast.Status.Conditions, securityv1alpha1.ScanPassed) || meta.IsStatusConditionTrue(dast.Status.Conditions, securityv1alpha1.ScanFailed)
+	analyzer.SetScanConditions(&dast.Status.Conditions, result)
+	if err := r.Status().Update(ctx, dast); err != nil {
+		return err
+	}
+
+	// service scans are notified when they are stored
+	if result.Finished && !finished && dast.Spec.Analyzer.Service == nil {
+		if err := r.Notifier.Notify(ctx, &notifier.Event{
+			Type:      securityv1alpha1.EventScanFinished,
+			Namespace: dast.GetNamespace(),
+			Target:    dast.Spec.Analyzer.Target,
+			Passed:    result.Passed,
+			Reason:    result.Reason,
+			Message:   result.Message,
+			Summary:   result.AlertsSummary,
+		}); err != nil {
+			r.Log.Error(err, "failed to send notifications", "dast", dast.GetName())
+		}
+	}
+	return nil
+}
+
+// crossNamespace reports whether the Dast can scan and store results in other namespaces
+func (r *DastReconciler) crossNamespace(dast *securityv1alpha1.Dast) bool {
+	for _, namespace := range r.ClusterScanNamespaces {
+		if namespace == dast.GetNamespace() {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectAllowed reports whether the results of the Dast can be stored under its subject.
+// The results of a subject in another namespace are rejected with a warning event, unless the Dast can scan other namespaces.
+func (r *DastReconciler) subjectAllowed(dast *securityv1alpha1.Dast) bool {
+	namespace, name := scanSubject(dast)
+	if name == "" || namespace == dast.GetNamespace() || r.crossNamespace(dast) {
+		return true
+	}
+	r.Log.Info("results of a subject in another namespace aren't stored", "dast", dast.GetName(), "namespace", dast.GetNamespace(), "subject", namespace+"/"+name)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(dast, corev1.EventTypeWarning, "SubjectRejected", "results of %s/%s aren't stored, the Dast can't store results in other namespaces", namespace, name)
+	}
+	return false
+}
+
 func (r *DastReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&securityv1alpha1.Dast{}).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
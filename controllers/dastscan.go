@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"emperror.dev/emperror"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=security.banzaicloud.io,resources=dastscans,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=security.banzaicloud.io,resources=dastscans/status,verbs=get;update;patch
+
+// dastScanName returns the name of the DastScan of the scanned object
+func dastScanName(kind, name string) string {
+	return strings.ToLower(kind) + "-" + name
+}
+
+// updateDastScan applies update to the status of the DastScan of the scanned object.
+// The DastScan is created with the object as controller owner if it doesn't exist yet,
+// so the scan status is kept off the object and it's deleted together with the object.
+func updateDastScan(ctx context.Context, c client.Client, obj metav1.Object, gvk schema.GroupVersionKind, update func(status *securityv1alpha1.DastScanStatus)) error {
+	var scan securityv1alpha1.DastScan
+	key := types.NamespacedName{Name: dastScanName(gvk.Kind, obj.GetName()), Namespace: obj.GetNamespace()}
+	err := c.Get(ctx, key, &scan)
+	if apierrors.IsNotFound(err) {
+		scan = securityv1alpha1.DastScan{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            key.Name,
+				Namespace:       key.Namespace,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(obj, gvk)},
+			},
+			Spec: securityv1alpha1.DastScanSpec{
+				Kind: gvk.Kind,
+				Name: obj.GetName(),
+			},
+		}
+		err = c.Create(ctx, &scan)
+	}
+	if err != nil {
+		return emperror.WrapWith(err, "failed to get dastscan", "name", key.Name, "namespace", key.Namespace)
+	}
+
+	current := scan.Status.DeepCopy()
+	update(&scan.Status)
+	if equality.Semantic.DeepEqual(current, &scan.Status) {
+		return nil
+	}
+	return emperror.WrapWith(c.Status().Update(ctx, &scan), "failed to update dastscan status", "name", key.Name, "namespace", key.Namespace)
+}
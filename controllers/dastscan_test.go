@@ -0,0 +1,102 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func newFakeClient(t *testing.T, objects ...runtime.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := securityv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewFakeClientWithScheme(scheme, objects...)
+}
+
+func TestServiceScanStatus(t *testing.T) {
+	ctx := context.Background()
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:        "app",
+		Namespace:   "default",
+		UID:         "uid",
+		Annotations: map[string]string{"dast.security.banzaicloud.io/zaproxy": "dast-test"},
+	}}
+	c := newFakeClient(t, service.DeepCopy())
+	r := &ServiceReconciler{Client: c, Log: zap.New()}
+
+	getScan := func() *securityv1alpha1.DastScan {
+		var scan securityv1alpha1.DastScan
+		if err := c.Get(ctx, types.NamespacedName{Name: "service-app", Namespace: "default"}, &scan); err != nil {
+			t.Fatal(err)
+		}
+		return &scan
+	}
+
+	if err := r.updateQueueStatus(ctx, service, 3); err != nil {
+		t.Fatal(err)
+	}
+	scan := getScan()
+	if scan.Spec.Kind != "Service" || scan.Spec.Name != "app" {
+		t.Errorf("unexpected scanned object %+v", scan.Spec)
+	}
+	if owner := metav1.GetControllerOf(scan); owner == nil || owner.Kind != "Service" || owner.UID != "uid" {
+		t.Errorf("dastscan isn't owned by the service: %v", scan.GetOwnerReferences())
+	}
+	if scan.Status.QueuePosition != 3 || meta.FindStatusCondition(scan.Status.Conditions, securityv1alpha1.ScanPassed).Reason != securityv1alpha1.ReasonQueued {
+		t.Errorf("unexpected queued status %+v", scan.Status)
+	}
+
+	result := &analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonThresholdExceeded, Message: "2 high alerts"}
+	if err := r.setScanStatus(ctx, service, result, &results.Scan{ID: "1", NewHigh: 2}); err != nil {
+		t.Fatal(err)
+	}
+	scan = getScan()
+	if scan.Status.QueuePosition != 0 || scan.Status.LastScanID != "1" || scan.Status.NewHigh != 2 {
+		t.Errorf("unexpected finished status %+v", scan.Status)
+	}
+	if !meta.IsStatusConditionTrue(scan.Status.Conditions, securityv1alpha1.ScanFailed) || meta.IsStatusConditionTrue(scan.Status.Conditions, securityv1alpha1.ScanPassed) {
+		t.Errorf("unexpected conditions %+v", scan.Status.Conditions)
+	}
+
+	// the scan status is kept off the service
+	var current corev1.Service
+	if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, &current); err != nil {
+		t.Fatal(err)
+	}
+	if len(current.GetAnnotations()) != 1 {
+		t.Errorf("service is modified: %v", current.GetAnnotations())
+	}
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"emperror.dev/emperror"
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/exporter"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/notifier"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+	"github.com/banzaicloud/dast-operator/pkg/scheduler"
+)
+
+const (
+	scanHostsAnnotation         = "dast.security.banzaicloud.io/scan-hosts"
+	ingressControllerAnnotation = "dast.security.banzaicloud.io/ingress-controller"
+)
+
+// IngressReconciler scans the hosts of annotated ingresses end-to-end, through the ingress controller
+type IngressReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Results stores the results of finished host scans, optional
+	Results results.Store
+	// Notifier sends notifications of finished scans, optional
+	Notifier *notifier.Notifier
+	// Exporters push the stored scans to vulnerability management systems in the background
+	Exporters *exporter.Queue
+	// Logs reads the alerts of the scans run by a ZAP sidecar from the analyzer logs
+	Logs k8sutil.PodLogs
+	// Scheduler limits the number of running analyzer jobs, optional
+	Scheduler *scheduler.Scheduler
+	// JobHistory is the number of analyzer jobs of previous runs kept when a scan is run again
+	JobHistory int
+	// Recorder records events of ingresses with invalid annotations, optional
+	Recorder record.EventRecorder
+	// ControllerService is the ingress controller service in namespace/name[:port] format, which the hosts are scanned through.
+	// The hosts are scanned directly if it's empty and the ingress has no ingress-controller annotation.
+	ControllerService string
+}
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;update;patch
+
+func (r *IngressReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("ingress", req.NamespacedName)
+
+	var ingress networkingv1.Ingress
+	if err := r.Get(ctx, req.NamespacedName, &ingress); err != nil {
+		if apierrors.IsNotFound(err) {
+			// the jobs of the hosts of the deleted ingress in the namespace of ZAP have no owner reference
+			return ctrl.Result{}, analyzer.DeleteTargetJobs(r.Client, client.MatchingLabels{
+				analyzer.IngressNameLabel:      req.Name,
+				analyzer.IngressNamespaceLabel: req.Namespace,
+			})
+		}
+		return ctrl.Result{}, err
+	}
+	if ingress.GetAnnotations()[scanHostsAnnotation] != "true" {
+		return ctrl.Result{}, nil
+	}
+	annotations, err := k8sutil.ResolveAnnotations(ctx, r.Client, &ingress)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	resolved := ingress.DeepCopy()
+	resolved.SetAnnotations(annotations)
+	zaProxyCfg, err := k8sutil.GetServiceAnotations(resolved, log)
+	if err != nil {
+		return ctrl.Result{}, nil
+	}
+	now := time.Now()
+	settings, err := getScanSettings(resolved)
+	if err != nil {
+		log.Error(err, "hosts aren't scanned")
+		return ctrl.Result{}, r.updateScanStatus(ctx, &ingress, invalidAnnotation(r.Recorder, &ingress, err), 0)
+	}
+	controller := r.ControllerService
+	if c, ok := annotations[ingressControllerAnnotation]; ok {
+		controller = c
+	}
+
+	var hostResults []*analyzer.ScanResult
+	position := 0
+	for _, host := range k8sutil.GetIngressHosts(&ingress) {
+		target, controllerService, err := k8sutil.IngressHostTarget(host, controller)
+		if err != nil {
+			log.Error(err, "invalid ingress controller, the hosts aren't scanned")
+			return ctrl.Result{}, nil
+		}
+		hostAliases, err := r.hostAliases(ctx, host.Host, controllerService)
+		if err != nil {
+			log.Error(err, "the ingress controller can't be resolved, the hosts aren't scanned")
+			return ctrl.Result{}, nil
+		}
+		scope := k8sutil.GetServiceScope(resolved)
+		if scope == nil {
+			scope = k8sutil.IngressHostScope(host, target)
+		}
+
+		name := strings.Replace(results.IngressScanKey(ingress.GetName(), host.Host), ".", "-", 1)
+		dast := securityv1alpha1.Dast{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   zaProxyCfg["namespace"],
+				Annotations: rescanAnnotations(&ingress, settings.schedule, now),
+			},
+			Spec: securityv1alpha1.DastSpec{
+				ZaProxy: securityv1alpha1.ZaProxy{
+					Name: zaProxyCfg["name"],
+				},
+				Analyzer: securityv1alpha1.Analyzer{
+					Image:       zaProxyCfg["analyzer_image"],
+					Name:        name,
+					Target:      target,
+					HostAliases: hostAliases,
+					Scope:       scope,
+					ScanPolicy:  annotations["dast.security.banzaicloud.io/scan-policy"],
+					MaxDuration: settings.maxDuration,
+					FailOn:      settings.failOn,
+					Ingress: &securityv1alpha1.IngressTarget{
+						Name:      ingress.GetName(),
+						Namespace: ingress.GetNamespace(),
+						UID:       ingress.GetUID(),
+						Host:      host.Host,
+						Backends:  host.Backends,
+					},
+				},
+			},
+		}
+
+		admitted, hostPosition, err := admitScan(ctx, r.Client, r.Scheduler, &dast, ingress.GetNamespace(), settings.priority, r.JobHistory)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !admitted {
+			if position == 0 || hostPosition < position {
+				position = hostPosition
+			}
+			hostResults = append(hostResults, &analyzer.ScanResult{
+				Reason:  securityv1alpha1.ReasonQueued,
+				Message: fmt.Sprintf("%s: analyzer job is queued at position %d", host.Host, hostPosition),
+			})
+			continue
+		}
+		if err := analyzer.New(r.Client, &dast).Reconcile(log.WithValues("host", host.Host)); err != nil {
+			return ctrl.Result{}, err
+		}
+		result, err := r.hostScanResult(ctx, &dast)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		result.Message = host.Host + ": " + result.Message
+		hostResults = append(hostResults, result)
+	}
+	if len(hostResults) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if position > 0 {
+		return ctrl.Result{RequeueAfter: scheduler.RetryInterval}, r.updateScanStatus(ctx, &ingress, aggregateHostResults(hostResults), position)
+	}
+	return ctrl.Result{RequeueAfter: nextScheduledRun(&ingress, settings.schedule, now)}, r.updateScanStatus(ctx, &ingress, aggregateHostResults(hostResults), position)
+}
+
+// hostAliases resolves the host to the cluster IP of the ingress controller service, the host isn't aliased without controller
+func (r *IngressReconciler) hostAliases(ctx context.Context, host string, controller *types.NamespacedName) ([]corev1.HostAlias, error) {
+	if controller == nil {
+		return nil, nil
+	}
+	var service corev1.Service
+	if err := r.Get(ctx, *controller, &service); err != nil {
+		return nil, emperror.WrapWith(err, "failed to get ingress controller service", "service", controller.String())
+	}
+	if service.Spec.ClusterIP == "" || service.Spec.ClusterIP == corev1.ClusterIPNone {
+		return nil, errors.NewWithDetails("ingress controller service has no cluster IP", "service", controller.String())
+	}
+	return []corev1.HostAlias{{IP: service.Spec.ClusterIP, Hostnames: []string{host}}}, nil
+}
+
+// hostScanResult records the finished scan of a host and returns its result
+func (r *IngressReconciler) hostScanResult(ctx context.Context, dast *securityv1alpha1.Dast) (*analyzer.ScanResult, error) {
+	var job batchv1.Job
+	if err := r.Get(ctx, types.NamespacedName{Name: analyzer.JobName(dast), Namespace: dast.Namespace}, &job); err != nil {
+		return &analyzer.ScanResult{Reason: securityv1alpha1.ReasonScanInProgress, Message: "analyzer job is starting"}, client.IgnoreNotFound(err)
+	}
+	result, err := analyzer.GetScanResult(r.Client, &job)
+	if err != nil {
+		return nil, err
+	}
+	if result.Finished {
+		if _, err := recordScan(ctx, r.Client, r.Results, r.Notifier, r.Exporters, r.Logs, dast, &job, result, r.Log); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// aggregateHostResults returns the result of the scan of the ingress from the results of its hosts.
+// The scan failed if any host failed, and it's in progress until every host is scanned.
+func aggregateHostResults(hostResults []*analyzer.ScanResult) *analyzer.ScanResult {
+	var failed, pending, passed []string
+	reason := securityv1alpha1.ReasonQueued
+	failedReason := securityv1alpha1.ReasonError
+	for _, result := range hostResults {
+		switch {
+		case result.Finished && !result.Passed:
+			failed = append(failed, result.Message)
+			// exceeded thresholds are reported over the other failures
+			if len(failed) == 1 || result.Reason == securityv1alpha1.ReasonThresholdExceeded {
+				failedReason = result.Reason
+			}
+		case !result.Finished:
+			pending = append(pending, result.Message)
+			if result.Reason != securityv1alpha1.ReasonQueued {
+				reason = securityv1alpha1.ReasonScanInProgress
+			}
+		default:
+			passed = append(passed, result.Message)
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		return &analyzer.ScanResult{Finished: true, Reason: failedReason, Message: strings.Join(failed, "; ")}
+	case len(pending) > 0:
+		return &analyzer.ScanResult{Reason: reason, Message: strings.Join(pending, "; ")}
+	default:
+		return &analyzer.ScanResult{Finished: true, Passed: true, Reason: securityv1alpha1.ReasonScanCompleted, Message: strings.Join(passed, "; ")}
+	}
+}
+
+// updateScanStatus records the result of the scan of the ingress in its DastScan.
+// The lowest queue position of the queued hosts is recorded while any host is queued.
+func (r *IngressReconciler) updateScanStatus(ctx context.Context, ingress *networkingv1.Ingress, result *analyzer.ScanResult, position int) error {
+	return updateDastScan(ctx, r.Client, ingress, networkingv1.SchemeGroupVersion.WithKind("Ingress"), func(status *securityv1alpha1.DastScanStatus) {
+		status.QueuePosition = position
+		analyzer.SetScanConditions(&status.Conditions, result)
+	})
+}
+
+// jobToIngress maps analyzer jobs to the ingress, whose host is scanned
+func jobToIngress(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+	name, ok := labels[analyzer.IngressNameLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: name, Namespace: labels[analyzer.IngressNamespaceLabel]}},
+	}
+}
+
+func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&networkingv1.Ingress{}).
+		Watches(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(jobToIngress)}).
+		Complete(r)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+)
+
+func TestIngressHostAliases(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t,
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: "ingress-nginx"}, Spec: corev1.ServiceSpec{ClusterIP: "10.0.0.10"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "ingress-nginx"}, Spec: corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone}},
+	)
+	r := &IngressReconciler{Client: c, Log: zap.New()}
+
+	aliases, err := r.hostAliases(ctx, "shop.example.com", &types.NamespacedName{Name: "controller", Namespace: "ingress-nginx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 1 || aliases[0].IP != "10.0.0.10" || len(aliases[0].Hostnames) != 1 || aliases[0].Hostnames[0] != "shop.example.com" {
+		t.Errorf("unexpected host aliases %+v", aliases)
+	}
+	if aliases, err := r.hostAliases(ctx, "shop.example.com", nil); err != nil || aliases != nil {
+		t.Errorf("host is aliased without controller: %+v, %v", aliases, err)
+	}
+	for _, name := range []string{"headless", "missing"} {
+		if _, err := r.hostAliases(ctx, "shop.example.com", &types.NamespacedName{Name: name, Namespace: "ingress-nginx"}); err == nil {
+			t.Errorf("%s controller service is resolved", name)
+		}
+	}
+}
+
+func TestAggregateHostResults(t *testing.T) {
+	passed := &analyzer.ScanResult{Finished: true, Passed: true, Reason: securityv1alpha1.ReasonScanCompleted, Message: "a: passed"}
+	failed := &analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonThresholdExceeded, Message: "b: failed"}
+	timedOut := &analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonTimeout, Message: "e: timed out"}
+	running := &analyzer.ScanResult{Reason: securityv1alpha1.ReasonScanInProgress, Message: "c: running"}
+	queued := &analyzer.ScanResult{Reason: securityv1alpha1.ReasonQueued, Message: "d: queued"}
+	tests := []struct {
+		results  []*analyzer.ScanResult
+		expected analyzer.ScanResult
+	}{
+		{results: []*analyzer.ScanResult{passed}, expected: analyzer.ScanResult{Finished: true, Passed: true, Reason: securityv1alpha1.ReasonScanCompleted, Message: "a: passed"}},
+		{results: []*analyzer.ScanResult{passed, running, failed}, expected: analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonThresholdExceeded, Message: "b: failed"}},
+		{results: []*analyzer.ScanResult{timedOut, passed}, expected: analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonTimeout, Message: "e: timed out"}},
+		{results: []*analyzer.ScanResult{timedOut, failed}, expected: analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonThresholdExceeded, Message: "e: timed out; b: failed"}},
+		{results: []*analyzer.ScanResult{passed, queued}, expected: analyzer.ScanResult{Reason: securityv1alpha1.ReasonQueued, Message: "d: queued"}},
+		{results: []*analyzer.ScanResult{queued, running}, expected: analyzer.ScanResult{Reason: securityv1alpha1.ReasonScanInProgress, Message: "d: queued; c: running"}},
+	}
+	for _, test := range tests {
+		result := aggregateHostResults(test.results)
+		if result.Finished != test.expected.Finished || result.Passed != test.expected.Passed || result.Reason != test.expected.Reason || result.Message != test.expected.Message {
+			t.Errorf("expected %+v, got %+v", test.expected, *result)
+		}
+	}
+}
+
+func TestIngressScanStatus(t *testing.T) {
+	ctx := context.Background()
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:        "shop",
+		Namespace:   "default",
+		UID:         "uid",
+		Annotations: map[string]string{scanHostsAnnotation: "true"},
+	}}
+	c := newFakeClient(t, ingress.DeepCopy())
+	r := &IngressReconciler{Client: c, Log: zap.New()}
+
+	if err := r.updateScanStatus(ctx, ingress, &analyzer.ScanResult{Reason: securityv1alpha1.ReasonQueued, Message: "shop.example.com: queued"}, 2); err != nil {
+		t.Fatal(err)
+	}
+	var scan securityv1alpha1.DastScan
+	if err := c.Get(ctx, types.NamespacedName{Name: "ingress-shop", Namespace: "default"}, &scan); err != nil {
+		t.Fatal(err)
+	}
+	if scan.Spec.Kind != "Ingress" || scan.Spec.Name != "shop" || scan.Status.QueuePosition != 2 {
+		t.Errorf("unexpected dastscan %+v", scan)
+	}
+	if owner := metav1.GetControllerOf(&scan); owner == nil || owner.Kind != "Ingress" || owner.UID != "uid" {
+		t.Errorf("dastscan isn't owned by the ingress: %v", scan.GetOwnerReferences())
+	}
+	if condition := meta.FindStatusCondition(scan.Status.Conditions, securityv1alpha1.ScanPassed); condition == nil || condition.Reason != securityv1alpha1.ReasonQueued {
+		t.Errorf("unexpected conditions %+v", scan.Status.Conditions)
+	}
+
+	// the scan status is kept off the ingress
+	var current networkingv1.Ingress
+	if err := c.Get(ctx, types.NamespacedName{Name: "shop", Namespace: "default"}, &current); err != nil {
+		t.Fatal(err)
+	}
+	if len(current.GetAnnotations()) != 1 {
+		t.Errorf("ingress is modified: %v", current.GetAnnotations())
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+var (
+	scanNewHighAlerts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dast_scan_new_high_alerts",
+		Help: "Number of high alerts of the latest scan of the service port, which weren't reported by the previous scan of the port",
+	}, []string{"namespace", "service", "port"})
+	scanNewAlerts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dast_scan_new_alerts",
+		Help: "Number of new alerts of the latest scan of the service port per risk level",
+	}, []string{"namespace", "service", "port", "risk"})
+	scanFixedAlerts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dast_scan_fixed_alerts",
+		Help: "Number of alerts of the previous scan of the service port, which weren't reported by the latest scan of the port",
+	}, []string{"namespace", "service", "port"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(scanNewHighAlerts, scanNewAlerts, scanFixedAlerts)
+}
+
+// updateScanMetrics exposes the diff of the latest scan of a service port, the port is empty for ingress hosts
+func updateScanMetrics(scan *results.Scan) {
+	if scan.Diff == nil {
+		return
+	}
+	port := ""
+	if scan.Port != 0 {
+		port = strconv.Itoa(int(scan.Port))
+	}
+	scanNewHighAlerts.WithLabelValues(scan.Namespace, scan.Service, port).Set(float64(scan.NewHigh))
+	for risk, count := range scan.Diff.NewSummary() {
+		scanNewAlerts.WithLabelValues(scan.Namespace, scan.Service, port, risk).Set(float64(count))
+	}
+	scanFixedAlerts.WithLabelValues(scan.Namespace, scan.Service, port).Set(float64(len(scan.Diff.Fixed)))
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/exporter"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/notifier"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+	"github.com/banzaicloud/dast-operator/pkg/zapclient"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=security.banzaicloud.io,resources=dastbaselines,verbs=get;list;watch;create;update
+
+// scanSubject returns the namespace and the name the results of the scan are stored under, the name is empty if the results aren't stored
+func scanSubject(dast *securityv1alpha1.Dast) (string, string) {
+	switch {
+	case dast.Spec.Analyzer.Service != nil:
+		return dast.Spec.Analyzer.Service.GetNamespace(), dast.Spec.Analyzer.Service.GetName()
+	case dast.Spec.Analyzer.Ingress != nil:
+		return dast.Spec.Analyzer.Ingress.Namespace, results.IngressScanKey(dast.Spec.Analyzer.Ingress.Name, dast.Spec.Analyzer.Ingress.Host)
+	default:
+		return "", ""
+	}
+}
+
+// scanPort returns the scanned port of the service, the results of the ports are stored separately.
+// It's zero for ingress hosts and targets without port.
+func scanPort(dast *securityv1alpha1.Dast) int32 {
+	if dast.Spec.Analyzer.Service == nil {
+		return 0
+	}
+	target, err := url.Parse(dast.Spec.Analyzer.Target)
+	if err != nil {
+		return 0
+	}
+	port, err := strconv.ParseInt(target.Port(), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(port)
+}
+
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+
+// recordScan stores the alerts and reports of a finished service or ingress host scan with the diff to the previous scan.
+// It returns the stored scan, scans already stored are not fetched again.
+// Notifications are sent and the scan is exported when the scan is stored.
+func recordScan(ctx context.Context, c client.Client, store results.Store, n *notifier.Notifier, exporters *exporter.Queue, logs k8sutil.PodLogs, dast *securityv1alpha1.Dast, job *batchv1.Job, result *analyzer.ScanResult, log logr.Logger) (*results.Scan, error) {
+	namespace, name := scanSubject(dast)
+	if store == nil || name == "" || !result.Finished {
+		return nil, nil
+	}
+	id := string(job.GetUID())
+	scan, err := store.Get(ctx, namespace, name, id)
+	if err == nil {
+		updateScanMetrics(scan)
+		return scan, applyBaseline(ctx, c, dast, scan, result)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	port := scanPort(dast)
+	previous, err := results.LatestOfPort(ctx, store, namespace, name, port)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, summary, err := scanAlerts(ctx, c, logs, dast, job, result, log)
+	if err != nil {
+		return nil, err
+	}
+
+	scan = &results.Scan{
+		ID:             id,
+		Namespace:      namespace,
+		Service:        name,
+		Port:           port,
+		Target:         dast.Spec.Analyzer.Target,
+		Job:            job.GetName(),
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+		Passed:         result.Passed,
+		Reason:         result.Reason,
+		Message:        result.Message,
+		Summary:        summary,
+		Alerts:         alerts,
+	}
+	target := exporter.Target{
+		Namespace: namespace,
+		Service:   name,
+	}
+	if service := dast.Spec.Analyzer.Service; service != nil {
+		target.ServiceLabels = service.GetLabels()
+	}
+	if ingress := dast.Spec.Analyzer.Ingress; ingress != nil {
+		scan.Ingress = ingress.Name
+		scan.Host = ingress.Host
+		scan.Backends = ingress.Backends
+	}
+	if scan.CompletionTime == nil {
+		// failed and stopped jobs have no completion time
+		now := metav1.Now()
+		scan.CompletionTime = &now
+	}
+	scan.Diff = results.Compare(previous, scan)
+	scan.NewHigh = scan.Diff.NewSummary()[results.RiskHigh]
+	if err := applyBaseline(ctx, c, dast, scan, result); err != nil {
+		return nil, err
+	}
+	scan.Passed = result.Passed
+	scan.Reason = result.Reason
+	scan.Message = result.Message
+
+	reports, err := results.Reports(scan)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("storing scan results", "id", id, "summary", summary, "new", len(scan.Diff.New), "fixed", len(scan.Diff.Fixed))
+	if err := store.Save(ctx, scan, reports); err != nil {
+		return nil, err
+	}
+	updateScanMetrics(scan)
+	if err := n.Notify(ctx, notifier.ScanFinishedEvent(scan)); err != nil {
+		log.Error(err, "failed to send notifications", "id", id)
+	}
+	exportScan(ctx, c, exporters, target, scan, reports, log)
+	return scan, nil
+}
+
+// exportScan queues the scan for the exporters with the labels of the namespace, failed exports are logged and not retried
+func exportScan(ctx context.Context, c client.Client, exporters *exporter.Queue, target exporter.Target, scan *results.Scan, reports map[string][]byte, log logr.Logger) {
+	if exporters == nil {
+		return
+	}
+	var namespace corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: target.Namespace}, &namespace); err != nil {
+		log.Error(err, "failed to get namespace labels", "namespace", target.Namespace)
+	} else {
+		target.NamespaceLabels = namespace.GetLabels()
+	}
+	exporters.Export(target, scan, reports)
+}
+
+// applyBaseline passes a scan exceeding the thresholds, if the alerts above the thresholds are accepted by the baseline of the service
+func applyBaseline(ctx context.Context, c client.Client, dast *securityv1alpha1.Dast, scan *results.Scan, result *analyzer.ScanResult) error {
+	if result.Reason != securityv1alpha1.ReasonThresholdExceeded {
+		return nil
+	}
+	baseline, err := results.GetBaseline(ctx, c, scan.Namespace, scan.Service)
+	if err != nil || baseline == nil {
+		return err
+	}
+	if results.ExceedsThresholds(results.Summarize(results.ExcludeBaseline(scan.Alerts, baseline)), dast.Spec.Analyzer.FailOn) {
+		return nil
+	}
+	result.Passed = true
+	result.Reason = securityv1alpha1.ReasonScanCompleted
+	result.Message = "alerts above the thresholds are accepted by the baseline"
+	return nil
+}
+
+// scanAlerts returns the alerts of a finished scan with their number per risk level.
+// The alerts of scans run by a ZAP sidecar are read from the log of the analyzer, the sidecar is gone with the alerts after the scan.
+func scanAlerts(ctx context.Context, c client.Client, logs k8sutil.PodLogs, dast *securityv1alpha1.Dast, job *batchv1.Job, result *analyzer.ScanResult, log logr.Logger) ([]results.Alert, map[string]int, error) {
+	if !analyzer.UsesSidecar(dast) {
+		zapClient, err := zapclient.NewFromSecret(dast.Spec.ZaProxy.Name, dast.GetNamespace(), c, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		return results.FetchAlerts(zapClient, dast.Spec.Analyzer.Target, result.AlertsAfter)
+	}
+
+	// the analyzer writes the alerts only when the scan got to the results
+	reported := result.Passed || result.Reason == securityv1alpha1.ReasonThresholdExceeded || result.Reason == securityv1alpha1.ReasonTimeout
+	if result.Pod == "" || len(job.Spec.Template.Spec.Containers) == 0 {
+		if reported {
+			return nil, nil, errors.NewWithDetails("no analyzer pod of the scan", "job", job.GetName())
+		}
+		return []results.Alert{}, map[string]int{}, nil
+	}
+	if logs == nil {
+		return nil, nil, errors.New("pod logs aren't available to read the alerts of the ZAP sidecar")
+	}
+	content, err := logs.Logs(ctx, job.GetNamespace(), result.Pod, job.Spec.Template.Spec.Containers[0].Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	alerts, summary, err := results.AlertsFromLog(content, dast.Spec.Analyzer.Target)
+	if err != nil && !reported {
+		log.Info("failed scan reported no alerts", "pod", result.Pod, "reason", result.Reason)
+		return []results.Alert{}, map[string]int{}, nil
+	}
+	return alerts, summary, err
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+// fakePodLogs returns the logs of the pods by name
+type fakePodLogs map[string]string
+
+func (l fakePodLogs) Logs(_ context.Context, namespace, pod, container string) ([]byte, error) {
+	return []byte(l[namespace+"/"+pod+"/"+container]), nil
+}
+
+// TestRecordSidecarScan checks that the alerts of a scan run by a ZAP sidecar are read from the analyzer log,
+// the shared ZAP, which has no secret here, isn't asked
+func TestRecordSidecarScan(t *testing.T) {
+	logs := fakePodLogs{
+		"zap/dast-shop-pod/analyzer": alertsLog(t, `[{"id":"1","pluginId":"40012","alert":"XSS","risk":"High","url":"https://shop.example.com/search"}]`) + "summary: map[High:1]",
+	}
+
+	dast := &securityv1alpha1.Dast{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress-shop", Namespace: "zap"},
+		Spec: securityv1alpha1.DastSpec{
+			ZaProxy: securityv1alpha1.ZaProxy{Name: "zaproxy"},
+			Analyzer: securityv1alpha1.Analyzer{
+				Name:        "analyzer",
+				Target:      "https://shop.example.com",
+				Ingress:     &securityv1alpha1.IngressTarget{Name: "shop", Namespace: "default", Host: "shop.example.com"},
+				HostAliases: []corev1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"shop.example.com"}}},
+			},
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "dast-shop", Namespace: "zap", UID: "job-uid"},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "analyzer"}, {Name: "zaproxy"}},
+		}}},
+	}
+
+	tests := []struct {
+		name   string
+		result *analyzer.ScanResult
+		alerts int
+		err    bool
+	}{
+		{
+			name:   "passed",
+			result: &analyzer.ScanResult{Finished: true, Passed: true, Reason: securityv1alpha1.ReasonScanCompleted, Pod: "dast-shop-pod"},
+			alerts: 1,
+		},
+		{
+			name:   "failed before the scan",
+			result: &analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonError, Pod: "dast-shop-failed"},
+		},
+		{
+			name:   "failed without pod",
+			result: &analyzer.ScanResult{Finished: true, Reason: securityv1alpha1.ReasonError},
+		},
+		{
+			name:   "passed without alerts in the log",
+			result: &analyzer.ScanResult{Finished: true, Passed: true, Reason: securityv1alpha1.ReasonScanCompleted, Pod: "dast-shop-failed"},
+			err:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newFakeClient(t)
+			store := results.NewConfigMapStore(c, c, 0)
+			scan, err := recordScan(context.Background(), c, store, nil, nil, logs, dast, job, test.result, zap.New())
+			if test.err {
+				if err == nil {
+					t.Errorf("scan without alerts is stored %+v", scan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(scan.Alerts) != test.alerts || scan.Summary[results.RiskHigh] != test.alerts {
+				t.Errorf("unexpected alerts %v", scan.Alerts)
+			}
+			stored, err := store.Get(context.Background(), "default", results.IngressScanKey("shop", "shop.example.com"), "job-uid")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored.Alerts) != test.alerts {
+				t.Errorf("unexpected stored alerts %v", stored.Alerts)
+			}
+		})
+	}
+}
+
+// TestRecordScanPorts checks that the scans of a port are compared with the previous scan of the same port only
+func TestRecordScanPorts(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := results.NewConfigMapStore(c, c, 0)
+	if err := store.Save(ctx, &results.Scan{ID: "8080-1", Namespace: "default", Service: "app", Port: 8080, CompletionTime: &metav1.Time{Time: time.Now()}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+	dast := &securityv1alpha1.Dast{
+		ObjectMeta: metav1.ObjectMeta{Name: "selector", Namespace: "default"},
+		Spec: securityv1alpha1.DastSpec{Analyzer: securityv1alpha1.Analyzer{
+			Name:    "analyzer",
+			Target:  "http://app.default.svc.cluster.local:80",
+			Service: service,
+			// the alerts are read from the analyzer log of the sidecar, so no ZAP is needed
+			HostAliases: []corev1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"app.default.svc.cluster.local"}}},
+		}},
+	}
+	logs := fakePodLogs{
+		"default/pod-1/analyzer": alertsLog(t, `[{"id":"1","pluginId":"40012","alert":"XSS","risk":"High","url":"http://app.default.svc.cluster.local:80/search"}]`),
+		"default/pod-2/analyzer": alertsLog(t, `[]`),
+	}
+	for i, pod := range []string{"pod-1", "pod-2"} {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "analyzer", Namespace: "default", UID: types.UID(pod)},
+			Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "analyzer"}},
+			}}},
+			Status: batchv1.JobStatus{CompletionTime: &metav1.Time{Time: time.Now().Add(time.Duration(i+1) * time.Minute)}},
+		}
+		result := &analyzer.ScanResult{Finished: true, Passed: true, Reason: securityv1alpha1.ReasonScanCompleted, Pod: pod}
+		scan, err := recordScan(ctx, c, store, nil, nil, logs, dast, job, result, zap.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scan.Port != 80 {
+			t.Errorf("unexpected port %d", scan.Port)
+		}
+		switch i {
+		case 0:
+			if scan.Diff.PreviousID != "" || len(scan.Diff.New) != 1 {
+				t.Errorf("first scan of the port is compared with another port: %+v", scan.Diff)
+			}
+		case 1:
+			if scan.Diff.PreviousID != "pod-1" || len(scan.Diff.Fixed) != 1 {
+				t.Errorf("unexpected diff to the previous scan of the port: %+v", scan.Diff)
+			}
+		}
+	}
+}
+
+// alertsLog returns an analyzer log with the alerts the way the analyzer writes them
+func alertsLog(t *testing.T, alerts string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(alerts)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return "scan started\n" + results.AlertsLogPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()) + "\n"
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+	"github.com/banzaicloud/dast-operator/pkg/scheduler"
+)
+
+// admitScan reports whether the analyzer job of the Dast can be created, otherwise the job is queued by the scheduler
+// and its position in the queue is returned. Existing jobs are always admitted.
+// The namespace is the namespace of the scanned object, which the queue is fair between.
+// Before the job of a new run is queued, the jobs of the previous runs are stopped and the ones beyond the history are deleted.
+func admitScan(ctx context.Context, c client.Client, s *scheduler.Scheduler, dast *securityv1alpha1.Dast, namespace string, priority, history int) (bool, int, error) {
+	key := types.NamespacedName{Name: analyzer.JobName(dast), Namespace: dast.GetNamespace()}
+	var job batchv1.Job
+	err := c.Get(ctx, key, &job)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, 0, err
+	}
+	if err := analyzer.CleanupRuns(c, dast, history); err != nil {
+		return false, 0, err
+	}
+	return s.Admit(ctx, scheduler.Request{
+		Job:       key,
+		ZaProxy:   dast.Spec.ZaProxy.Name,
+		Namespace: namespace,
+		Priority:  priority,
+	})
+}
+
+// scanSettings are the settings of a scan defined in annotations
+type scanSettings struct {
+	maxDuration *metav1.Duration
+	failOn      *securityv1alpha1.Thresholds
+	priority    int
+	// schedule is the interval of the scheduled rescans, 0 if the object is only scanned once
+	schedule time.Duration
+}
+
+// getScanSettings parses the scan settings defined in the annotations of the object, an invalid annotation is an error
+func getScanSettings(obj metav1.Object) (*scanSettings, error) {
+	var settings scanSettings
+	var err error
+	if settings.maxDuration, err = k8sutil.GetServiceMaxDuration(obj); err != nil {
+		return nil, err
+	}
+	if settings.failOn, err = k8sutil.GetServiceFailOn(obj); err != nil {
+		return nil, err
+	}
+	if settings.priority, err = k8sutil.GetScanPriority(obj); err != nil {
+		return nil, err
+	}
+	if settings.schedule, err = k8sutil.GetScanSchedule(obj); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// invalidAnnotation records a warning event of the object, whose scan isn't started because of an invalid annotation.
+// It returns the failed scan result of the object.
+func invalidAnnotation(recorder record.EventRecorder, obj runtime.Object, err error) *analyzer.ScanResult {
+	if recorder != nil {
+		recorder.Event(obj, corev1.EventTypeWarning, securityv1alpha1.ReasonInvalidAnnotation, err.Error())
+	}
+	return &analyzer.ScanResult{
+		Finished: true,
+		Reason:   securityv1alpha1.ReasonInvalidAnnotation,
+		Message:  err.Error(),
+	}
+}
+
+// rescanAnnotations returns the rescan annotation of the scanned object, which is the annotation of the Dast built for the object.
+// With a schedule the number of the scheduled run since the creation of the object is added to the annotation,
+// so a new run is started in every interval.
+func rescanAnnotations(obj metav1.Object, schedule time.Duration, now time.Time) map[string]string {
+	rescan, ok := obj.GetAnnotations()[analyzer.RescanAnnotation]
+	if schedule > 0 {
+		run := now.Sub(obj.GetCreationTimestamp().Time) / schedule
+		return map[string]string{analyzer.RescanAnnotation: rescan + "/scheduled-" + strconv.FormatInt(int64(run), 10)}
+	}
+	if !ok {
+		return nil
+	}
+	return map[string]string{analyzer.RescanAnnotation: rescan}
+}
+
+// nextScheduledRun returns the time until the next scheduled run of the object, 0 if it isn't scheduled
+func nextScheduledRun(obj metav1.Object, schedule time.Duration, now time.Time) time.Duration {
+	if schedule <= 0 {
+		return 0
+	}
+	return schedule - now.Sub(obj.GetCreationTimestamp().Time)%schedule
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+)
+
+func TestScheduledRescan(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	obj := &metav1.ObjectMeta{CreationTimestamp: metav1.Time{Time: created}}
+	if annotations := rescanAnnotations(obj, 0, created.Add(time.Hour)); annotations != nil {
+		t.Errorf("unscheduled object without rescan annotation has %v", annotations)
+	}
+	if next := nextScheduledRun(obj, 0, created.Add(time.Hour)); next != 0 {
+		t.Errorf("unscheduled object is requeued after %s", next)
+	}
+
+	obj.SetAnnotations(map[string]string{analyzer.RescanAnnotation: "1"})
+	first := rescanAnnotations(obj, 24*time.Hour, created.Add(time.Hour))
+	if first[analyzer.RescanAnnotation] != "1/scheduled-0" {
+		t.Errorf("unexpected first run %v", first)
+	}
+	if same := rescanAnnotations(obj, 24*time.Hour, created.Add(23*time.Hour)); same[analyzer.RescanAnnotation] != first[analyzer.RescanAnnotation] {
+		t.Errorf("new run is started within the interval: %v", same)
+	}
+	if second := rescanAnnotations(obj, 24*time.Hour, created.Add(25*time.Hour)); second[analyzer.RescanAnnotation] != "1/scheduled-1" {
+		t.Errorf("unexpected second run %v", second)
+	}
+	if next := nextScheduledRun(obj, 24*time.Hour, created.Add(25*time.Hour)); next != 23*time.Hour {
+		t.Errorf("next run is in %s", next)
+	}
+}
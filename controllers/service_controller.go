@@ -18,26 +18,56 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"emperror.dev/emperror"
 	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/exporter"
 	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/notifier"
 	"github.com/banzaicloud/dast-operator/pkg/resources"
 	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+	"github.com/banzaicloud/dast-operator/pkg/scheduler"
 )
 
+const baselineTTLAnnotation = "dast.security.banzaicloud.io/baseline-ttl"
+
 // ServiceReconciler reconciles a Service object
 type ServiceReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Results stores the results of finished service scans, optional
+	Results results.Store
+	// Notifier sends notifications of finished scans, optional
+	Notifier *notifier.Notifier
+	// Exporters push the stored scans to vulnerability management systems in the background
+	Exporters *exporter.Queue
+	// Logs reads the alerts of the scans run by a ZAP sidecar from the analyzer logs
+	Logs k8sutil.PodLogs
+	// Scheduler limits the number of running analyzer jobs, optional
+	Scheduler *scheduler.Scheduler
+	// JobHistory is the number of analyzer jobs of previous runs kept when a scan is run again
+	JobHistory int
+	// Recorder records events of services with invalid annotations, optional
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;create;list;update;patch;watch
@@ -49,39 +79,64 @@ func (r *ServiceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	var service corev1.Service
 	if err := r.Get(ctx, req.NamespacedName, &service); err != nil {
 		if apierrors.IsNotFound(err) {
-			// we'll ignore not-found errors, since they can't be fixed by an immediate
-			// requeue (we'll need to wait for a new notification), and we can get them
-			// on deleted requests.
-			return ctrl.Result{}, nil
+			// the jobs of the deleted service in the namespace of ZAP have no owner reference
+			return ctrl.Result{}, analyzer.DeleteTargetJobs(r.Client, client.MatchingLabels{
+				analyzer.ServiceNameLabel:      req.Name,
+				analyzer.ServiceNamespaceLabel: req.Namespace,
+			})
 		}
 		return ctrl.Result{}, err
 	}
 
-	zaProxyCfg, err := k8sutil.GetServiceAnotations(&service, log)
+	resolved, err := k8sutil.ResolveService(ctx, r.Client, &service)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	zaProxyCfg, err := k8sutil.GetServiceAnotations(resolved, log)
 	if err != nil {
 		return ctrl.Result{}, nil
 	}
 
 	log.Info("service reconciler", "serrvice", service.Spec)
 
+	now := time.Now()
+	settings, err := getScanSettings(resolved)
+	if err != nil {
+		log.Error(err, "scan isn't started")
+		return ctrl.Result{}, r.setScanStatus(ctx, &service, invalidAnnotation(r.Recorder, &service, err), nil)
+	}
+
 	ann := securityv1alpha1.Dast{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      service.GetName(),
-			Namespace: zaProxyCfg["namespace"],
+			Name:        service.GetName(),
+			Namespace:   zaProxyCfg["namespace"],
+			Annotations: rescanAnnotations(&service, settings.schedule, now),
 		},
 		Spec: securityv1alpha1.DastSpec{
 			ZaProxy: securityv1alpha1.ZaProxy{
 				Name: zaProxyCfg["name"],
 			},
 			Analyzer: securityv1alpha1.Analyzer{
-				Image:   zaProxyCfg["analyzer_image"],
-				Name:    service.GetName(),
-				Target:  k8sutil.GetTargetService(&service),
-				Service: &service,
+				Image:       zaProxyCfg["analyzer_image"],
+				Name:        service.GetName(),
+				Target:      k8sutil.GetTargetService(resolved),
+				Service:     resolved,
+				Scope:       k8sutil.GetServiceScope(resolved),
+				ScanPolicy:  resolved.GetAnnotations()["dast.security.banzaicloud.io/scan-policy"],
+				MaxDuration: settings.maxDuration,
+				FailOn:      settings.failOn,
 			},
 		},
 	}
 
+	admitted, position, err := admitScan(ctx, r.Client, r.Scheduler, &ann, service.GetNamespace(), settings.priority, r.JobHistory)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !admitted {
+		return ctrl.Result{RequeueAfter: scheduler.RetryInterval}, r.updateQueueStatus(ctx, &service, position)
+	}
+
 	reconcilers := []resources.ComponentReconciler{
 		analyzer.New(r.Client, &ann),
 	}
@@ -93,11 +148,205 @@ func (r *ServiceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
-	return ctrl.Result{}, nil
+	if err := r.updateScanStatus(ctx, &service, &ann); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if service.GetLabels()[results.AcceptBaselineLabel] == "true" {
+		if err := r.acceptBaseline(ctx, &service, log); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: nextScheduledRun(&service, settings.schedule, now)}, nil
+}
+
+// updateScanStatus records the outcome of the analyzer job in the DastScan of the service
+func (r *ServiceReconciler) updateScanStatus(ctx context.Context, service *corev1.Service, dast *securityv1alpha1.Dast) error {
+	var job batchv1.Job
+	if err := r.Get(ctx, types.NamespacedName{Name: analyzer.JobName(dast), Namespace: dast.Namespace}, &job); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	result, err := analyzer.GetScanResult(r.Client, &job)
+	if err != nil {
+		return err
+	}
+	var scan *results.Scan
+	if result.Finished {
+		if scan, err = recordScan(ctx, r.Client, r.Results, r.Notifier, r.Exporters, r.Logs, dast, &job, result, r.Log); err != nil {
+			return err
+		}
+	}
+	return r.setScanStatus(ctx, service, result, scan)
+}
+
+// setScanStatus records the scan result and the stored scan, if any, in the DastScan of the service
+func (r *ServiceReconciler) setScanStatus(ctx context.Context, service *corev1.Service, result *analyzer.ScanResult, scan *results.Scan) error {
+	return updateDastScan(ctx, r.Client, service, corev1.SchemeGroupVersion.WithKind("Service"), func(status *securityv1alpha1.DastScanStatus) {
+		status.QueuePosition = 0
+		analyzer.SetScanConditions(&status.Conditions, result)
+		if scan != nil {
+			status.LastScanID = scan.ID
+			status.NewHigh = scan.NewHigh
+		}
+	})
+}
+
+// updateQueueStatus records the position of the queued analyzer job in the DastScan of the service
+func (r *ServiceReconciler) updateQueueStatus(ctx context.Context, service *corev1.Service, position int) error {
+	return updateDastScan(ctx, r.Client, service, corev1.SchemeGroupVersion.WithKind("Service"), func(status *securityv1alpha1.DastScanStatus) {
+		status.QueuePosition = position
+		analyzer.SetScanConditions(&status.Conditions, &analyzer.ScanResult{
+			Reason:  securityv1alpha1.ReasonQueued,
+			Message: fmt.Sprintf("analyzer job is queued at position %d", position),
+		})
+	})
+}
+
+// acceptBaseline snapshots the alerts of the latest scans of the ports of the service into its baseline and removes the accept-baseline label.
+// If the accept-alerts annotation is set, only the matching alerts are added to the baseline.
+// The acceptance is refused, unless the baseline webhook recorded the authorized user on the service.
+func (r *ServiceReconciler) acceptBaseline(ctx context.Context, service *corev1.Service, log logr.Logger) error {
+	if r.Results == nil {
+		return nil
+	}
+	acceptedBy := service.GetAnnotations()[results.AcceptedByAnnotation]
+	if acceptedBy == "" {
+		return r.rejectBaseline(ctx, service, "baseline acceptance isn't authorized by the baseline webhook", log)
+	}
+	filter, err := results.ParseAlertFilter(service.GetAnnotations())
+	if err != nil {
+		return r.rejectBaseline(ctx, service, err.Error(), log)
+	}
+	var scans []*results.Scan
+	if filter != nil && filter.ScanID != "" {
+		scan, err := r.Results.Get(ctx, service.GetNamespace(), service.GetName(), filter.ScanID)
+		if apierrors.IsNotFound(err) {
+			return r.rejectBaseline(ctx, service, fmt.Sprintf("scan %s of the service isn't found", filter.ScanID), log)
+		}
+		if err != nil {
+			return err
+		}
+		scans = append(scans, scan)
+	} else {
+		if scans, err = results.LatestPerPort(ctx, r.Results, service.GetNamespace(), service.GetName()); err != nil {
+			return err
+		}
+	}
+	if len(scans) == 0 {
+		log.Info("baseline is accepted after the first scan of the service")
+		return nil
+	}
+
+	var expiresAt *metav1.Time
+	if ttl, ok := service.GetAnnotations()[baselineTTLAnnotation]; ok {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Error(err, "invalid baseline ttl, the baseline doesn't expire", "ttl", ttl)
+		} else {
+			expiresAt = &metav1.Time{Time: time.Now().Add(duration)}
+		}
+	}
+
+	// the alerts of the latest scans of every port are accepted
+	baseline := results.NewBaseline(&results.Scan{Namespace: service.GetNamespace(), Service: service.GetName()}, expiresAt)
+	var ids []string
+	var alerts []results.Alert
+	for _, scan := range scans {
+		if filter != nil && filter.Port != 0 && scan.Port != filter.Port {
+			continue
+		}
+		ids = append(ids, scan.ID)
+		for _, alert := range scan.Alerts {
+			if filter == nil || filter.Matches(alert) {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	baseline.Spec.ScanID = strings.Join(ids, ",")
+	baseline.Spec.AcceptedBy = acceptedBy
+	baseline.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(service, corev1.SchemeGroupVersion.WithKind("Service"))})
+
+	var current securityv1alpha1.DastBaseline
+	err = r.Get(ctx, types.NamespacedName{Name: baseline.GetName(), Namespace: baseline.GetNamespace()}, &current)
+	added := results.AddToBaseline(baseline, alerts)
+	switch {
+	case apierrors.IsNotFound(err):
+		err = r.Create(ctx, baseline)
+	case err == nil && filter != nil:
+		// the matching alerts are added to the accepted ones
+		added = results.AddToBaseline(&current, alerts)
+		current.Spec.AcceptedBy = acceptedBy
+		if expiresAt != nil {
+			current.Spec.ExpiresAt = expiresAt
+		}
+		baseline = &current
+		err = r.Update(ctx, &current)
+	case err == nil:
+		current.Spec = baseline.Spec
+		err = r.Update(ctx, &current)
+	}
+	if err != nil {
+		return emperror.WrapWith(err, "failed to save baseline", "service", service.GetName())
+	}
+	log.Info("baseline accepted", "scan", strings.Join(ids, ","), "added", added, "alerts", len(baseline.Spec.Alerts), "acceptedBy", acceptedBy)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(service, corev1.EventTypeNormal, "BaselineAccepted", "%d alerts of scan %s accepted by %s", added, strings.Join(ids, ","), acceptedBy)
+	}
+	return r.removeAcceptBaseline(ctx, service)
+}
+
+// rejectBaseline records the refused acceptance of the baseline and removes the accept-baseline label
+func (r *ServiceReconciler) rejectBaseline(ctx context.Context, service *corev1.Service, message string, log logr.Logger) error {
+	log.Info("baseline isn't accepted", "reason", message)
+	if r.Recorder != nil {
+		r.Recorder.Event(service, corev1.EventTypeWarning, "BaselineRejected", message)
+	}
+	return r.removeAcceptBaseline(ctx, service)
+}
+
+// removeAcceptBaseline removes the accept-baseline label and the accepting user from the service
+func (r *ServiceReconciler) removeAcceptBaseline(ctx context.Context, service *corev1.Service) error {
+	labels := service.GetLabels()
+	delete(labels, results.AcceptBaselineLabel)
+	service.SetLabels(labels)
+	annotations := service.GetAnnotations()
+	delete(annotations, results.AcceptedByAnnotation)
+	delete(annotations, results.AcceptAlertsAnnotation)
+	service.SetAnnotations(annotations)
+	return r.Update(ctx, service)
+}
+
+// jobToService maps analyzer jobs to the scanned service
+func jobToService(obj handler.MapObject) []reconcile.Request {
+	labels := obj.Meta.GetLabels()
+	name, ok := labels[analyzer.ServiceNameLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: name, Namespace: labels[analyzer.ServiceNamespaceLabel]}},
+	}
+}
+
+// namespaceToServices maps namespaces to their services, so changed namespace defaults are applied
+func (r *ServiceReconciler) namespaceToServices(obj handler.MapObject) []reconcile.Request {
+	var services corev1.ServiceList
+	if err := r.List(context.Background(), &services, client.InNamespace(obj.Meta.GetName())); err != nil {
+		r.Log.Error(err, "failed to list services of namespace", "namespace", obj.Meta.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(services.Items))
+	for _, service := range services.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: service.GetName(), Namespace: service.GetNamespace()}})
+	}
+	return requests
 }
 
 func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Service{}).
+		Watches(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(jobToService)}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.namespaceToServices)}).
 		Complete(r)
 }
@@ -0,0 +1,276 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/emperror"
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+	"github.com/banzaicloud/dast-operator/pkg/scheduler"
+)
+
+// targetSelectorResync is the period of evaluating the target selectors again to pick up new services
+const targetSelectorResync = 10 * time.Minute
+
+// selectedTarget is a port of a service selected by the target selector of a Dast
+type selectedTarget struct {
+	service *corev1.Service
+	port    int32
+}
+
+// reconcileTargets runs an analyzer job for every port of the services selected by the target selector.
+// At most MaxConcurrent jobs are running at once, the remaining targets are pending until a job finishes.
+// The jobs are created when they're admitted by the scheduler, the targets are queued until then.
+func (r *DastReconciler) reconcileTargets(ctx context.Context, dast *securityv1alpha1.Dast, priority int, log logr.Logger) (ctrl.Result, error) {
+	targets, err := selectTargets(ctx, r.Client, dast, r.crossNamespace(dast))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	statuses := make([]securityv1alpha1.TargetStatus, len(targets))
+	targetDasts := make([]*securityv1alpha1.Dast, len(targets))
+	running := 0
+	for i, target := range targets {
+		targetDasts[i] = newTargetDast(dast, target)
+		statuses[i] = securityv1alpha1.TargetStatus{
+			Namespace: target.service.GetNamespace(),
+			Service:   target.service.GetName(),
+			Port:      target.port,
+			Job:       analyzer.JobName(targetDasts[i]),
+			Phase:     securityv1alpha1.ReasonPending,
+		}
+
+		var job batchv1.Job
+		err := r.Get(ctx, types.NamespacedName{Name: statuses[i].Job, Namespace: dast.GetNamespace()}, &job)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.updateTargetStatus(ctx, targetDasts[i], &job, &statuses[i], log); err != nil {
+			return ctrl.Result{}, err
+		}
+		if statuses[i].Phase == securityv1alpha1.ReasonScanInProgress {
+			running++
+		}
+	}
+
+	maxConcurrent := dast.Spec.Analyzer.TargetSelector.MaxConcurrent
+	queued := false
+	for i := range statuses {
+		if statuses[i].Phase != securityv1alpha1.ReasonPending {
+			continue
+		}
+		if maxConcurrent > 0 && running >= maxConcurrent {
+			break
+		}
+		admitted, position, err := admitScan(ctx, r.Client, r.Scheduler, targetDasts[i], statuses[i].Namespace, priority, r.JobHistory)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !admitted {
+			statuses[i].Phase = securityv1alpha1.ReasonQueued
+			statuses[i].QueuePosition = position
+			queued = true
+			continue
+		}
+		if err := analyzer.New(r.Client, targetDasts[i]).Reconcile(log.WithValues("service", statuses[i].Service, "namespace", statuses[i].Namespace, "port", statuses[i].Port)); err != nil {
+			return ctrl.Result{}, err
+		}
+		statuses[i].Phase = securityv1alpha1.ReasonScanInProgress
+		running++
+	}
+
+	dast.Status.Targets = statuses
+	analyzer.SetScanConditions(&dast.Status.Conditions, targetsResult(statuses))
+	if err := r.Status().Update(ctx, dast); err != nil {
+		return ctrl.Result{}, err
+	}
+	if queued {
+		return ctrl.Result{RequeueAfter: scheduler.RetryInterval}, nil
+	}
+	return ctrl.Result{RequeueAfter: targetSelectorResync}, nil
+}
+
+// updateTargetStatus records the outcome of the analyzer job of a selected target
+func (r *DastReconciler) updateTargetStatus(ctx context.Context, dast *securityv1alpha1.Dast, job *batchv1.Job, status *securityv1alpha1.TargetStatus, log logr.Logger) error {
+	result, err := analyzer.GetScanResult(r.Client, job)
+	if err != nil {
+		return err
+	}
+	if !result.Finished {
+		status.Phase = securityv1alpha1.ReasonScanInProgress
+		return nil
+	}
+	var scan *results.Scan
+	if r.subjectAllowed(dast) {
+		if scan, err = recordScan(ctx, r.Client, r.Results, r.Notifier, r.Exporters, r.Logs, dast, job, result, log); err != nil {
+			return err
+		}
+	}
+	if scan != nil {
+		status.ScanID = scan.ID
+	}
+	status.Phase = securityv1alpha1.ScanFailed
+	if result.Passed {
+		status.Phase = securityv1alpha1.ScanPassed
+	}
+	status.Reason = result.Reason
+	status.Message = result.Message
+	return nil
+}
+
+// selectTargets lists the ports of the services selected by the target selector sorted by namespace, name and port.
+// Services, which opted out of scanning, are skipped. Only the namespace of the Dast is selected, unless crossNamespace is set.
+func selectTargets(ctx context.Context, c client.Client, dast *securityv1alpha1.Dast, crossNamespace bool) ([]selectedTarget, error) {
+	selector := dast.Spec.Analyzer.TargetSelector
+
+	namespaces := []string{dast.GetNamespace()}
+	if selector.NamespaceSelector != nil {
+		namespaceSelector, err := metav1.LabelSelectorAsSelector(selector.NamespaceSelector)
+		if err != nil {
+			return nil, emperror.Wrap(err, "invalid namespace selector")
+		}
+		var list corev1.NamespaceList
+		if err := c.List(ctx, &list, client.MatchingLabelsSelector{Selector: namespaceSelector}); err != nil {
+			return nil, emperror.Wrap(err, "failed to list namespaces")
+		}
+		namespaces = namespaces[:0]
+		for _, namespace := range list.Items {
+			if crossNamespace || namespace.GetName() == dast.GetNamespace() {
+				namespaces = append(namespaces, namespace.GetName())
+			}
+		}
+	}
+
+	serviceSelector := labels.Everything()
+	if selector.ServiceSelector != nil {
+		var err error
+		serviceSelector, err = metav1.LabelSelectorAsSelector(selector.ServiceSelector)
+		if err != nil {
+			return nil, emperror.Wrap(err, "invalid service selector")
+		}
+	}
+
+	var targets []selectedTarget
+	for _, namespace := range namespaces {
+		var services corev1.ServiceList
+		if err := c.List(ctx, &services, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: serviceSelector}); err != nil {
+			return nil, emperror.WrapWith(err, "failed to list services", "namespace", namespace)
+		}
+		for i := range services.Items {
+			service := &services.Items[i]
+			if k8sutil.OptedOut(service) {
+				continue
+			}
+			for _, port := range service.Spec.Ports {
+				targets = append(targets, selectedTarget{service: service, port: port.Port})
+			}
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		a, b := targets[i], targets[j]
+		if a.service.GetNamespace() != b.service.GetNamespace() {
+			return a.service.GetNamespace() < b.service.GetNamespace()
+		}
+		if a.service.GetName() != b.service.GetName() {
+			return a.service.GetName() < b.service.GetName()
+		}
+		return a.port < b.port
+	})
+	return targets, nil
+}
+
+// newTargetDast returns the Dast of a selected target, which shares the analyzer settings of the selecting Dast
+func newTargetDast(dast *securityv1alpha1.Dast, target selectedTarget) *securityv1alpha1.Dast {
+	targetDast := dast.DeepCopy()
+	targetDast.Spec.Analyzer.Name = targetJobName(dast.Spec.Analyzer.Name, target)
+	targetDast.Spec.Analyzer.Target = k8sutil.GetTargetServicePort(target.service, target.port)
+	targetDast.Spec.Analyzer.Service = target.service
+	return targetDast
+}
+
+// targetJobName returns the name of the analyzer job of a selected target, which fits in a label value
+func targetJobName(name string, target selectedTarget) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", target.service.GetNamespace(), target.service.GetName(), target.port)))
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	return fmt.Sprintf("%s-%x", strings.TrimSuffix(name, "-"), sum[:6])
+}
+
+// targetsResult aggregates the results of the selected targets, the scan is finished when every target is finished
+func targetsResult(statuses []securityv1alpha1.TargetStatus) *analyzer.ScanResult {
+	if len(statuses) == 0 {
+		return &analyzer.ScanResult{
+			Reason:  securityv1alpha1.ReasonPending,
+			Message: "no services are selected",
+		}
+	}
+	var finished int
+	var failed []string
+	reason := securityv1alpha1.ReasonScanCompleted
+	for _, status := range statuses {
+		switch status.Phase {
+		case securityv1alpha1.ScanPassed:
+			finished++
+		case securityv1alpha1.ScanFailed:
+			finished++
+			failed = append(failed, fmt.Sprintf("%s/%s:%d", status.Namespace, status.Service, status.Port))
+			reason = status.Reason
+		}
+	}
+	if finished < len(statuses) {
+		return &analyzer.ScanResult{
+			Reason:  securityv1alpha1.ReasonScanInProgress,
+			Message: fmt.Sprintf("%d of %d targets finished", finished, len(statuses)),
+		}
+	}
+	if len(failed) > 0 {
+		return &analyzer.ScanResult{
+			Finished: true,
+			Reason:   reason,
+			Message:  fmt.Sprintf("%d of %d targets failed: %s", len(failed), len(statuses), strings.Join(failed, ", ")),
+		}
+	}
+	return &analyzer.ScanResult{
+		Finished: true,
+		Passed:   true,
+		Reason:   reason,
+		Message:  fmt.Sprintf("%d targets passed", len(statuses)),
+	}
+}
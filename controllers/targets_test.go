@@ -0,0 +1,139 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+func TestSelectTargets(t *testing.T) {
+	ctx := context.Background()
+	namespace := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"scan": "true"}}}
+	}
+	service := func(namespace, name string, annotations map[string]string, ports ...int32) *corev1.Service {
+		service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{"app": "web"},
+			Annotations: annotations,
+		}}
+		for _, port := range ports {
+			service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{Port: port})
+		}
+		return service
+	}
+	c := newFakeClient(t,
+		namespace("default"),
+		namespace("other"),
+		service("default", "web", nil, 8080, 80),
+		service("default", "api", nil, 8080),
+		service("default", "skipped", map[string]string{"dast.security.banzaicloud.io/enabled": "false"}, 80),
+		service("other", "web", nil, 80),
+	)
+	dast := &securityv1alpha1.Dast{ObjectMeta: metav1.ObjectMeta{Name: "dast", Namespace: "default"}}
+	dast.Spec.Analyzer.TargetSelector = &securityv1alpha1.TargetSelector{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"scan": "true"}},
+		ServiceSelector:   &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+	}
+
+	selected := func(crossNamespace bool) []string {
+		targets, err := selectTargets(ctx, c, dast, crossNamespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, target := range targets {
+			names = append(names, target.service.GetNamespace()+"/"+target.service.GetName()+fmt.Sprintf(":%d", target.port))
+		}
+		return names
+	}
+	if names := strings.Join(selected(false), ","); names != "default/api:8080,default/web:80,default/web:8080" {
+		t.Errorf("unexpected targets in the Dast namespace %s", names)
+	}
+	if names := strings.Join(selected(true), ","); names != "default/api:8080,default/web:80,default/web:8080,other/web:80" {
+		t.Errorf("unexpected targets in every namespace %s", names)
+	}
+
+	targets, err := selectTargets(ctx, c, dast, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dast.Spec.Analyzer.Name = strings.Repeat("a", 60)
+	targetDast := newTargetDast(dast, targets[0])
+	if targetDast.Spec.Analyzer.Service.GetName() != "api" || targetDast.Spec.Analyzer.Target == "" {
+		t.Errorf("unexpected target Dast %+v", targetDast.Spec.Analyzer)
+	}
+	if name := targetDast.Spec.Analyzer.Name; len(name) > 63 || name == targetJobName(dast.Spec.Analyzer.Name, targets[1]) {
+		t.Errorf("job name %q is too long or not unique", name)
+	}
+}
+
+func TestTargetsResult(t *testing.T) {
+	if result := targetsResult(nil); result.Finished || result.Reason != securityv1alpha1.ReasonPending {
+		t.Errorf("scan without targets is %+v", result)
+	}
+	statuses := []securityv1alpha1.TargetStatus{
+		{Namespace: "default", Service: "web", Port: 80, Phase: securityv1alpha1.ScanPassed, Reason: securityv1alpha1.ReasonScanCompleted},
+		{Namespace: "default", Service: "api", Port: 8080},
+	}
+	if result := targetsResult(statuses); result.Finished || result.Reason != securityv1alpha1.ReasonScanInProgress {
+		t.Errorf("scan with a running target is %+v", result)
+	}
+	statuses[1].Phase = securityv1alpha1.ScanPassed
+	if result := targetsResult(statuses); !result.Finished || !result.Passed {
+		t.Errorf("scan with passed targets is %+v", result)
+	}
+	statuses[1].Phase, statuses[1].Reason = securityv1alpha1.ScanFailed, securityv1alpha1.ReasonThresholdExceeded
+	if result := targetsResult(statuses); !result.Finished || result.Passed || result.Reason != securityv1alpha1.ReasonThresholdExceeded {
+		t.Errorf("scan with a failed target is %+v", result)
+	}
+}
+
+func TestSubjectAllowed(t *testing.T) {
+	recorder := record.NewFakeRecorder(1)
+	r := &DastReconciler{Log: zap.New(), Recorder: recorder}
+	dast := &securityv1alpha1.Dast{ObjectMeta: metav1.ObjectMeta{Name: "dast", Namespace: "default"}}
+	if !r.subjectAllowed(dast) {
+		t.Error("Dast without a subject is rejected")
+	}
+	dast.Spec.Analyzer.Service = &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	if !r.subjectAllowed(dast) {
+		t.Error("subject in the Dast namespace is rejected")
+	}
+	dast.Spec.Analyzer.Service.Namespace = "other"
+	if r.subjectAllowed(dast) {
+		t.Error("subject in another namespace is allowed")
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "SubjectRejected") {
+		t.Errorf("unexpected event %q", event)
+	}
+	r.ClusterScanNamespaces = []string{"default"}
+	if !r.subjectAllowed(dast) {
+		t.Error("subject in another namespace is rejected for a cluster scan namespace")
+	}
+}
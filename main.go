@@ -17,22 +17,34 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
+	"emperror.dev/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	extv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
 	"github.com/banzaicloud/dast-operator/controllers"
+	"github.com/banzaicloud/dast-operator/pkg/exporter"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/notifier"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+	"github.com/banzaicloud/dast-operator/pkg/resultserver"
+	"github.com/banzaicloud/dast-operator/pkg/scheduler"
 	"github.com/banzaicloud/dast-operator/webhooks"
 	// +kubebuilder:scaffold:imports
 )
@@ -56,14 +68,87 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var resultsAddr string
+	var resultsCertDir string
+	var resultsHistory int
+	var defectDojoURL string
+	var resourceBackends string
+	var defaultThresholds string
+	var serviceWebhook bool
+	var enforcementMode string
+	var failurePolicy string
+	var webhookCacheTTL time.Duration
+	var webhookTimeout time.Duration
+	var overrideMaxDuration time.Duration
+	var ingressScans bool
+	var autoEnrolZaProxy string
+	var ingressControllerService string
+	var scanMaxAge time.Duration
+	var defectDojoProductType string
+	var maxConcurrentScans int
+	var maxScansPerZaProxy int
+	var jobHistory int
+	var notifierAllowedHosts string
+	var clusterScanNamespaces string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&resultsAddr, "results-addr", "", "The address the scan results API binds to, the API is disabled if empty.")
+	flag.StringVar(&resultsCertDir, "results-cert-dir", "", "Directory of tls.crt and tls.key of the scan results API, it's required if the API is enabled.")
+	flag.IntVar(&resultsHistory, "results-history", 10, "Number of stored scan results kept per service.")
+	flag.StringVar(&defectDojoURL, "defectdojo-url", "", "URL of DefectDojo, the findings of stored scans are exported if set. The API key is read from DEFECTDOJO_API_KEY.")
+	flag.StringVar(&defectDojoProductType, "defectdojo-product-type", "Kubernetes", "Product type of the DefectDojo products created by the exporter.")
+	flag.StringVar(&resourceBackends, "ingress-resource-backends", string(k8sutil.ResourceBackendSkip), "Handling of ingress resource backends and route backends, which aren't services, by the webhooks, skip or deny.")
+	flag.StringVar(&defaultThresholds, "default-thresholds", "", "Thresholds of the webhooks for the risk levels without annotation, e.g. high=0,medium=5. Unset risk levels are 0.")
+	flag.BoolVar(&serviceWebhook, "service-webhook", false, "Deny services becoming LoadBalancer or NodePort, or getting external IPs, without a recent passing scan.")
+	flag.DurationVar(&scanMaxAge, "scan-max-age", 7*24*time.Hour, "Maximum age of the latest scan of the services accepted by the webhooks, 0 means no limit.")
+	flag.StringVar(&enforcementMode, "enforcement-mode", string(webhooks.EnforcementEnforce), "Handling of objects above the thresholds by the webhooks in namespaces without enforcement mode annotation: enforce, warn or audit.")
+	flag.StringVar(&failurePolicy, "webhook-failure-policy", string(webhooks.FailClosed), "Handling of objects by the webhooks, whose scan results can't be evaluated: open allows them with a warning, closed handles them like objects above the thresholds.")
+	flag.DurationVar(&webhookCacheTTL, "webhook-cache-ttl", 30*time.Second, "Time the summaries of the services are cached for by the webhooks, 0 disables the cache.")
+	flag.DurationVar(&webhookTimeout, "webhook-timeout", 5*time.Second, "Time limit of the evaluation of the scan results by the webhooks, it should be well under the timeout of the webhook configuration.")
+	flag.DurationVar(&overrideMaxDuration, "override-max-duration", 24*time.Hour, "Maximum expiry of break-glass overrides of denied admissions, 0 means no limit.")
+	flag.BoolVar(&ingressScans, "ingress-scans", false, "Scan the hosts of ingresses with the scan-hosts annotation end-to-end. Requires networking.k8s.io/v1 ingresses.")
+	flag.StringVar(&ingressControllerService, "ingress-controller-service", "", "Ingress controller service in namespace/name[:port] format, which the ingress hosts are scanned through. The hosts are scanned directly if it's empty.")
+	flag.StringVar(&autoEnrolZaProxy, "auto-enrol-zaproxy", "", "ZAP in namespace/name format, which scans the backend services without zaproxy annotation. The webhooks annotate such services and deny the object until the scan is stored. Services aren't enrolled if it's empty.")
+	flag.IntVar(&maxConcurrentScans, "max-concurrent-scans", 0, "Maximum number of analyzer jobs running at once in the cluster, 0 means no limit. Further jobs are queued.")
+	flag.IntVar(&maxScansPerZaProxy, "max-scans-per-zaproxy", 0, "Maximum number of analyzer jobs running at once per ZAP instance, 0 means no limit. Further jobs are queued.")
+	flag.IntVar(&jobHistory, "job-history", 3, "Number of analyzer jobs of previous runs kept per scan when a scan is run again with the rescan annotation.")
+	flag.StringVar(&notifierAllowedHosts, "notifier-allowed-hosts", "hooks.slack.com,*.webhook.office.com", "Comma separated hosts the notifications are sent to, *.example.com allows the subdomains of example.com. Notifiers with other hosts are rejected.")
+	flag.StringVar(&clusterScanNamespaces, "cluster-scan-namespaces", "", "Comma separated namespaces, whose Dasts can select services in other namespaces and store their results. Other Dasts only scan their own namespace.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	if policy := k8sutil.ResourceBackendPolicy(resourceBackends); policy != k8sutil.ResourceBackendSkip && policy != k8sutil.ResourceBackendDeny {
+		setupLog.Info("invalid ingress resource backend policy", "policy", resourceBackends)
+		os.Exit(1)
+	}
+	thresholds, err := k8sutil.ParseThresholds(defaultThresholds)
+	if err != nil {
+		setupLog.Error(err, "invalid default thresholds")
+		os.Exit(1)
+	}
+	mode, err := webhooks.ParseEnforcementMode(enforcementMode)
+	if err != nil {
+		setupLog.Error(err, "invalid enforcement mode")
+		os.Exit(1)
+	}
+	webhookFailurePolicy, err := webhooks.ParseFailurePolicy(failurePolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid webhook failure policy")
+		os.Exit(1)
+	}
+	var autoEnrol *types.NamespacedName
+	if autoEnrolZaProxy != "" {
+		parts := strings.Split(autoEnrolZaProxy, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			setupLog.Info("invalid auto-enrol zaproxy, must be namespace/name", "zaproxy", autoEnrolZaProxy)
+			os.Exit(1)
+		}
+		autoEnrol = &types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -76,22 +161,116 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the stored scans are signed with the key of the operator, so the scans created by the users of the namespaces aren't trusted
+	operatorNamespace := os.Getenv("POD_NAMESPACE")
+	var signingKey []byte
+	if operatorNamespace != "" {
+		signingKey, err = results.LoadSigningKey(context.Background(), mgr.GetClient(), mgr.GetAPIReader(), operatorNamespace)
+	} else {
+		setupLog.Info("POD_NAMESPACE isn't set, the scans are signed with a temporary key and the scans stored before a restart aren't trusted")
+		signingKey, err = results.NewSigningKey()
+	}
+	if err != nil {
+		setupLog.Error(err, "unable to load results signing key")
+		os.Exit(1)
+	}
+	resultStore := results.NewSignedConfigMapStore(mgr.GetClient(), mgr.GetAPIReader(), resultsHistory, signingKey)
+	var allowedHosts []string
+	for _, host := range strings.Split(notifierAllowedHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			allowedHosts = append(allowedHosts, host)
+		}
+	}
+	var crossNamespaces []string
+	for _, namespace := range strings.Split(clusterScanNamespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			crossNamespaces = append(crossNamespaces, namespace)
+		}
+	}
+	scanNotifier := notifier.New(mgr.GetClient(), allowedHosts, ctrl.Log.WithName("notifier"))
+	scanScheduler := scheduler.New(mgr.GetClient(), maxConcurrentScans, maxScansPerZaProxy)
+	var exporters []exporter.Exporter
+	if defectDojoURL != "" {
+		exporters = append(exporters, exporter.NewDefectDojo(exporter.DefectDojoConfig{
+			URL:         defectDojoURL,
+			APIKey:      os.Getenv("DEFECTDOJO_API_KEY"),
+			ProductType: defectDojoProductType,
+		}))
+	}
+	exportQueue := exporter.NewQueue(exporters, ctrl.Log.WithName("exporter"))
+	podLogs, err := k8sutil.NewPodLogs(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create pod logs client")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.DastReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Dast"),
-		Scheme: mgr.GetScheme(),
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("Dast"),
+		Scheme:     mgr.GetScheme(),
+		Results:    resultStore,
+		Notifier:   scanNotifier,
+		Exporters:  exportQueue,
+		Logs:       podLogs,
+		Scheduler:  scanScheduler,
+		JobHistory: jobHistory,
+		Recorder:   mgr.GetEventRecorderFor("dast-operator"),
+
+		ClusterScanNamespaces: crossNamespaces,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Dast")
 		os.Exit(1)
 	}
 	err = (&controllers.ServiceReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Service"),
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("Service"),
+		Results:    resultStore,
+		Notifier:   scanNotifier,
+		Exporters:  exportQueue,
+		Logs:       podLogs,
+		Scheduler:  scanScheduler,
+		JobHistory: jobHistory,
+		Recorder:   mgr.GetEventRecorderFor("dast-operator"),
 	}).SetupWithManager(mgr)
 	if err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Service")
 		os.Exit(1)
 	}
+	if ingressScans {
+		err = (&controllers.IngressReconciler{
+			Client:            mgr.GetClient(),
+			Log:               ctrl.Log.WithName("controllers").WithName("Ingress"),
+			Results:           resultStore,
+			Notifier:          scanNotifier,
+			Exporters:         exportQueue,
+			Logs:              podLogs,
+			Scheduler:         scanScheduler,
+			JobHistory:        jobHistory,
+			Recorder:          mgr.GetEventRecorderFor("dast-operator"),
+			ControllerService: ingressControllerService,
+		}).SetupWithManager(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+			os.Exit(1)
+		}
+	}
+
+	if resultsAddr != "" {
+		if resultsCertDir == "" {
+			setupLog.Error(errors.New("--results-cert-dir is required"), "the results API is served over TLS only")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&resultserver.Server{
+			Addr:    resultsAddr,
+			CertDir: resultsCertDir,
+			Client:  mgr.GetClient(),
+			Store:   resultStore,
+			Log:     ctrl.Log.WithName("results"),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up results server")
+			os.Exit(1)
+		}
+	}
 
 	// Setup webhooks
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
@@ -99,7 +278,48 @@ func main() {
 		hookServer := mgr.GetWebhookServer()
 
 		setupLog.Info("registering webhooks to the webhook server")
-		hookServer.Register("/ingress", &webhook.Admission{Handler: webhooks.NewIngressValidator(mgr.GetClient(), ctrl.Log.WithName("webhooks").WithName("Ingress"))})
+		validatorConfig := webhooks.ValidatorConfig{
+			Client:              mgr.GetClient(),
+			Store:               resultStore,
+			Notifier:            scanNotifier,
+			ResourceBackends:    k8sutil.ResourceBackendPolicy(resourceBackends),
+			DefaultThresholds:   thresholds,
+			Mode:                mode,
+			Recorder:            mgr.GetEventRecorderFor("dast-webhook"),
+			FailurePolicy:       webhookFailurePolicy,
+			CacheTTL:            webhookCacheTTL,
+			Timeout:             webhookTimeout,
+			ScanMaxAge:          scanMaxAge,
+			OverrideMaxDuration: overrideMaxDuration,
+			AutoEnrolZaProxy:    autoEnrol,
+		}
+		ingressConfig := validatorConfig
+		ingressConfig.Log = ctrl.Log.WithName("webhooks").WithName("Ingress")
+		hookServer.Register("/ingress", &webhook.Admission{Handler: webhooks.NewIngressValidator(ingressConfig)})
+		routeConfig := validatorConfig
+		routeConfig.Log = ctrl.Log.WithName("webhooks").WithName("Route")
+		hookServer.Register("/route", &webhook.Admission{Handler: webhooks.NewRouteValidator(routeConfig)})
+		exposureConfig := validatorConfig
+		exposureConfig.Log = ctrl.Log.WithName("webhooks").WithName("Exposure")
+		hookServer.Register("/exposure", &webhook.Admission{Handler: webhooks.NewExposureValidator(exposureConfig)})
+		serviceConfig := validatorConfig
+		serviceConfig.Log = ctrl.Log.WithName("webhooks").WithName("Service")
+		hookServer.Register("/service", &webhook.Admission{Handler: webhooks.NewServiceValidator(serviceConfig, serviceWebhook)})
+		baselineConfig := validatorConfig
+		baselineConfig.Log = ctrl.Log.WithName("webhooks").WithName("Baseline")
+		hookServer.Register("/baseline", &webhook.Admission{Handler: webhooks.NewBaselineMutator(baselineConfig)})
+
+		// the effective config of the webhooks is read by kubectl dast explain
+		if operatorNamespace != "" {
+			if err := mgr.Add(manager.RunnableFunc(func(<-chan struct{}) error {
+				return webhooks.PublishConfig(context.Background(), mgr.GetClient(), mgr.GetAPIReader(), operatorNamespace, validatorConfig)
+			})); err != nil {
+				setupLog.Error(err, "unable to publish webhook config")
+				os.Exit(1)
+			}
+		} else {
+			setupLog.Info("POD_NAMESPACE isn't set, the webhook config isn't published")
+		}
 	}
 
 	// +kubebuilder:scaffold:builder
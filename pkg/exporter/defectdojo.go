@@ -0,0 +1,272 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/emperror"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+const (
+	// DefectDojoProductLabel overrides the product of the namespace
+	DefectDojoProductLabel = "dast.security.banzaicloud.io/defectdojo-product"
+	// DefectDojoEngagementLabel overrides the engagement of the service
+	DefectDojoEngagementLabel = "dast.security.banzaicloud.io/defectdojo-engagement"
+
+	defectDojoScanType = "Generic Findings Import"
+)
+
+var defectDojoSeverities = map[string]string{
+	"High":          "High",
+	"Medium":        "Medium",
+	"Low":           "Low",
+	"Informational": "Info",
+}
+
+// DefectDojoConfig configures the DefectDojo exporter
+type DefectDojoConfig struct {
+	// URL of the DefectDojo instance
+	URL string
+	// APIKey is the API v2 key of the DefectDojo user
+	APIKey string
+	// ProductType of the products created by the exporter
+	ProductType string
+}
+
+// NewDefectDojo creates an exporter, which reimports the findings of every scan into the engagement of the service
+// and attaches the ZAP XML report of the scan to the test.
+// Findings are identified by the stable hash of the alerts, so DefectDojo deduplicates them across scans.
+// The product is the namespace and the engagement is the service, unless they are overridden by labels.
+func NewDefectDojo(config DefectDojoConfig) Exporter {
+	return &defectDojo{
+		config: config,
+		client: &http.Client{Timeout: time.Minute},
+	}
+}
+
+type defectDojo struct {
+	config DefectDojoConfig
+	client *http.Client
+}
+
+type defectDojoFindings struct {
+	Findings []defectDojoFinding `json:"findings"`
+}
+
+type defectDojoFinding struct {
+	Title            string               `json:"title"`
+	Severity         string               `json:"severity"`
+	Description      string               `json:"description"`
+	Mitigation       string               `json:"mitigation,omitempty"`
+	CWE              int                  `json:"cwe,omitempty"`
+	Date             string               `json:"date"`
+	UniqueIDFromTool string               `json:"unique_id_from_tool"`
+	VulnIDFromTool   string               `json:"vuln_id_from_tool"`
+	Param            string               `json:"param,omitempty"`
+	Payload          string               `json:"payload,omitempty"`
+	Endpoints        []defectDojoEndpoint `json:"endpoints,omitempty"`
+	StaticFinding    bool                 `json:"static_finding"`
+	DynamicFinding   bool                 `json:"dynamic_finding"`
+	Active           bool                 `json:"active"`
+	Verified         bool                 `json:"verified"`
+}
+
+type defectDojoEndpoint struct {
+	Protocol string `json:"protocol,omitempty"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Query    string `json:"query,omitempty"`
+}
+
+// defectDojoImport is the response of the reimport, it has the id of the test of the findings
+type defectDojoImport struct {
+	Test   int `json:"test"`
+	TestID int `json:"test_id"`
+}
+
+func (d *defectDojo) Name() string {
+	return "defectdojo"
+}
+
+func (d *defectDojo) Export(ctx context.Context, target Target, scan *results.Scan, reports map[string][]byte) error {
+	report, ok := reports[results.ReportXML]
+	if !ok {
+		return emperror.With(fmt.Errorf("scan has no %s report", results.ReportXML), "scan", scan.ID)
+	}
+	findings, err := json.Marshal(newDefectDojoFindings(scan))
+	if err != nil {
+		return emperror.Wrap(err, "failed to marshal findings")
+	}
+
+	product := target.Namespace
+	if label, ok := target.NamespaceLabels[DefectDojoProductLabel]; ok {
+		product = label
+	}
+	engagement := target.Service
+	if label, ok := target.ServiceLabels[DefectDojoEngagementLabel]; ok {
+		engagement = label
+	}
+
+	var imported defectDojoImport
+	if err := d.post(ctx, "/api/v2/reimport-scan/", map[string]string{
+		"scan_type":           defectDojoScanType,
+		"product_type_name":   d.config.ProductType,
+		"product_name":        product,
+		"engagement_name":     engagement,
+		"test_title":          "DAST " + target.Namespace + "/" + target.Service,
+		"auto_create_context": "true",
+		"close_old_findings":  "true",
+		"active":              "true",
+		"verified":            "false",
+		"scan_date":           scanDate(scan),
+	}, "dast-"+scan.ID+".json", findings, &imported); err != nil {
+		return emperror.With(emperror.Wrap(err, "failed to import scan into DefectDojo"), "scan", scan.ID)
+	}
+
+	test := imported.TestID
+	if test == 0 {
+		test = imported.Test
+	}
+	if test == 0 {
+		return emperror.With(fmt.Errorf("DefectDojo import response has no test"), "scan", scan.ID)
+	}
+	if err := d.post(ctx, fmt.Sprintf("/api/v2/tests/%d/files/", test), map[string]string{
+		"title": "ZAP report " + scan.ID,
+	}, "dast-"+scan.ID+".xml", report, nil); err != nil {
+		return emperror.With(emperror.Wrap(err, "failed to upload ZAP report to DefectDojo"), "scan", scan.ID, "test", test)
+	}
+	return nil
+}
+
+// post sends the fields and the file as a multipart form, the JSON response is decoded into response if it isn't nil
+func (d *defectDojo) post(ctx context.Context, path string, fields map[string]string, filename string, content []byte, response interface{}) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for key, value := range fields {
+		if err := w.WriteField(key, value); err != nil {
+			return emperror.Wrap(err, "failed to create request")
+		}
+	}
+	file, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return emperror.Wrap(err, "failed to create request")
+	}
+	if _, err := file.Write(content); err != nil {
+		return emperror.Wrap(err, "failed to create request")
+	}
+	if err := w.Close(); err != nil {
+		return emperror.Wrap(err, "failed to create request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(d.config.URL, "/")+path, &body)
+	if err != nil {
+		return emperror.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Token "+d.config.APIKey)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, message)
+	}
+	if response == nil {
+		return nil
+	}
+	return emperror.Wrap(json.NewDecoder(resp.Body).Decode(response), "invalid response")
+}
+
+func newDefectDojoFindings(scan *results.Scan) defectDojoFindings {
+	findings := defectDojoFindings{Findings: []defectDojoFinding{}}
+	seen := map[string]bool{}
+	for _, alert := range scan.Alerts {
+		hash := alert.Hash()
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		finding := defectDojoFinding{
+			Title:            alert.Name,
+			Severity:         defectDojoSeverities[alert.Risk],
+			Description:      alert.Description,
+			Mitigation:       alert.Solution,
+			Date:             scanDate(scan),
+			UniqueIDFromTool: hash,
+			VulnIDFromTool:   alert.PluginID,
+			Param:            alert.Param,
+			Payload:          alert.Evidence,
+			StaticFinding:    false,
+			DynamicFinding:   true,
+			Active:           true,
+		}
+		if finding.Severity == "" {
+			finding.Severity = "Info"
+		}
+		if finding.Description == "" {
+			finding.Description = alert.Name
+		}
+		if cwe, err := strconv.Atoi(alert.CWEID); err == nil {
+			finding.CWE = cwe
+		}
+		if endpoint, ok := newDefectDojoEndpoint(alert.URL); ok {
+			finding.Endpoints = []defectDojoEndpoint{endpoint}
+		}
+		findings.Findings = append(findings.Findings, finding)
+	}
+	return findings
+}
+
+func newDefectDojoEndpoint(rawURL string) (defectDojoEndpoint, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return defectDojoEndpoint{}, false
+	}
+	endpoint := defectDojoEndpoint{
+		Protocol: u.Scheme,
+		Host:     u.Hostname(),
+		Path:     strings.TrimPrefix(u.Path, "/"),
+		Query:    u.RawQuery,
+	}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		endpoint.Port = port
+	}
+	return endpoint, true
+}
+
+func scanDate(scan *results.Scan) string {
+	if scan.CompletionTime != nil {
+		return scan.CompletionTime.Format("2006-01-02")
+	}
+	return time.Now().Format("2006-01-02")
+}
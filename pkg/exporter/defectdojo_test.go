@@ -0,0 +1,263 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func testScan() *results.Scan {
+	completed := metav1.NewTime(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+	return &results.Scan{
+		ID:             "1234",
+		Namespace:      "test",
+		Service:        "app",
+		CompletionTime: &completed,
+		Alerts: []results.Alert{
+			{PluginID: "40018", Name: "SQL Injection", Risk: "High", Confidence: "Medium", URL: "http://app.test.svc.cluster.local:8080/search?q=1", Method: "GET", Param: "q", CWEID: "89"},
+			{PluginID: "10021", Name: "X-Content-Type-Options Header Missing", Risk: "Low", Confidence: "Medium", URL: "http://app.test.svc.cluster.local:8080/", Method: "GET"},
+		},
+	}
+}
+
+// defectDojoServer records the imported findings and the uploaded reports of the exports
+type defectDojoServer struct {
+	t        *testing.T
+	fields   map[string]string
+	findings defectDojoFindings
+	report   []byte
+	title    string
+}
+
+func (d *defectDojoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if auth := r.Header.Get("Authorization"); auth != "Token secret" {
+		d.t.Errorf("unexpected authorization %q", auth)
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		d.t.Fatal(err)
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	switch r.URL.Path {
+	case "/api/v2/reimport-scan/":
+		d.fields = map[string]string{}
+		for key, values := range r.MultipartForm.Value {
+			d.fields[key] = values[0]
+		}
+		d.findings = defectDojoFindings{}
+		if err := json.Unmarshal(content, &d.findings); err != nil {
+			d.t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"test":7,"test_id":7}`)
+	case "/api/v2/tests/7/files/":
+		d.title = r.FormValue("title")
+		d.report = content
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":1}`)
+	default:
+		d.t.Errorf("unexpected path %s", r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func TestDefectDojoExport(t *testing.T) {
+	dojo := &defectDojoServer{t: t}
+	server := httptest.NewServer(dojo)
+	defer server.Close()
+
+	exporter := NewDefectDojo(DefectDojoConfig{URL: server.URL + "/", APIKey: "secret", ProductType: "Kubernetes"})
+	target := Target{
+		Namespace:     "test",
+		Service:       "app",
+		ServiceLabels: map[string]string{DefectDojoEngagementLabel: "checkout"},
+	}
+	reports, err := results.Reports(testScan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Export(context.Background(), target, testScan(), reports); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedFields := map[string]string{
+		"scan_type":           defectDojoScanType,
+		"product_type_name":   "Kubernetes",
+		"product_name":        "test",
+		"engagement_name":     "checkout",
+		"auto_create_context": "true",
+		"scan_date":           "2021-03-01",
+	}
+	for key, value := range expectedFields {
+		if dojo.fields[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, dojo.fields[key])
+		}
+	}
+
+	if len(dojo.findings.Findings) != 2 {
+		t.Fatalf("unexpected findings %+v", dojo.findings)
+	}
+	sqli := dojo.findings.Findings[0]
+	if sqli.Severity != "High" || sqli.CWE != 89 || sqli.VulnIDFromTool != "40018" || sqli.Param != "q" || !sqli.DynamicFinding {
+		t.Errorf("unexpected finding %+v", sqli)
+	}
+	if sqli.UniqueIDFromTool != testScan().Alerts[0].Hash() {
+		t.Errorf("finding isn't identified by the alert hash: %s", sqli.UniqueIDFromTool)
+	}
+	if len(sqli.Endpoints) != 1 || sqli.Endpoints[0].Host != "app.test.svc.cluster.local" || sqli.Endpoints[0].Port != 8080 || sqli.Endpoints[0].Path != "search" {
+		t.Errorf("unexpected endpoints %+v", sqli.Endpoints)
+	}
+
+	// the ZAP report of the scan is attached to the test of the findings
+	var report struct {
+		Sites []struct {
+			Host   string `xml:"host,attr"`
+			Alerts []struct {
+				PluginID string `xml:"pluginid"`
+			} `xml:"alerts>alertitem"`
+		} `xml:"site"`
+	}
+	if err := xml.Unmarshal(dojo.report, &report); err != nil {
+		t.Fatal(err)
+	}
+	if dojo.title != "ZAP report 1234" || len(report.Sites) != 1 || len(report.Sites[0].Alerts) != 2 {
+		t.Errorf("unexpected report %q %+v", dojo.title, report)
+	}
+}
+
+func TestDefectDojoStableFindings(t *testing.T) {
+	dojo := &defectDojoServer{t: t}
+	server := httptest.NewServer(dojo)
+	defer server.Close()
+	exporter := NewDefectDojo(DefectDojoConfig{URL: server.URL, APIKey: "secret"})
+
+	var ids [][]string
+	for _, run := range []string{"1", "2"} {
+		scan := testScan()
+		scan.ID = run
+		// the evidence of the alerts changes across the runs
+		for i := range scan.Alerts {
+			scan.Alerts[i].Evidence = "evidence of run " + run
+		}
+		reports, err := results.Reports(scan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.Export(context.Background(), Target{Namespace: "test", Service: "app"}, scan, reports); err != nil {
+			t.Fatal(err)
+		}
+		var runIDs []string
+		for _, finding := range dojo.findings.Findings {
+			runIDs = append(runIDs, finding.UniqueIDFromTool)
+		}
+		ids = append(ids, runIDs)
+	}
+	if len(ids[0]) != 2 || ids[0][0] == ids[0][1] || ids[0][0] != ids[1][0] || ids[0][1] != ids[1][1] {
+		t.Errorf("the same alerts have different ids across runs: %v", ids)
+	}
+}
+
+func TestDefectDojoExportWithoutReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("scan without report is imported")
+	}))
+	defer server.Close()
+
+	exporter := NewDefectDojo(DefectDojoConfig{URL: server.URL, APIKey: "secret"})
+	if err := exporter.Export(context.Background(), Target{Namespace: "test", Service: "app"}, testScan(), map[string][]byte{}); err == nil {
+		t.Error("expected error without xml report")
+	}
+}
+
+func TestDefectDojoExportFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	reports, err := results.Reports(testScan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := NewDefectDojo(DefectDojoConfig{URL: server.URL, APIKey: "invalid"})
+	if err := exporter.Export(context.Background(), Target{Namespace: "test", Service: "app"}, testScan(), reports); err == nil {
+		t.Error("expected error on rejected import")
+	}
+}
+
+func TestAlertHashIsStable(t *testing.T) {
+	alert := testScan().Alerts[0]
+	changed := alert
+	changed.Evidence = "different evidence"
+	changed.Description = "different description"
+	if alert.Hash() != changed.Hash() {
+		t.Error("hash depends on fields, which aren't identifying the alert")
+	}
+	changed.Param = "other"
+	if alert.Hash() == changed.Hash() {
+		t.Error("alerts of different parameters have the same hash")
+	}
+}
+
+type recordingExporter struct {
+	exported chan string
+}
+
+func (e recordingExporter) Name() string {
+	return "recording"
+}
+
+func (e recordingExporter) Export(ctx context.Context, target Target, scan *results.Scan, reports map[string][]byte) error {
+	e.exported <- target.Namespace + "/" + target.Service + "/" + scan.ID
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	if NewQueue(nil, zap.New()) != nil {
+		t.Error("queue without exporters isn't nil")
+	}
+	var empty *Queue
+	empty.Export(Target{}, testScan(), nil)
+	empty.Wait()
+
+	e := recordingExporter{exported: make(chan string, 1)}
+	queue := NewQueue([]Exporter{e}, zap.New())
+	queue.Export(Target{Namespace: "test", Service: "app"}, testScan(), nil)
+	queue.Wait()
+	if exported := <-e.exported; exported != "test/app/1234" {
+		t.Errorf("unexpected export %s", exported)
+	}
+}
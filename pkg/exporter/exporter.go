@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+const (
+	// defaultTimeout limits the export of a scan to an exporter
+	defaultTimeout = 3 * time.Minute
+	// maxPending is the number of exports run at once, further exports are dropped
+	maxPending = 16
+)
+
+// Target describes where the findings of a scan belong to, it is mapped from the namespace and the service
+type Target struct {
+	Namespace       string
+	Service         string
+	NamespaceLabels map[string]string
+	ServiceLabels   map[string]string
+}
+
+// Exporter pushes the findings of completed scans to a vulnerability management system
+type Exporter interface {
+	// Name of the exporter
+	Name() string
+	// Export pushes the scan with its reports
+	Export(ctx context.Context, target Target, scan *results.Scan, reports map[string][]byte) error
+}
+
+// Queue pushes the scans to the exporters in the background, so the exports don't delay the reconcile loops
+type Queue struct {
+	Exporters []Exporter
+	Log       logr.Logger
+	// Timeout limits the export of a scan to an exporter
+	Timeout time.Duration
+
+	pending chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewQueue creates a Queue of the exporters, it returns nil without exporters
+func NewQueue(exporters []Exporter, log logr.Logger) *Queue {
+	if len(exporters) == 0 {
+		return nil
+	}
+	return &Queue{
+		Exporters: exporters,
+		Log:       log,
+		Timeout:   defaultTimeout,
+		pending:   make(chan struct{}, maxPending),
+	}
+}
+
+// Export pushes the scan to every exporter in the background.
+// Every export is limited to the timeout of the queue, failed exports are logged and not retried.
+func (q *Queue) Export(target Target, scan *results.Scan, reports map[string][]byte) {
+	if q == nil {
+		return
+	}
+	for _, e := range q.Exporters {
+		select {
+		case q.pending <- struct{}{}:
+		default:
+			q.Log.Info("too many pending exports, export is dropped", "exporter", e.Name(), "id", scan.ID)
+			continue
+		}
+		q.wg.Add(1)
+		go func(e Exporter) {
+			defer func() {
+				<-q.pending
+				q.wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), q.Timeout)
+			defer cancel()
+			if err := e.Export(ctx, target, scan, reports); err != nil {
+				q.Log.Error(err, "failed to export scan", "exporter", e.Name(), "id", scan.ID)
+				return
+			}
+			q.Log.Info("scan exported", "exporter", e.Name(), "id", scan.ID)
+		}(e)
+	}
+}
+
+// Wait waits until the pending exports are finished
+func (q *Queue) Wait() {
+	if q == nil {
+		return
+	}
+	q.wg.Wait()
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+const (
+	// EnabledLabel opts every service of the namespace in for scanning with the defaults of the namespace annotations
+	EnabledLabel = "dast.security.banzaicloud.io/enabled"
+	// EnabledAnnotation opts a service out of the namespace defaults if it's false
+	EnabledAnnotation = "dast.security.banzaicloud.io/enabled"
+)
+
+// inheritedAnnotations are the annotations of opted in namespaces, which are the defaults of the objects in the namespace
+var inheritedAnnotations = []string{
+	"dast.security.banzaicloud.io/zaproxy",
+	"dast.security.banzaicloud.io/zaproxy-namespace",
+	"dast.security.banzaicloud.io/analyzer_image",
+	"dast.security.banzaicloud.io/scan-policy",
+	"dast.security.banzaicloud.io/scope-include",
+	"dast.security.banzaicloud.io/scope-exclude",
+	"dast.security.banzaicloud.io/technologies",
+	"dast.security.banzaicloud.io/max-duration",
+	"dast.security.banzaicloud.io/fail-on",
+	"dast.security.banzaicloud.io/deny-on",
+	"dast.security.banzaicloud.io/high",
+	"dast.security.banzaicloud.io/medium",
+	"dast.security.banzaicloud.io/low",
+	"dast.security.banzaicloud.io/informational",
+	"dast.security.banzaicloud.io/scan-priority",
+	"dast.security.banzaicloud.io/schedule",
+}
+
+// OptedOut reports whether the object opted out of the namespace defaults
+func OptedOut(obj metav1.Object) bool {
+	return obj.GetAnnotations()[EnabledAnnotation] == "false"
+}
+
+// ResolveAnnotations returns the annotations of the object merged with the defaults of its namespace.
+// The defaults are the inherited annotations of namespaces with the enabled label, the annotations of the object override them.
+// Objects, which opted out, don't inherit the defaults.
+func ResolveAnnotations(ctx context.Context, c client.Reader, obj metav1.Object) (map[string]string, error) {
+	annotations := map[string]string{}
+	if !OptedOut(obj) && obj.GetNamespace() != "" {
+		var namespace corev1.Namespace
+		if err := c.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, &namespace); err != nil {
+			return nil, errors.WrapIfWithDetails(err, "failed to get namespace defaults", "namespace", obj.GetNamespace())
+		}
+		if namespace.GetLabels()[EnabledLabel] == "true" {
+			for _, key := range inheritedAnnotations {
+				if value, ok := namespace.GetAnnotations()[key]; ok {
+					annotations[key] = value
+				}
+			}
+		}
+	}
+	for key, value := range obj.GetAnnotations() {
+		annotations[key] = value
+	}
+	return annotations, nil
+}
+
+// ResolveService returns a copy of the service with the annotations merged with the defaults of its namespace.
+// Services, which can't be scanned, don't inherit the defaults.
+func ResolveService(ctx context.Context, c client.Reader, service *corev1.Service) (*corev1.Service, error) {
+	if !scannable(service) {
+		return service.DeepCopy(), nil
+	}
+	annotations, err := ResolveAnnotations(ctx, c, service)
+	if err != nil {
+		return nil, err
+	}
+	resolved := service.DeepCopy()
+	resolved.SetAnnotations(annotations)
+	return resolved, nil
+}
+
+// scannable reports whether the service can be scanned with the namespace defaults.
+// Headless services, services without cluster IP or ports have no scan target, and the ZAP services of Dasts aren't scanned.
+func scannable(service *corev1.Service) bool {
+	if service.Spec.ClusterIP == "" || service.Spec.ClusterIP == corev1.ClusterIPNone || len(service.Spec.Ports) == 0 {
+		return false
+	}
+	if owner := metav1.GetControllerOf(service); owner != nil && owner.APIVersion == securityv1alpha1.GroupVersion.String() && owner.Kind == "Dast" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+func TestResolveAnnotations(t *testing.T) {
+	defaults := map[string]string{
+		"dast.security.banzaicloud.io/zaproxy":           "dast-test",
+		"dast.security.banzaicloud.io/zaproxy-namespace": "zaproxy",
+		"dast.security.banzaicloud.io/high":              "1",
+		"dast.security.banzaicloud.io/accept-baseline":   "true",
+	}
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "enabled", Labels: map[string]string{EnabledLabel: "true"}, Annotations: defaults}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "disabled", Annotations: defaults}},
+	)
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		expected    map[string]string
+	}{
+		{
+			name:      "inherited",
+			namespace: "enabled",
+			annotations: map[string]string{
+				"dast.security.banzaicloud.io/high": "0",
+			},
+			expected: map[string]string{
+				"dast.security.banzaicloud.io/zaproxy":           "dast-test",
+				"dast.security.banzaicloud.io/zaproxy-namespace": "zaproxy",
+				"dast.security.banzaicloud.io/high":              "0",
+			},
+		},
+		{
+			name:      "opted out",
+			namespace: "enabled",
+			annotations: map[string]string{
+				EnabledAnnotation: "false",
+			},
+			expected: map[string]string{
+				EnabledAnnotation: "false",
+			},
+		},
+		{
+			name:        "namespace not opted in",
+			namespace:   "disabled",
+			annotations: nil,
+			expected:    map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: test.namespace, Annotations: test.annotations}}
+			annotations, err := ResolveAnnotations(context.Background(), c, service)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(annotations, test.expected) {
+				t.Errorf("unexpected annotations %v", annotations)
+			}
+		})
+	}
+}
+
+func TestResolveService(t *testing.T) {
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "enabled",
+		Labels:      map[string]string{EnabledLabel: "true"},
+		Annotations: map[string]string{"dast.security.banzaicloud.io/zaproxy": "dast-test"},
+	}})
+	ports := []corev1.ServicePort{{Port: 80}}
+	zap := metav1.ObjectMeta{Name: "dast-test", Namespace: "enabled", OwnerReferences: []metav1.OwnerReference{
+		*metav1.NewControllerRef(&metav1.ObjectMeta{Name: "dast-test", UID: "uid"}, securityv1alpha1.GroupVersion.WithKind("Dast")),
+	}}
+
+	tests := []struct {
+		name      string
+		service   *corev1.Service
+		inherited bool
+	}{
+		{name: "service", service: &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "enabled"}, Spec: corev1.ServiceSpec{ClusterIP: "10.0.0.1", Ports: ports}}, inherited: true},
+		{name: "headless", service: &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "enabled"}, Spec: corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone, Ports: ports}}},
+		{name: "without ports", service: &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "enabled"}, Spec: corev1.ServiceSpec{ClusterIP: "10.0.0.1"}}},
+		{name: "external name", service: &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "enabled"}, Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, Ports: ports}}},
+		{name: "zap", service: &corev1.Service{ObjectMeta: zap, Spec: corev1.ServiceSpec{ClusterIP: "10.0.0.2", Ports: ports}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := ResolveService(context.Background(), c, test.service)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := resolved.GetAnnotations()["dast.security.banzaicloud.io/zaproxy"]; ok != test.inherited {
+				t.Errorf("inherited is %v, expected %v", ok, test.inherited)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ExposureExtractor returns the backend services of an object exposing services, with their name, port and optionally namespace
+type ExposureExtractor func(obj *unstructured.Unstructured, c client.Client, policy ResourceBackendPolicy, log logr.Logger) ([]map[string]string, error)
+
+var exposureExtractors = map[schema.GroupKind]ExposureExtractor{}
+
+// RegisterExposureExtractor registers the extractor of a kind, it replaces the extractor registered before
+func RegisterExposureExtractor(gk schema.GroupKind, extractor ExposureExtractor) {
+	exposureExtractors[gk] = extractor
+}
+
+// ExposureKinds returns the kinds with registered extractor
+func ExposureKinds() []schema.GroupKind {
+	kinds := make([]schema.GroupKind, 0, len(exposureExtractors))
+	for gk := range exposureExtractors {
+		kinds = append(kinds, gk)
+	}
+	return kinds
+}
+
+// GetExposedBackendServices returns the backend services of an object using the extractor registered for its kind
+func GetExposedBackendServices(obj *unstructured.Unstructured, c client.Client, policy ResourceBackendPolicy, log logr.Logger) ([]map[string]string, error) {
+	gk := obj.GroupVersionKind().GroupKind()
+	extractor, ok := exposureExtractors[gk]
+	if !ok {
+		return nil, errors.Errorf("unsupported kind %s", gk)
+	}
+	return extractor(obj, c, policy, log)
+}
+
+func init() {
+	for _, group := range []string{"extensions", "networking.k8s.io"} {
+		RegisterExposureExtractor(schema.GroupKind{Group: group, Kind: "Ingress"}, GetIngressBackendServices)
+	}
+	for _, kind := range []string{"HTTPRoute", "GRPCRoute"} {
+		RegisterExposureExtractor(schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: kind}, func(obj *unstructured.Unstructured, _ client.Client, policy ResourceBackendPolicy, log logr.Logger) ([]map[string]string, error) {
+			return GetRouteBackendServices(obj, policy, log)
+		})
+	}
+	RegisterExposureExtractor(schema.GroupKind{Group: "networking.istio.io", Kind: "VirtualService"}, GetVirtualServiceBackendServices)
+	RegisterExposureExtractor(schema.GroupKind{Group: "route.openshift.io", Kind: "Route"}, GetOpenShiftRouteBackendServices)
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+)
+
+func TestGetExposedBackendServices(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test"},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromString("web")}, {Name: "metrics", Port: 9090, TargetPort: intstr.FromInt(9090)}},
+			},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "single", Namespace: "other"},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Port: 8080}},
+			},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	)
+
+	tests := []struct {
+		name     string
+		obj      string
+		policy   ResourceBackendPolicy
+		expected []map[string]string
+		err      bool
+	}{
+		{
+			name: "virtual service bound to a gateway",
+			obj: `
+apiVersion: networking.istio.io/v1beta1
+kind: VirtualService
+spec:
+  gateways:
+  - istio-system/public
+  hosts:
+  - app.example.com
+  http:
+  - route:
+    - destination:
+        host: app
+        port:
+          number: 80
+    - destination:
+        host: single.other.svc.cluster.local
+  tcp:
+  - route:
+    - destination:
+        host: app.test.svc
+        port:
+          number: 80
+    - destination:
+        host: api.example.com
+        port:
+          number: 443`,
+			expected: []map[string]string{
+				{"name": "app", "namespace": "test", "port": "80"},
+				{"name": "single", "namespace": "other", "port": "8080"},
+			},
+		},
+		{
+			name: "virtual service of the mesh",
+			obj: `
+apiVersion: networking.istio.io/v1beta1
+kind: VirtualService
+spec:
+  hosts:
+  - app
+  http:
+  - route:
+    - destination:
+        host: app`,
+			expected: []map[string]string{},
+		},
+		{
+			name: "virtual service with name.namespace destinations",
+			obj: `
+apiVersion: networking.istio.io/v1beta1
+kind: VirtualService
+spec:
+  gateways:
+  - public
+  http:
+  - route:
+    - destination:
+        host: single.other
+    - destination:
+        host: api.example`,
+			expected: []map[string]string{
+				{"name": "single", "namespace": "other", "port": "8080"},
+			},
+		},
+		{
+			name: "virtual service with name.namespace destination of a missing namespace denied",
+			obj: `
+apiVersion: networking.istio.io/v1beta1
+kind: VirtualService
+spec:
+  gateways:
+  - public
+  http:
+  - route:
+    - destination:
+        host: api.example`,
+			policy: ResourceBackendDeny,
+			err:    true,
+		},
+		{
+			name: "virtual service with gateways of the route matches",
+			obj: `
+apiVersion: networking.istio.io/v1beta1
+kind: VirtualService
+spec:
+  gateways:
+  - public
+  - mesh
+  http:
+  - match:
+    - gateways:
+      - mesh
+    route:
+    - destination:
+        host: single.other.svc
+  - match:
+    - uri:
+        prefix: /api
+    route:
+    - destination:
+        host: app
+        port:
+          number: 80`,
+			expected: []map[string]string{
+				{"name": "app", "namespace": "test", "port": "80"},
+			},
+		},
+		{
+			name: "virtual service of the mesh with a route match bound to a gateway",
+			obj: `
+apiVersion: networking.istio.io/v1beta1
+kind: VirtualService
+spec:
+  http:
+  - route:
+    - destination:
+        host: app
+        port:
+          number: 80
+  - match:
+    - gateways:
+      - istio-system/public
+    route:
+    - destination:
+        host: single.other`,
+			expected: []map[string]string{
+				{"name": "single", "namespace": "other", "port": "8080"},
+			},
+		},
+		{
+			name: "virtual service with external destination denied",
+			obj: `
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+spec:
+  gateways:
+  - public
+  http:
+  - route:
+    - destination:
+        host: api.example.com`,
+			policy: ResourceBackendDeny,
+			err:    true,
+		},
+		{
+			name: "openshift route with named target port and alternate backend",
+			obj: `
+apiVersion: route.openshift.io/v1
+kind: Route
+spec:
+  host: app.example.com
+  to:
+    kind: Service
+    name: app
+    weight: 90
+  alternateBackends:
+  - kind: Service
+    name: app
+    weight: 10
+  port:
+    targetPort: web`,
+			expected: []map[string]string{{"name": "app", "port": "80"}},
+		},
+		{
+			name: "openshift route with numeric target port",
+			obj: `
+apiVersion: route.openshift.io/v1
+kind: Route
+spec:
+  to:
+    kind: Service
+    name: app
+  port:
+    targetPort: 9090`,
+			expected: []map[string]string{{"name": "app", "port": "9090"}},
+		},
+		{
+			name: "unsupported kind",
+			obj: `
+apiVersion: example.com/v1
+kind: Exposure`,
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal([]byte(test.obj), &obj.Object); err != nil {
+				t.Fatal(err)
+			}
+			obj.SetNamespace("test")
+			backends, err := GetExposedBackendServices(obj, c, test.policy, zap.New(zap.UseDevMode(true)))
+			if test.err {
+				if err == nil {
+					t.Errorf("expected error, got %v", backends)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(backends, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, backends)
+			}
+		})
+	}
+}
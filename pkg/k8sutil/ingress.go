@@ -0,0 +1,166 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResourceBackendPolicy decides how ingress backends referencing resources instead of services are handled
+type ResourceBackendPolicy string
+
+const (
+	// ResourceBackendSkip ignores resource backends, they aren't scanned
+	ResourceBackendSkip ResourceBackendPolicy = "skip"
+	// ResourceBackendDeny rejects ingresses with resource backends
+	ResourceBackendDeny ResourceBackendPolicy = "deny"
+)
+
+// ErrResourceBackend is returned for ingresses with resource backends if they are denied by the policy
+var ErrResourceBackend = errors.Sentinel("ingress has resource backends")
+
+// GetIngressBackendServices returns the deduplicated backend services of a networking.k8s.io/v1 or v1beta1 ingress
+// with their name and port. The default backend is included, named ports are resolved through the service.
+func GetIngressBackendServices(ingress *unstructured.Unstructured, c client.Client, policy ResourceBackendPolicy, log logr.Logger) ([]map[string]string, error) {
+	log.Info("ingress", "ingress", ingress)
+	backends := []map[string]string{}
+	seen := map[string]bool{}
+
+	add := func(backend map[string]interface{}) error {
+		name, port, err := ingressBackendService(backend, ingress.GetNamespace(), c)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			if policy == ResourceBackendDeny {
+				return ErrResourceBackend
+			}
+			log.Info("skipping resource backend", "backend", backend)
+			return nil
+		}
+		if seen[name+":"+port] {
+			return nil
+		}
+		seen[name+":"+port] = true
+		backends = append(backends, map[string]string{"name": name, "port": port})
+		return nil
+	}
+
+	for _, field := range []string{"defaultBackend", "backend"} {
+		if backend, ok, _ := unstructured.NestedMap(ingress.Object, "spec", field); ok {
+			if err := add(backend); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	rules, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// rules without http are served by the default backend
+		paths, _, _ := unstructured.NestedSlice(ruleMap, "http", "paths")
+		for _, path := range paths {
+			pathMap, ok := path.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			backend, ok, _ := unstructured.NestedMap(pathMap, "backend")
+			if !ok {
+				return nil, errors.New("value not found: backend")
+			}
+			if err := add(backend); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return backends, nil
+}
+
+// ingressBackendService returns the service name and port of a backend, the name is empty for resource backends
+func ingressBackendService(backend map[string]interface{}, namespace string, c client.Client) (string, string, error) {
+	if _, ok := backend["resource"]; ok {
+		return "", "", nil
+	}
+
+	// networking.k8s.io/v1
+	if name, ok, _ := unstructured.NestedString(backend, "service", "name"); ok {
+		if number, ok, _ := unstructured.NestedFieldNoCopy(backend, "service", "port", "number"); ok {
+			port, err := portNumber(number)
+			return name, port, err
+		}
+		if portName, ok, _ := unstructured.NestedString(backend, "service", "port", "name"); ok {
+			port, err := resolvePortName(name, namespace, portName, c)
+			return name, port, err
+		}
+		return "", "", errors.Errorf("value not found: port of service %s", name)
+	}
+
+	// networking.k8s.io/v1beta1 and extensions/v1beta1
+	name, ok, _ := unstructured.NestedString(backend, "serviceName")
+	if !ok {
+		return "", "", errors.New("value not found: service name")
+	}
+	servicePort, ok, _ := unstructured.NestedFieldNoCopy(backend, "servicePort")
+	if !ok {
+		return "", "", errors.Errorf("value not found: port of service %s", name)
+	}
+	if portName, ok := servicePort.(string); ok {
+		if _, err := strconv.Atoi(portName); err == nil {
+			return name, portName, nil
+		}
+		port, err := resolvePortName(name, namespace, portName, c)
+		return name, port, err
+	}
+	port, err := portNumber(servicePort)
+	return name, port, err
+}
+
+func portNumber(value interface{}) (string, error) {
+	switch number := value.(type) {
+	case int64:
+		return strconv.FormatInt(number, 10), nil
+	case int:
+		return strconv.Itoa(number), nil
+	case float64:
+		return strconv.Itoa(int(number)), nil
+	default:
+		return "", errors.Errorf("invalid service port %v", value)
+	}
+}
+
+// resolvePortName returns the number of a named service port
+func resolvePortName(service, namespace, portName string, c client.Client) (string, error) {
+	k8sService, err := GetServiceByName(service, namespace, c)
+	if err != nil {
+		return "", err
+	}
+	for _, port := range k8sService.Spec.Ports {
+		if port.Name == portName {
+			return strconv.Itoa(int(port.Port)), nil
+		}
+	}
+	return "", errors.Errorf("port %s not found in service %s", portName, service)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+// IngressHost is a host of the rules of an ingress with its paths and backend services
+type IngressHost struct {
+	Host string
+	// TLS is true if the host is listed in the TLS section of the ingress
+	TLS      bool
+	Paths    []string
+	Backends []string
+}
+
+// GetIngressHosts returns the hosts of the rules of the ingress in the order of the rules, rules without host can't be scanned end-to-end
+func GetIngressHosts(ingress *networkingv1.Ingress) []IngressHost {
+	tls := map[string]bool{}
+	for _, t := range ingress.Spec.TLS {
+		for _, host := range t.Hosts {
+			tls[host] = true
+		}
+	}
+
+	var hosts []IngressHost
+	index := map[string]int{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host == "" {
+			continue
+		}
+		i, ok := index[rule.Host]
+		if !ok {
+			i = len(hosts)
+			index[rule.Host] = i
+			hosts = append(hosts, IngressHost{Host: rule.Host, TLS: tls[rule.Host]})
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			hosts[i].Paths = appendUnique(hosts[i].Paths, path.Path)
+			if path.Backend.Service != nil {
+				hosts[i].Backends = appendUnique(hosts[i].Backends, path.Backend.Service.Name)
+			}
+		}
+	}
+	for i := range hosts {
+		if len(hosts[i].Backends) == 0 && ingress.Spec.DefaultBackend != nil && ingress.Spec.DefaultBackend.Service != nil {
+			hosts[i].Backends = []string{ingress.Spec.DefaultBackend.Service.Name}
+		}
+		sort.Strings(hosts[i].Backends)
+	}
+	return hosts
+}
+
+// IngressHostTarget returns the scan target of an ingress host and the ingress controller service, which the host is resolved to.
+// The controller is in namespace/name[:port] format, the host is scanned directly if it's empty.
+// The target is the host itself in both cases, so the requests have the Host header and SNI of the host.
+func IngressHostTarget(host IngressHost, controller string) (string, *types.NamespacedName, error) {
+	if controller == "" {
+		scheme := "http"
+		if host.TLS {
+			scheme = "https"
+		}
+		return scheme + "://" + host.Host, nil, nil
+	}
+
+	port := 80
+	if i := strings.LastIndex(controller, ":"); i >= 0 {
+		var err error
+		if port, err = strconv.Atoi(controller[i+1:]); err != nil {
+			return "", nil, errors.WrapIfWithDetails(err, "invalid ingress controller port", "controller", controller)
+		}
+		controller = controller[:i]
+	}
+	parts := strings.Split(controller, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", nil, errors.NewWithDetails("invalid ingress controller service, must be namespace/name[:port]", "controller", controller)
+	}
+	service := &types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	switch port {
+	case 80:
+		return "http://" + host.Host, service, nil
+	case 443:
+		return "https://" + host.Host, service, nil
+	default:
+		return fmt.Sprintf("http://%s:%d", host.Host, port), service, nil
+	}
+}
+
+// IngressHostScope restricts the scan of the target to the paths of the host, the scope isn't restricted if a path covers the whole host
+func IngressHostScope(host IngressHost, target string) *securityv1alpha1.Scope {
+	var include []string
+	for _, path := range host.Paths {
+		if path == "" || path == "/" {
+			return nil
+		}
+		include = append(include, "^"+regexp.QuoteMeta(target+path)+".*")
+	}
+	if len(include) == 0 {
+		return nil
+	}
+	return &securityv1alpha1.Scope{Include: include}
+}
+
+func appendUnique(items []string, item string) []string {
+	for _, i := range items {
+		if i == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
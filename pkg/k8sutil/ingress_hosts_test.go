@@ -0,0 +1,113 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+func TestGetIngressHosts(t *testing.T) {
+	ingress := &networkingv1.Ingress{}
+	if err := yaml.Unmarshal([]byte(`
+spec:
+  defaultBackend:
+    service:
+      name: default
+  tls:
+  - hosts: [shop.example.com]
+  rules:
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /api
+        backend:
+          service:
+            name: api
+      - path: /
+        backend:
+          service:
+            name: web
+  - host: shop.example.com
+    http:
+      paths:
+      - path: /api
+        backend:
+          service:
+            name: api
+  - http:
+      paths:
+      - path: /
+        backend:
+          service:
+            name: web
+  - host: static.example.com
+`), ingress); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []IngressHost{
+		{Host: "shop.example.com", TLS: true, Paths: []string{"/api", "/"}, Backends: []string{"api", "web"}},
+		{Host: "static.example.com", Backends: []string{"default"}},
+	}
+	if hosts := GetIngressHosts(ingress); !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("unexpected hosts %+v", hosts)
+	}
+}
+
+func TestIngressHostTarget(t *testing.T) {
+	tests := []struct {
+		host       IngressHost
+		controller string
+		target     string
+		service    string
+		err        bool
+	}{
+		{host: IngressHost{Host: "shop.example.com"}, target: "http://shop.example.com"},
+		{host: IngressHost{Host: "shop.example.com", TLS: true}, target: "https://shop.example.com"},
+		{host: IngressHost{Host: "shop.example.com"}, controller: "ingress-nginx/controller", target: "http://shop.example.com", service: "ingress-nginx/controller"},
+		{host: IngressHost{Host: "shop.example.com", TLS: true}, controller: "ingress-nginx/controller:443", target: "https://shop.example.com", service: "ingress-nginx/controller"},
+		{host: IngressHost{Host: "shop.example.com"}, controller: "ingress-nginx/controller:8080", target: "http://shop.example.com:8080", service: "ingress-nginx/controller"},
+		{host: IngressHost{Host: "shop.example.com"}, controller: "controller", err: true},
+		{host: IngressHost{Host: "shop.example.com"}, controller: "ingress-nginx/controller:http", err: true},
+	}
+	for _, test := range tests {
+		target, service, err := IngressHostTarget(test.host, test.controller)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v", test.controller, err)
+			continue
+		}
+		if target != test.target || (service == nil) != (test.service == "") || (service != nil && service.String() != test.service) {
+			t.Errorf("%s: unexpected target %s and controller service %v", test.controller, target, service)
+		}
+	}
+}
+
+func TestIngressHostScope(t *testing.T) {
+	if scope := IngressHostScope(IngressHost{Paths: []string{"/api", "/"}}, "http://shop.example.com"); scope != nil {
+		t.Errorf("root path is restricted: %v", scope)
+	}
+	expected := &securityv1alpha1.Scope{Include: []string{`^http://shop\.example\.com/api.*`}}
+	if scope := IngressHostScope(IngressHost{Paths: []string{"/api"}}, "http://shop.example.com"); !reflect.DeepEqual(scope, expected) {
+		t.Errorf("unexpected scope %v", scope)
+	}
+}
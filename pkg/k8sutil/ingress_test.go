@@ -0,0 +1,192 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+)
+
+func TestGetIngressBackendServices(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9090}},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		ingress  string
+		policy   ResourceBackendPolicy
+		expected []map[string]string
+		err      bool
+	}{
+		{
+			name: "v1 default backend only",
+			ingress: `
+apiVersion: networking.k8s.io/v1
+spec:
+  defaultBackend:
+    service:
+      name: app
+      port:
+        number: 80`,
+			expected: []map[string]string{{"name": "app", "port": "80"}},
+		},
+		{
+			name: "v1beta1 backend only",
+			ingress: `
+apiVersion: networking.k8s.io/v1beta1
+spec:
+  backend:
+    serviceName: app
+    servicePort: 80`,
+			expected: []map[string]string{{"name": "app", "port": "80"}},
+		},
+		{
+			name: "v1 named port and rule without http, deduplicated",
+			ingress: `
+apiVersion: networking.k8s.io/v1
+spec:
+  defaultBackend:
+    service:
+      name: app
+      port:
+        name: http
+  rules:
+  - host: example.com
+  - http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 8080
+      - path: /metrics
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              name: metrics`,
+			expected: []map[string]string{{"name": "app", "port": "8080"}, {"name": "app", "port": "9090"}},
+		},
+		{
+			name: "v1beta1 named port",
+			ingress: `
+apiVersion: extensions/v1beta1
+spec:
+  rules:
+  - http:
+      paths:
+      - backend:
+          serviceName: app
+          servicePort: metrics
+      - backend:
+          serviceName: app
+          servicePort: "8080"`,
+			expected: []map[string]string{{"name": "app", "port": "9090"}, {"name": "app", "port": "8080"}},
+		},
+		{
+			name: "unknown named port",
+			ingress: `
+apiVersion: networking.k8s.io/v1
+spec:
+  defaultBackend:
+    service:
+      name: app
+      port:
+        name: grpc`,
+			err: true,
+		},
+		{
+			name: "resource backend skipped",
+			ingress: `
+apiVersion: networking.k8s.io/v1
+spec:
+  defaultBackend:
+    resource:
+      apiGroup: k8s.example.com
+      kind: StorageBucket
+      name: static-assets
+  rules:
+  - http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: app
+            port:
+              number: 80`,
+			policy:   ResourceBackendSkip,
+			expected: []map[string]string{{"name": "app", "port": "80"}},
+		},
+		{
+			name: "resource backend denied",
+			ingress: `
+apiVersion: networking.k8s.io/v1
+spec:
+  defaultBackend:
+    resource:
+      apiGroup: k8s.example.com
+      kind: StorageBucket
+      name: static-assets`,
+			policy: ResourceBackendDeny,
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ingress := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal([]byte(test.ingress), &ingress.Object); err != nil {
+				t.Fatal(err)
+			}
+			ingress.SetNamespace("test")
+			backends, err := GetIngressBackendServices(ingress, c, test.policy, zap.New(zap.UseDevMode(true)))
+			if test.err {
+				if err == nil {
+					t.Errorf("expected error, got %v", backends)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(backends, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, backends)
+			}
+		})
+	}
+}
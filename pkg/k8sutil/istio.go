@@ -0,0 +1,163 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const istioMeshGateway = "mesh"
+
+// GetVirtualServiceBackendServices returns the destination services of the routes of an Istio VirtualService bound to gateways.
+// Routes applied only to the mesh don't expose services, hosts outside the cluster are handled according to the policy.
+func GetVirtualServiceBackendServices(vs *unstructured.Unstructured, c client.Client, policy ResourceBackendPolicy, log logr.Logger) ([]map[string]string, error) {
+	backends := []map[string]string{}
+	gateways, _, _ := unstructured.NestedStringSlice(vs.Object, "spec", "gateways")
+
+	seen := map[string]bool{}
+	for _, protocol := range []string{"http", "tls", "tcp"} {
+		routes, _, _ := unstructured.NestedSlice(vs.Object, "spec", protocol)
+		for i, route := range routes {
+			routeMap, ok := route.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !istioRouteExposed(routeMap, gateways) {
+				log.Info("route of the virtual service isn't bound to gateways", "name", vs.GetName(), "protocol", protocol, "route", i)
+				continue
+			}
+			destinations, _, _ := unstructured.NestedSlice(routeMap, "route")
+			for _, destination := range destinations {
+				destinationMap, ok := destination.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				host, ok, _ := unstructured.NestedString(destinationMap, "destination", "host")
+				if !ok {
+					return nil, errors.New("value not found: destination host")
+				}
+				name, namespace, ok, err := istioServiceHost(host, vs.GetNamespace(), c)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					if policy == ResourceBackendDeny {
+						return nil, ErrResourceBackend
+					}
+					log.Info("skipping destination outside the cluster", "host", host)
+					continue
+				}
+
+				var port string
+				if number, ok, _ := unstructured.NestedFieldNoCopy(destinationMap, "destination", "port", "number"); ok {
+					var err error
+					if port, err = portNumber(number); err != nil {
+						return nil, err
+					}
+				} else {
+					// the port can be omitted if the service has a single port
+					service, err := GetServiceByName(name, namespace, c)
+					if err != nil {
+						return nil, err
+					}
+					if len(service.Spec.Ports) != 1 {
+						return nil, errors.Errorf("value not found: destination port of %s", host)
+					}
+					port = strconv.Itoa(int(service.Spec.Ports[0].Port))
+				}
+
+				key := namespace + "/" + name + ":" + port
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				backends = append(backends, map[string]string{"name": name, "namespace": namespace, "port": port})
+			}
+		}
+	}
+	return backends, nil
+}
+
+// istioRouteExposed reports whether the route is applied to gateways other than the mesh.
+// The gateways of a match override the gateways of the virtual service, which default to the mesh.
+func istioRouteExposed(route map[string]interface{}, gateways []string) bool {
+	matches, _, _ := unstructured.NestedSlice(route, "match")
+	if len(matches) == 0 {
+		return istioGatewaysExposed(gateways)
+	}
+	for _, match := range matches {
+		matchMap, ok := match.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		matchGateways, _, _ := unstructured.NestedStringSlice(matchMap, "gateways")
+		if len(matchGateways) == 0 {
+			matchGateways = gateways
+		}
+		if istioGatewaysExposed(matchGateways) {
+			return true
+		}
+	}
+	return false
+}
+
+func istioGatewaysExposed(gateways []string) bool {
+	for _, gateway := range gateways {
+		if gateway != istioMeshGateway {
+			return true
+		}
+	}
+	return false
+}
+
+// istioServiceHost returns the service of a destination host. Short names are in the namespace of the virtual service,
+// name.namespace hosts are services of the namespace if it exists, like the cluster DNS resolves them.
+func istioServiceHost(host, namespace string, c client.Client) (string, string, bool, error) {
+	if !strings.Contains(host, ".") {
+		return host, namespace, true, nil
+	}
+	for _, suffix := range []string{".svc.cluster.local", ".svc"} {
+		if strings.HasSuffix(host, suffix) {
+			parts := strings.Split(strings.TrimSuffix(host, suffix), ".")
+			if len(parts) == 2 {
+				return parts[0], parts[1], true, nil
+			}
+		}
+	}
+	parts := strings.Split(host, ".")
+	if len(parts) != 2 {
+		return "", "", false, nil
+	}
+	var ns corev1.Namespace
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: parts[1]}, &ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", "", false, nil
+		}
+		return "", "", false, errors.WrapIf(err, "cannot get namespace of destination host")
+	}
+	return parts[0], parts[1], true, nil
+}
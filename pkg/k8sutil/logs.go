@@ -0,0 +1,52 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"context"
+
+	"emperror.dev/emperror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// PodLogs reads the logs of pod containers, which the controller-runtime client can't
+type PodLogs interface {
+	Logs(ctx context.Context, namespace, pod, container string) ([]byte, error)
+}
+
+type podLogs struct {
+	clientset kubernetes.Interface
+}
+
+// NewPodLogs returns a PodLogs reading the logs through the API server
+func NewPodLogs(config *rest.Config) (PodLogs, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, emperror.Wrap(err, "failed to create kubernetes client")
+	}
+	return &podLogs{clientset: clientset}, nil
+}
+
+func (l *podLogs) Logs(ctx context.Context, namespace, pod, container string) ([]byte, error) {
+	content, err := l.clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{Container: container}).Do(ctx).Raw()
+	if err != nil {
+		return nil, emperror.WrapWith(err, "failed to get pod logs", "namespace", namespace, "pod", pod, "container", container)
+	}
+	return content, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetOpenShiftRouteBackendServices returns the services of an OpenShift Route including the alternate backends.
+// The target port of the route is resolved to the service port.
+func GetOpenShiftRouteBackendServices(route *unstructured.Unstructured, c client.Client, policy ResourceBackendPolicy, log logr.Logger) ([]map[string]string, error) {
+	backends := []map[string]string{}
+	targets := []interface{}{}
+	if to, ok, _ := unstructured.NestedMap(route.Object, "spec", "to"); ok {
+		targets = append(targets, to)
+	}
+	alternates, _, _ := unstructured.NestedSlice(route.Object, "spec", "alternateBackends")
+	targets = append(targets, alternates...)
+
+	var targetPort *intstr.IntOrString
+	if value, ok, _ := unstructured.NestedFieldNoCopy(route.Object, "spec", "port", "targetPort"); ok {
+		switch port := value.(type) {
+		case string:
+			parsed := intstr.Parse(port)
+			targetPort = &parsed
+		default:
+			number, err := portNumber(port)
+			if err != nil {
+				return nil, err
+			}
+			parsed := intstr.Parse(number)
+			targetPort = &parsed
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, target := range targets {
+		targetMap, ok := target.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(targetMap, "kind")
+		if kind != "" && kind != "Service" {
+			if policy == ResourceBackendDeny {
+				return nil, ErrResourceBackend
+			}
+			log.Info("skipping route backend", "kind", kind)
+			continue
+		}
+		name, ok, _ := unstructured.NestedString(targetMap, "name")
+		if !ok {
+			return nil, errors.New("value not found: route backend name")
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		port, err := routeServicePort(name, route.GetNamespace(), targetPort, c)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, map[string]string{"name": name, "port": port})
+	}
+	return backends, nil
+}
+
+// routeServicePort returns the service port matching the target port of the route, the first port if it isn't set
+func routeServicePort(name, namespace string, targetPort *intstr.IntOrString, c client.Client) (string, error) {
+	service, err := GetServiceByName(name, namespace, c)
+	if err != nil {
+		return "", err
+	}
+	for _, port := range service.Spec.Ports {
+		switch {
+		case targetPort == nil:
+			return strconv.Itoa(int(port.Port)), nil
+		case targetPort.Type == intstr.String && (port.Name == targetPort.StrVal || port.TargetPort.StrVal == targetPort.StrVal):
+			return strconv.Itoa(int(port.Port)), nil
+		case targetPort.Type == intstr.Int && (port.TargetPort.IntVal == targetPort.IntVal || (port.TargetPort.IntVal == 0 && port.Port == targetPort.IntVal)):
+			return strconv.Itoa(int(port.Port)), nil
+		}
+	}
+	return "", errors.Errorf("target port %s not found in service %s", targetPort.String(), name)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// GetRouteBackendServices returns the deduplicated backend services of a gateway.networking.k8s.io HTTPRoute or GRPCRoute
+// with their name, namespace and port. Backend references of other kinds are handled according to the policy.
+func GetRouteBackendServices(route *unstructured.Unstructured, policy ResourceBackendPolicy, log logr.Logger) ([]map[string]string, error) {
+	backends := []map[string]string{}
+	seen := map[string]bool{}
+
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		refs, _, _ := unstructured.NestedSlice(ruleMap, "backendRefs")
+		for _, ref := range refs {
+			refMap, ok := ref.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			group, _, _ := unstructured.NestedString(refMap, "group")
+			kind, ok, _ := unstructured.NestedString(refMap, "kind")
+			if !ok {
+				kind = "Service"
+			}
+			if group != "" || kind != "Service" {
+				if policy == ResourceBackendDeny {
+					return nil, ErrResourceBackend
+				}
+				log.Info("skipping backend reference", "group", group, "kind", kind)
+				continue
+			}
+
+			name, ok, _ := unstructured.NestedString(refMap, "name")
+			if !ok {
+				return nil, errors.New("value not found: backend name")
+			}
+			namespace, ok, _ := unstructured.NestedString(refMap, "namespace")
+			if !ok {
+				namespace = route.GetNamespace()
+			}
+			portValue, ok, _ := unstructured.NestedFieldNoCopy(refMap, "port")
+			if !ok {
+				return nil, errors.Errorf("value not found: port of service %s", name)
+			}
+			port, err := portNumber(portValue)
+			if err != nil {
+				return nil, err
+			}
+
+			key := namespace + "/" + name + ":" + port
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			backends = append(backends, map[string]string{"name": name, "namespace": namespace, "port": port})
+		}
+	}
+	return backends, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+)
+
+func TestGetRouteBackendServices(t *testing.T) {
+	route := `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+spec:
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: /
+    backendRefs:
+    - name: app
+      port: 8080
+      weight: 90
+    - name: app-canary
+      namespace: canary
+      port: 8080
+      weight: 10
+  - backendRefs:
+    - kind: Service
+      name: app
+      port: 8080
+    - group: example.com
+      kind: Bucket
+      name: assets
+`
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(route), &obj.Object); err != nil {
+		t.Fatal(err)
+	}
+	obj.SetNamespace("test")
+
+	backends, err := GetRouteBackendServices(obj, ResourceBackendSkip, zap.New(zap.UseDevMode(true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]string{
+		{"name": "app", "namespace": "test", "port": "8080"},
+		{"name": "app-canary", "namespace": "canary", "port": "8080"},
+	}
+	if !reflect.DeepEqual(backends, expected) {
+		t.Errorf("expected %v, got %v", expected, backends)
+	}
+
+	if _, err := GetRouteBackendServices(obj, ResourceBackendDeny, zap.New(zap.UseDevMode(true))); err != ErrResourceBackend {
+		t.Errorf("expected ErrResourceBackend, got %v", err)
+	}
+}
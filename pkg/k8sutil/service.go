@@ -18,16 +18,26 @@ package k8sutil
 
 import (
 	"context"
-	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"emperror.dev/emperror"
 	"emperror.dev/errors"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+const (
+	maxDurationAnnotation  = "dast.security.banzaicloud.io/max-duration"
+	scanPriorityAnnotation = "dast.security.banzaicloud.io/scan-priority"
+	failOnAnnotation       = "dast.security.banzaicloud.io/fail-on"
+	scheduleAnnotation     = "dast.security.banzaicloud.io/schedule"
 )
 
 func GetServiceStatus(service *corev1.Service) bool {
@@ -48,41 +58,9 @@ func GetTargetService(service *corev1.Service) string {
 	return "http://" + service.GetName() + "." + service.GetNamespace() + ".svc.cluster.local:" + portNR
 }
 
-func GetIngressBackendServices(ingress *unstructured.Unstructured, log logr.Logger) ([]map[string]string, error) {
-	log.Info("ingress", "ingress", ingress)
-	backends := []map[string]string{}
-	rules, ok, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
-	if !ok {
-		return backends, errors.New("value not found: rules")
-	}
-	for _, rule := range rules {
-		paths, ok, _ := unstructured.NestedSlice(rule.(map[string]interface{}), "http", "paths")
-		if !ok {
-			return backends, errors.New("value not found: paths")
-		}
-		for _, path := range paths {
-			backend := map[string]string{}
-			backend["name"], ok, _ = unstructured.NestedString(path.(map[string]interface{}), "backend", "serviceName")
-			if !ok {
-				backend["name"], ok, _ = unstructured.NestedString(path.(map[string]interface{}), "backend", "service", "name")
-				if !ok {
-					return backends, errors.New("value not found: service name")
-				}
-			}
-			portNum, ok, _ := unstructured.NestedFieldCopy(path.(map[string]interface{}), "backend", "servicePort")
-			if !ok {
-				portNum, ok, _ = unstructured.NestedFieldCopy(path.(map[string]interface{}), "backend", "service", "port", "number")
-				if !ok {
-					return backends, errors.New("value not found: service port")
-				}
-			}
-
-			backend["port"] = fmt.Sprintf("%v", portNum)
-			backends = append(backends, backend)
-		}
-	}
-
-	return backends, nil
+// GetTargetServicePort returns the url of a port of the service
+func GetTargetServicePort(service metav1.Object, port int32) string {
+	return "http://" + service.GetName() + "." + service.GetNamespace() + ".svc.cluster.local:" + strconv.Itoa(int(port))
 }
 
 func GetServiceByName(name, namespace string, client client.Client) (*corev1.Service, error) {
@@ -98,7 +76,7 @@ func GetServiceByName(name, namespace string, client client.Client) (*corev1.Ser
 	return &service, nil
 }
 
-func GetServiceAnotations(service *corev1.Service, log logr.Logger) (map[string]string, error) {
+func GetServiceAnotations(service metav1.Object, log logr.Logger) (map[string]string, error) {
 	annotations := service.GetAnnotations()
 	zaProxyCfg := map[string]string{}
 	if zaproxyName, ok := annotations["dast.security.banzaicloud.io/zaproxy"]; ok {
@@ -118,3 +96,111 @@ func GetServiceAnotations(service *corev1.Service, log logr.Logger) (map[string]
 
 	return nil, errors.New("service isn't annotated")
 }
+
+// GetServiceScope returns the scan scope defined in service annotations.
+// Include and exclude regexes are separated by newlines, technologies by commas.
+func GetServiceScope(service metav1.Object) *securityv1alpha1.Scope {
+	annotations := service.GetAnnotations()
+	scope := &securityv1alpha1.Scope{
+		Include:      splitAnnotation(annotations["dast.security.banzaicloud.io/scope-include"], "\n"),
+		Exclude:      splitAnnotation(annotations["dast.security.banzaicloud.io/scope-exclude"], "\n"),
+		Technologies: splitAnnotation(annotations["dast.security.banzaicloud.io/technologies"], ","),
+	}
+	if len(scope.Include) == 0 && len(scope.Exclude) == 0 && len(scope.Technologies) == 0 {
+		return nil
+	}
+	return scope
+}
+
+func splitAnnotation(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// GetServiceMaxDuration returns the maximum scan duration defined in service annotations
+func GetServiceMaxDuration(service metav1.Object) (*metav1.Duration, error) {
+	value, ok := service.GetAnnotations()[maxDurationAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, errors.WrapIff(err, "invalid %s annotation", maxDurationAnnotation)
+	}
+	return &metav1.Duration{Duration: duration}, nil
+}
+
+// GetScanSchedule returns the interval of the scheduled rescans defined in annotations, 0 if it isn't defined
+func GetScanSchedule(obj metav1.Object) (time.Duration, error) {
+	value, ok := obj.GetAnnotations()[scheduleAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	schedule, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.WrapIff(err, "invalid %s annotation", scheduleAnnotation)
+	}
+	if schedule <= 0 {
+		return 0, errors.Errorf("invalid %s annotation, must be positive", scheduleAnnotation)
+	}
+	return schedule, nil
+}
+
+// GetScanPriority returns the priority of the scans in the scan queue defined in annotations, 0 if it isn't defined
+func GetScanPriority(obj metav1.Object) (int, error) {
+	value, ok := obj.GetAnnotations()[scanPriorityAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.WrapIff(err, "invalid %s annotation", scanPriorityAnnotation)
+	}
+	return priority, nil
+}
+
+// GetServiceFailOn returns the thresholds failing the analyzer job defined in service annotations
+func GetServiceFailOn(service metav1.Object) (*securityv1alpha1.Thresholds, error) {
+	value, ok := service.GetAnnotations()[failOnAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	thresholds, err := ParseThresholds(value)
+	if err != nil {
+		return nil, errors.WrapIff(err, "invalid %s annotation", failOnAnnotation)
+	}
+	return thresholds, nil
+}
+
+// ParseThresholds parses thresholds in high=0,medium=5 format
+func ParseThresholds(value string) (*securityv1alpha1.Thresholds, error) {
+	thresholds := &securityv1alpha1.Thresholds{}
+	for _, item := range splitAnnotation(value, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid threshold: %s", item)
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, errors.WrapIf(err, "invalid threshold value")
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "high":
+			thresholds.High = &max
+		case "medium":
+			thresholds.Medium = &max
+		case "low":
+			thresholds.Low = &max
+		case "informational":
+			thresholds.Informational = &max
+		default:
+			return nil, errors.Errorf("unknown risk level: %s", kv[0])
+		}
+	}
+	return thresholds, nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sutil
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestScanAnnotations(t *testing.T) {
+	obj := &metav1.ObjectMeta{}
+	if duration, err := GetServiceMaxDuration(obj); duration != nil || err != nil {
+		t.Errorf("missing max duration: %v, %v", duration, err)
+	}
+	if priority, err := GetScanPriority(obj); priority != 0 || err != nil {
+		t.Errorf("missing priority: %v, %v", priority, err)
+	}
+	if failOn, err := GetServiceFailOn(obj); failOn != nil || err != nil {
+		t.Errorf("missing fail-on: %v, %v", failOn, err)
+	}
+	if schedule, err := GetScanSchedule(obj); schedule != 0 || err != nil {
+		t.Errorf("missing schedule: %v, %v", schedule, err)
+	}
+
+	obj.SetAnnotations(map[string]string{
+		maxDurationAnnotation:  "10m",
+		scanPriorityAnnotation: "5",
+		failOnAnnotation:       "high=0,medium=3",
+		scheduleAnnotation:     "24h",
+	})
+	if duration, err := GetServiceMaxDuration(obj); err != nil || duration.Duration != 10*time.Minute {
+		t.Errorf("unexpected max duration: %v, %v", duration, err)
+	}
+	if priority, err := GetScanPriority(obj); err != nil || priority != 5 {
+		t.Errorf("unexpected priority: %v, %v", priority, err)
+	}
+	if failOn, err := GetServiceFailOn(obj); err != nil || *failOn.High != 0 || *failOn.Medium != 3 || failOn.Low != nil {
+		t.Errorf("unexpected fail-on: %v, %v", failOn, err)
+	}
+	if schedule, err := GetScanSchedule(obj); err != nil || schedule != 24*time.Hour {
+		t.Errorf("unexpected schedule: %v, %v", schedule, err)
+	}
+
+	obj.SetAnnotations(map[string]string{
+		maxDurationAnnotation:  "10 minutes",
+		scanPriorityAnnotation: "high",
+		failOnAnnotation:       "high",
+		scheduleAnnotation:     "-1h",
+	})
+	if _, err := GetServiceMaxDuration(obj); err == nil {
+		t.Error("invalid max duration is accepted")
+	}
+	if _, err := GetScanPriority(obj); err == nil {
+		t.Error("invalid priority is accepted")
+	}
+	if _, err := GetServiceFailOn(obj); err == nil {
+		t.Error("invalid fail-on is accepted")
+	}
+	if _, err := GetScanSchedule(obj); err == nil {
+		t.Error("invalid schedule is accepted")
+	}
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"emperror.dev/emperror"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+// +kubebuilder:rbac:groups=security.banzaicloud.io,resources=dastnotifiers,verbs=get;list;watch
+
+const (
+	defaultURLKey = "url"
+
+	defaultTimeout = 10 * time.Second
+	// maxPending is the number of notifications sent at once, further notifications are dropped
+	maxPending = 64
+
+	defaultTemplate = `{{if eq .Type "AdmissionDenied"}}DAST denied {{.Kind}} {{.Namespace}}/{{.Name}}: {{.Message}}` +
+		`{{else}}DAST scan of {{if .Service}}{{.Namespace}}/{{.Service}}{{else}}{{.Target}}{{end}} {{if .Passed}}passed{{else}}failed{{end}}` +
+		`{{if .Reason}} ({{.Reason}}){{end}}{{if .Message}}: {{.Message}}{{end}}` +
+		`{{if .Summary}}, alerts High: {{index .Summary "High"}}, Medium: {{index .Summary "Medium"}}, Low: {{index .Summary "Low"}}, Informational: {{index .Summary "Informational"}}{{end}}` +
+		`{{if .NewAlerts}}, new alerts: {{len .NewAlerts}}{{end}}{{end}}`
+)
+
+var riskLevels = map[string]int{
+	"Informational": 0,
+	"Low":           1,
+	"Medium":        2,
+	"High":          3,
+}
+
+// Event is passed to the notifiers and to their message templates
+type Event struct {
+	Type      securityv1alpha1.NotificationEvent `json:"type"`
+	Namespace string                             `json:"namespace"`
+	Service   string                             `json:"service,omitempty"`
+	Target    string                             `json:"target,omitempty"`
+	// Kind and Name identify the object denied by the webhook
+	Kind    string `json:"kind,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Ingress string `json:"ingress,omitempty"`
+	ScanID  string `json:"scanID,omitempty"`
+	Passed  bool   `json:"passed"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Summary is the number of alerts per risk level, nil if unknown
+	Summary   map[string]int  `json:"summary,omitempty"`
+	Alerts    []results.Alert `json:"alerts,omitempty"`
+	NewAlerts []results.Alert `json:"newAlerts,omitempty"`
+}
+
+// ScanFinishedEvent creates the event of a stored scan
+func ScanFinishedEvent(scan *results.Scan) *Event {
+	event := &Event{
+		Type:      securityv1alpha1.EventScanFinished,
+		Namespace: scan.Namespace,
+		Service:   scan.Service,
+		Target:    scan.Target,
+		Ingress:   scan.Ingress,
+		ScanID:    scan.ID,
+		Passed:    scan.Passed,
+		Reason:    scan.Reason,
+		Message:   scan.Message,
+		Summary:   scan.Summary,
+		Alerts:    scan.Alerts,
+	}
+	if scan.Diff != nil {
+		event.NewAlerts = scan.Diff.New
+	}
+	return event
+}
+
+// Notifier sends events to the DastNotifiers in the namespace of the event
+type Notifier struct {
+	Client     client.Client
+	HTTPClient *http.Client
+	Log        logr.Logger
+	// AllowedHosts are the hosts notifications are sent to, *.example.com allows the subdomains of example.com
+	AllowedHosts []string
+	// Timeout limits sending a notification
+	Timeout time.Duration
+
+	pending chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a Notifier, which sends notifications only to the allowed hosts
+func New(c client.Client, allowedHosts []string, log logr.Logger) *Notifier {
+	return &Notifier{
+		Client: c,
+		HTTPClient: &http.Client{
+			// redirects aren't followed, so notifications can't be redirected to hosts which aren't allowed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Log:          log,
+		AllowedHosts: allowedHosts,
+		Timeout:      defaultTimeout,
+		pending:      make(chan struct{}, maxPending),
+	}
+}
+
+// Notify sends the event to every matching notifier in the background, so it doesn't delay the caller.
+// Every notification is limited to the timeout of the notifier, failed notifiers are logged and don't stop the others.
+func (n *Notifier) Notify(ctx context.Context, event *Event) error {
+	if n == nil {
+		return nil
+	}
+	var notifiers securityv1alpha1.DastNotifierList
+	if err := n.Client.List(ctx, &notifiers, client.InNamespace(event.Namespace)); err != nil {
+		return emperror.Wrap(err, "failed to list notifiers")
+	}
+	for i := range notifiers.Items {
+		notifier := &notifiers.Items[i]
+		if !Matches(notifier, event) {
+			continue
+		}
+		select {
+		case n.pending <- struct{}{}:
+		default:
+			n.Log.Info("too many pending notifications, notification is dropped", "notifier", notifier.GetName(), "namespace", notifier.GetNamespace(), "event", event.Type)
+			continue
+		}
+		n.wg.Add(1)
+		go func() {
+			defer func() {
+				<-n.pending
+				n.wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), n.Timeout)
+			defer cancel()
+			if err := n.send(ctx, notifier, event); err != nil {
+				n.Log.Error(err, "failed to send notification", "notifier", notifier.GetName(), "namespace", notifier.GetNamespace())
+				return
+			}
+			n.Log.Info("notification sent", "notifier", notifier.GetName(), "namespace", notifier.GetNamespace(), "event", event.Type)
+		}()
+	}
+	return nil
+}
+
+// Wait waits until the pending notifications are sent
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
+
+// Matches reports whether the event passes the event list and the filters of the notifier
+func Matches(notifier *securityv1alpha1.DastNotifier, event *Event) bool {
+	if len(notifier.Spec.Events) > 0 {
+		found := false
+		for _, e := range notifier.Spec.Events {
+			if e == event.Type {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	filters := notifier.Spec.Filters
+	if event.Type == securityv1alpha1.EventAdmissionDenied {
+		// a denied admission is always a failure and has no alerts
+		return true
+	}
+	if filters.OnlyFailures && event.Passed {
+		return false
+	}
+	if filters.OnlyNew && len(event.NewAlerts) == 0 {
+		return false
+	}
+	if filters.MinRisk != "" {
+		min := riskLevels[filters.MinRisk]
+		if filters.OnlyNew {
+			for _, alert := range event.NewAlerts {
+				if riskLevels[alert.Risk] >= min {
+					return true
+				}
+			}
+			return false
+		}
+		for risk, count := range event.Summary {
+			if count > 0 && riskLevels[risk] >= min {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (n *Notifier) send(ctx context.Context, notifier *securityv1alpha1.DastNotifier, event *Event) error {
+	target, err := n.url(ctx, notifier)
+	if err != nil {
+		return err
+	}
+	if err := n.allowed(target); err != nil {
+		return emperror.With(err, "notifier", notifier.GetName())
+	}
+	message, err := Render(notifier.Spec.Template, event)
+	if err != nil {
+		return err
+	}
+	payload, err := Payload(notifier.Spec.Type, message, event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return emperror.Wrap(err, "failed to create notification request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		return emperror.Wrap(err, "failed to send notification")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return emperror.With(fmt.Errorf("notification rejected with status %d", resp.StatusCode), "notifier", notifier.GetName())
+	}
+	return nil
+}
+
+func (n *Notifier) url(ctx context.Context, notifier *securityv1alpha1.DastNotifier) (string, error) {
+	ref := notifier.Spec.URLSecretRef
+	key := ref.Key
+	if key == "" {
+		key = defaultURLKey
+	}
+	var secret corev1.Secret
+	if err := n.Client.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: notifier.GetNamespace()}, &secret); err != nil {
+		return "", emperror.WrapWith(err, "failed to get notifier secret", "secret", ref.Name)
+	}
+	url, ok := secret.Data[key]
+	if !ok || len(url) == 0 {
+		return "", emperror.With(fmt.Errorf("notifier secret has no %s key", key), "secret", ref.Name)
+	}
+	return string(bytes.TrimSpace(url)), nil
+}
+
+// allowed returns an error, unless the URL is an https URL of an allowed host
+func (n *Notifier) allowed(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return emperror.Wrap(err, "invalid notifier url")
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("notifier url must be an https url, got scheme %q", u.Scheme)
+	}
+	host := u.Hostname()
+	for _, allowed := range n.AllowedHosts {
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("notifier host %s isn't allowed", host)
+}
+
+// Render executes the message template on the event, the default template is used if tmpl is empty
+func Render(tmpl string, event *Event) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultTemplate
+	}
+	t, err := template.New("message").Parse(tmpl)
+	if err != nil {
+		return "", emperror.Wrap(err, "invalid notification template")
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, event); err != nil {
+		return "", emperror.Wrap(err, "failed to render notification template")
+	}
+	return buf.String(), nil
+}
+
+// Payload creates the request body of the notifier type
+func Payload(notifierType securityv1alpha1.NotifierType, message string, event *Event) ([]byte, error) {
+	var body interface{}
+	switch notifierType {
+	case securityv1alpha1.NotifierSlack:
+		body = map[string]string{"text": message}
+	case securityv1alpha1.NotifierTeams:
+		color := "2DC72D"
+		if !event.Passed {
+			color = "D70000"
+		}
+		body = map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    message,
+			"themeColor": color,
+			"text":       message,
+		}
+	case securityv1alpha1.NotifierWebhook:
+		body = struct {
+			*Event
+			Text string `json:"text"`
+		}{event, message}
+	default:
+		return nil, fmt.Errorf("unsupported notifier type %q", notifierType)
+	}
+	payload, err := json.Marshal(body)
+	return payload, emperror.Wrap(err, "failed to marshal notification")
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := securityv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func newNotifier(name string, notifierType securityv1alpha1.NotifierType, filters securityv1alpha1.NotifierFilters) *securityv1alpha1.DastNotifier {
+	return &securityv1alpha1.DastNotifier{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		Spec: securityv1alpha1.DastNotifierSpec{
+			Type:         notifierType,
+			URLSecretRef: securityv1alpha1.SecretKeyRef{Name: name},
+			Filters:      filters,
+		},
+	}
+}
+
+func newSecret(name, url string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		Data:       map[string][]byte{"url": []byte(url)},
+	}
+}
+
+// newTestNotifier creates a notifier, which sends notifications to the TLS test server
+func newTestNotifier(t *testing.T, objects []runtime.Object, server *httptest.Server) *Notifier {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := New(fake.NewFakeClientWithScheme(newScheme(t), objects...), []string{u.Hostname()}, zap.New(zap.UseDevMode(true)))
+	n.HTTPClient.Transport = server.Client().Transport
+	return n
+}
+
+func failedScanEvent() *Event {
+	return &Event{
+		Type:      securityv1alpha1.EventScanFinished,
+		Namespace: "test",
+		Service:   "app",
+		Reason:    securityv1alpha1.ReasonThresholdExceeded,
+		Summary:   map[string]int{"High": 0, "Medium": 1, "Low": 2, "Informational": 0},
+		NewAlerts: []results.Alert{{PluginID: "10021", Risk: "Low"}},
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var lock sync.Mutex
+	received := map[string]map[string]interface{}{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload %s: %v", body, err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		received[r.URL.Path] = payload
+	}))
+	defer server.Close()
+
+	n := newTestNotifier(t, []runtime.Object{
+		newNotifier("slack", securityv1alpha1.NotifierSlack, securityv1alpha1.NotifierFilters{}),
+		newSecret("slack", server.URL+"/slack"),
+		newNotifier("teams", securityv1alpha1.NotifierTeams, securityv1alpha1.NotifierFilters{}),
+		newSecret("teams", server.URL+"/teams"),
+		newNotifier("webhook", securityv1alpha1.NotifierWebhook, securityv1alpha1.NotifierFilters{}),
+		newSecret("webhook", server.URL+"/webhook"),
+		newNotifier("high", securityv1alpha1.NotifierSlack, securityv1alpha1.NotifierFilters{MinRisk: "High"}),
+		newSecret("high", server.URL+"/high"),
+		newNotifier("missing-secret", securityv1alpha1.NotifierSlack, securityv1alpha1.NotifierFilters{}),
+	}, server)
+	if err := n.Notify(context.Background(), failedScanEvent()); err != nil {
+		t.Fatal(err)
+	}
+	n.Wait()
+
+	if len(received) != 3 {
+		t.Fatalf("expected 3 notifications, got %v", received)
+	}
+	expected := "DAST scan of test/app failed (ThresholdExceeded), alerts High: 0, Medium: 1, Low: 2, Informational: 0, new alerts: 1"
+	if text := received["/slack"]["text"]; text != expected {
+		t.Errorf("unexpected slack message %q", text)
+	}
+	if received["/teams"]["@type"] != "MessageCard" || received["/teams"]["text"] != expected {
+		t.Errorf("unexpected teams message %v", received["/teams"])
+	}
+	if received["/webhook"]["service"] != "app" || received["/webhook"]["text"] != expected {
+		t.Errorf("unexpected webhook payload %v", received["/webhook"])
+	}
+	if _, ok := received["/high"]; ok {
+		t.Error("scan without high alerts was sent to the high risk notifier")
+	}
+}
+
+func TestNotifyRejected(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	n := newTestNotifier(t, nil, server)
+	c := n.Client
+	if err := n.send(context.Background(), newNotifier("slack", securityv1alpha1.NotifierSlack, securityv1alpha1.NotifierFilters{}), failedScanEvent()); err == nil {
+		t.Error("expected error without secret")
+	}
+
+	if err := c.Create(context.Background(), newSecret("slack", server.URL)); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.send(context.Background(), newNotifier("slack", securityv1alpha1.NotifierSlack, securityv1alpha1.NotifierFilters{}), failedScanEvent()); err == nil {
+		t.Error("expected error on rejected notification")
+	}
+}
+
+func TestNotifyTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	n := newTestNotifier(t, []runtime.Object{
+		newNotifier("slack", securityv1alpha1.NotifierSlack, securityv1alpha1.NotifierFilters{}),
+		newSecret("slack", server.URL),
+	}, server)
+	n.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	if err := n.Notify(context.Background(), failedScanEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= n.Timeout {
+		t.Errorf("notify waited for the notification for %s", elapsed)
+	}
+	n.Wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("notification isn't limited to the timeout, it took %s", elapsed)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	n := New(nil, []string{"hooks.slack.com", "*.webhook.office.com"}, zap.New(zap.UseDevMode(true)))
+	tests := []struct {
+		url     string
+		message string
+	}{
+		{url: "https://hooks.slack.com/services/T0/B0/X"},
+		{url: "https://example.webhook.office.com/webhookb2/X"},
+		{url: "http://hooks.slack.com/services/T0/B0/X", message: "must be an https url"},
+		{url: "https://webhook.office.com/X", message: "isn't allowed"},
+		{url: "https://hooks.slack.com.example.com/X", message: "isn't allowed"},
+		{url: "https://169.254.169.254/latest/meta-data", message: "isn't allowed"},
+		{url: "https://kubernetes.default.svc/api", message: "isn't allowed"},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			err := n.allowed(test.url)
+			if test.message == "" {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected error %q, got %v", test.message, err)
+			}
+		})
+	}
+}
+
+func TestNotifyRedirect(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	server := httptest.NewTLSServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer server.Close()
+
+	n := newTestNotifier(t, []runtime.Object{newSecret("slack", server.URL)}, server)
+	if err := n.send(context.Background(), newNotifier("slack", securityv1alpha1.NotifierSlack, securityv1alpha1.NotifierFilters{}), failedScanEvent()); err == nil {
+		t.Error("expected error on redirected notification")
+	}
+	if redirected {
+		t.Error("redirect is followed")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	passed := failedScanEvent()
+	passed.Passed = true
+	noNew := failedScanEvent()
+	noNew.NewAlerts = nil
+	denied := &Event{Type: securityv1alpha1.EventAdmissionDenied, Namespace: "test", Ingress: "app"}
+
+	tests := []struct {
+		name     string
+		filters  securityv1alpha1.NotifierFilters
+		events   []securityv1alpha1.NotificationEvent
+		event    *Event
+		expected bool
+	}{
+		{name: "no filters", event: failedScanEvent(), expected: true},
+		{name: "only failures, failed", filters: securityv1alpha1.NotifierFilters{OnlyFailures: true}, event: failedScanEvent(), expected: true},
+		{name: "only failures, passed", filters: securityv1alpha1.NotifierFilters{OnlyFailures: true}, event: passed, expected: false},
+		{name: "only new, no new alerts", filters: securityv1alpha1.NotifierFilters{OnlyNew: true}, event: noNew, expected: false},
+		{name: "min risk medium", filters: securityv1alpha1.NotifierFilters{MinRisk: "Medium"}, event: failedScanEvent(), expected: true},
+		{name: "min risk high", filters: securityv1alpha1.NotifierFilters{MinRisk: "High"}, event: failedScanEvent(), expected: false},
+		{name: "min risk medium, only new", filters: securityv1alpha1.NotifierFilters{MinRisk: "Medium", OnlyNew: true}, event: failedScanEvent(), expected: false},
+		{name: "min risk low, only new", filters: securityv1alpha1.NotifierFilters{MinRisk: "Low", OnlyNew: true}, event: failedScanEvent(), expected: true},
+		{name: "denied admission ignores filters", filters: securityv1alpha1.NotifierFilters{MinRisk: "High", OnlyNew: true}, event: denied, expected: true},
+		{name: "event not selected", events: []securityv1alpha1.NotificationEvent{securityv1alpha1.EventScanFinished}, event: denied, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notifier := newNotifier("test", securityv1alpha1.NotifierSlack, test.filters)
+			notifier.Spec.Events = test.events
+			if actual := Matches(notifier, test.event); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	message, err := Render("", &Event{Type: securityv1alpha1.EventAdmissionDenied, Namespace: "test", Kind: "Ingress", Name: "app", Ingress: "app", Message: "scan results are above treshold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "DAST denied Ingress test/app: scan results are above treshold"; message != expected {
+		t.Errorf("expected %q, got %q", expected, message)
+	}
+
+	message, err = Render("{{.Service}} has {{len .NewAlerts}} new alerts", failedScanEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "app has 1 new alerts"; message != expected {
+		t.Errorf("expected %q, got %q", expected, message)
+	}
+
+	if _, err := Render("{{.Unknown", failedScanEvent()); err == nil {
+		t.Error("expected error on invalid template")
+	}
+}
@@ -34,6 +34,18 @@ import (
 
 const (
 	componentName = "analyzer"
+	// ServiceNameLabel is the label of analyzer jobs holding the name of the scanned service
+	ServiceNameLabel = "dast.security.banzaicloud.io/service"
+	// ServiceNamespaceLabel is the label of analyzer jobs holding the namespace of the scanned service
+	ServiceNamespaceLabel = "dast.security.banzaicloud.io/service-namespace"
+	// IngressNameLabel is the label of analyzer jobs holding the name of the ingress, whose host is scanned
+	IngressNameLabel = "dast.security.banzaicloud.io/ingress"
+	// IngressNamespaceLabel is the label of analyzer jobs holding the namespace of the ingress, whose host is scanned
+	IngressNamespaceLabel = "dast.security.banzaicloud.io/ingress-namespace"
+	// ScanLabel is the label of analyzer jobs holding the analyzer name, which is shared by the runs of the scan
+	ScanLabel = "dast.security.banzaicloud.io/scan"
+	// ZaProxyLabel is the label of analyzer jobs holding the name of the ZAP instance in the namespace of the job
+	ZaProxyLabel = "dast.security.banzaicloud.io/zaproxy"
 )
 
 var labelSelector = map[string]string{
@@ -43,6 +55,7 @@ var labelSelector = map[string]string{
 // Reconciler implements the Component Reconciler
 type Reconciler struct {
 	resources.Reconciler
+	scanPolicy *securityv1alpha1.DastScanPolicy
 }
 
 // New creates a new reconciler for analyzer
@@ -119,6 +132,23 @@ func (r *Reconciler) Reconcile(log logr.Logger) error {
 		}
 	}
 
+	if r.Dast.Spec.Analyzer.ScanPolicy != "" {
+		policyNamespace := r.Dast.Namespace
+		// selected services share the scan policy of the Dast selecting them
+		if r.Dast.Spec.Analyzer.Service != nil && r.Dast.Spec.Analyzer.TargetSelector == nil {
+			policyNamespace = r.Dast.Spec.Analyzer.Service.GetNamespace()
+		}
+		key := types.NamespacedName{
+			Name:      r.Dast.Spec.Analyzer.ScanPolicy,
+			Namespace: policyNamespace,
+		}
+		scanPolicy := securityv1alpha1.DastScanPolicy{}
+		if err := r.Get(context.TODO(), key, &scanPolicy); err != nil {
+			return emperror.WrapWith(err, "failed to get scan policy", "policy", key)
+		}
+		r.scanPolicy = &scanPolicy
+	}
+
 	for _, res := range []resources.ResourceWithLogs{
 		r.job,
 	} {
@@ -133,3 +163,8 @@ func (r *Reconciler) Reconcile(log logr.Logger) error {
 
 	return nil
 }
+
+// JobLabels returns the labels selecting every analyzer job
+func JobLabels() map[string]string {
+	return map[string]string{"app": componentName}
+}
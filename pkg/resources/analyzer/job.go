@@ -17,41 +17,72 @@ limitations under the License.
 package analyzer
 
 import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/go-logr/logr"
 	"istio.io/pkg/log"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/resources/zaproxy"
 )
 
+const jobDeadlineGracePeriod = 5 * time.Minute
+
 // job return a job for analyzer
 func (r *Reconciler) job(log logr.Logger) runtime.Object {
 
-	return newAnalyzerJob(r.Dast)
+	return newAnalyzerJob(r.Dast, r.scanPolicy)
+}
+
+// UsesSidecar reports whether the scan runs in a ZAP sidecar of the analyzer instead of the shared ZAP of the Dast.
+// The host aliases are resolved by the sidecar, which is shut down by the analyzer after the scan.
+func UsesSidecar(dast *securityv1alpha1.Dast) bool {
+	return len(dast.Spec.Analyzer.HostAliases) > 0
 }
 
-func newAnalyzerJob(dast *securityv1alpha1.Dast) *batchv1.Job {
+func newAnalyzerJob(dast *securityv1alpha1.Dast, scanPolicy *securityv1alpha1.DastScanPolicy) *batchv1.Job {
 	var ownerReferences []metav1.OwnerReference
-	if dast.Spec.Analyzer.Service != nil {
-		ownerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(dast.Spec.Analyzer.Service, schema.GroupVersion{Group: "app", Version: "v1"}.WithKind("Service"))}
-	} else {
+	var annotations map[string]string
+	switch {
+	case dast.Spec.Analyzer.TargetSelector != nil && dast.Spec.Analyzer.Service != nil:
+		// jobs of selected services belong to the Dast selecting them
+		ownerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(dast, securityv1alpha1.GroupVersion.WithKind("Dast"))}
+		annotations = dast.Spec.Analyzer.Service.GetAnnotations()
+	case dast.Spec.Analyzer.Service != nil:
+		// owners in other namespaces are invalid, those jobs are found by their labels and deleted with the service
+		if dast.Spec.Analyzer.Service.GetNamespace() == dast.GetNamespace() {
+			ownerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(dast.Spec.Analyzer.Service, corev1.SchemeGroupVersion.WithKind("Service"))}
+		}
+		annotations = dast.Spec.Analyzer.Service.GetAnnotations()
+	case dast.Spec.Analyzer.Ingress != nil:
+		if dast.Spec.Analyzer.Ingress.Namespace == dast.GetNamespace() {
+			ingress := &metav1.ObjectMeta{Name: dast.Spec.Analyzer.Ingress.Name, UID: dast.Spec.Analyzer.Ingress.UID}
+			ownerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(ingress, networkingv1.SchemeGroupVersion.WithKind("Ingress"))}
+		}
+	default:
 		ownerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(dast, securityv1alpha1.GroupVersion.WithKind("Dast"))}
 	}
 
-	annotations := dast.Spec.Analyzer.Service.GetAnnotations()
-
+	// TODO use https
+	zapAddr := "http://" + dast.Spec.ZaProxy.Name + ":8080"
+	if UsesSidecar(dast) {
+		zapAddr = "http://127.0.0.1:8080"
+	}
 	command := []string{
 		"/dynamic-analyzer",
 		"scanner",
 		"-t",
 		dast.Spec.Analyzer.Target,
 		"-p",
-		// TODO use https
-		"http://" + dast.Spec.ZaProxy.Name + ":8080",
+		zapAddr,
 	}
 
 	apiScan, ok := annotations["dast.security.banzaicloud.io/apiscan"]
@@ -69,8 +100,7 @@ func newAnalyzerJob(dast *securityv1alpha1.Dast) *batchv1.Job {
 					"-o",
 					openapiURL,
 					"-p",
-					// TODO use https
-					"http://" + dast.Spec.ZaProxy.Name + ":8080",
+					zapAddr,
 				}
 			} else {
 				log.Info("openapi url is missing")
@@ -78,30 +108,91 @@ func newAnalyzerJob(dast *securityv1alpha1.Dast) *batchv1.Job {
 		}
 	}
 
-	backofflimit := int32(5)
+	command = append(command, withAuthentication(dast.Spec.Analyzer.Authentication)...)
+	if UsesSidecar(dast) {
+		command = append(command, "--sidecar")
+	}
+	command = append(command, withScope(dast.Spec.Analyzer.Scope)...)
+	if dast.Spec.Analyzer.AjaxSpider && command[1] == "scanner" {
+		command = append(command, "--ajax-spider")
+	}
+	for _, script := range dast.Spec.Analyzer.Scripts {
+		command = append(command, "--script", strings.Join([]string{
+			script.Name,
+			string(script.Type),
+			zaproxy.ScriptEngine(script),
+			zaproxy.ScriptPath(script),
+		}, ","))
+	}
+	command = append(command, withTimeouts(dast.Spec.Analyzer)...)
+	if failOn := withThresholds(dast.Spec.Analyzer.FailOn); failOn != "" {
+		command = append(command, "--fail-on", failOn)
+	}
+	if scanPolicy != nil {
+		policy, err := json.Marshal(scanPolicy.Spec)
+		if err != nil {
+			log.Errorf("failed to marshal scan policy: %v", err)
+		} else {
+			command = append(command, "--scan-policy", scanPolicy.GetName(), "--scan-policy-config", string(policy))
+		}
+	}
+
+	// a scan isn't retried, the result of the first attempt is final
+	backofflimit := int32(0)
 	completion := int32(1)
+	var activeDeadlineSeconds *int64
+	if dast.Spec.Analyzer.MaxDuration != nil {
+		// let the analyzer stop the scans and report partial results before the job is terminated
+		deadline := int64((dast.Spec.Analyzer.MaxDuration.Duration + jobDeadlineGracePeriod).Seconds())
+		activeDeadlineSeconds = &deadline
+	}
+	podSpec := corev1.PodSpec{
+		RestartPolicy: "Never",
+		Containers: []corev1.Container{
+			{
+				Name:            dast.Spec.Analyzer.Name,
+				Image:           dast.Spec.Analyzer.Image,
+				ImagePullPolicy: "IfNotPresent",
+				Command:         command,
+				Env:             withEnv(dast),
+			},
+		},
+	}
+	if UsesSidecar(dast) {
+		sidecar, volumes := zaproxy.Sidecar(dast)
+		sidecar.Env = []corev1.EnvVar{apiKeyEnv(dast)}
+		podSpec.HostAliases = dast.Spec.Analyzer.HostAliases
+		podSpec.Containers = append(podSpec.Containers, sidecar)
+		podSpec.Volumes = volumes
+	}
 	return &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            dast.Spec.Analyzer.Name,
+			Name:            JobName(dast),
 			Namespace:       dast.Namespace,
+			Labels:          jobLabels(dast),
 			OwnerReferences: ownerReferences,
 		},
 		Spec: batchv1.JobSpec{
-			BackoffLimit: &backofflimit,
-			Completions:  &completion,
+			BackoffLimit:          &backofflimit,
+			Completions:           &completion,
+			ActiveDeadlineSeconds: activeDeadlineSeconds,
 			Template: corev1.PodTemplateSpec{
-				Spec: corev1.PodSpec{
-					RestartPolicy: "Never",
-					Containers: []corev1.Container{
-						{
-							Name:            dast.Spec.Analyzer.Name,
-							Image:           dast.Spec.Analyzer.Image,
-							ImagePullPolicy: "IfNotPresent",
-							Command:         command,
-							Env:             withEnv(dast),
-						},
-					},
+				Spec: podSpec,
+			},
+		},
+	}
+}
+
+// apiKeyEnv reads the API key of the ZAP of the Dast from its secret
+func apiKeyEnv(dast *securityv1alpha1.Dast) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: zaproxy.APIKeyEnv,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: dast.Spec.ZaProxy.Name,
 				},
+				Key: "zap_api_key",
 			},
 		},
 	}
@@ -109,17 +200,134 @@ func newAnalyzerJob(dast *securityv1alpha1.Dast) *batchv1.Job {
 
 func withEnv(dast *securityv1alpha1.Dast) []corev1.EnvVar {
 	env := []corev1.EnvVar{
+		apiKeyEnv(dast),
 		{
-			Name: "ZAPAPIKEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: dast.Spec.ZaProxy.Name,
+			// the configuration added to the shared ZAP instance is named after the run
+			Name:  "DAST_RUN_ID",
+			Value: JobName(dast),
+		},
+	}
+
+	if auth := dast.Spec.Analyzer.Authentication; auth != nil {
+		for _, secretEnv := range []struct{ name, key string }{
+			{"DAST_AUTH_USERNAME", "username"},
+			{"DAST_AUTH_PASSWORD", "password"},
+			{"DAST_AUTH_TOKEN", "token"},
+			{"DAST_AUTH_CLIENT_ID", "client_id"},
+			{"DAST_AUTH_CLIENT_SECRET", "client_secret"},
+		} {
+			optional := true
+			env = append(env, corev1.EnvVar{
+				Name: secretEnv.name,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: auth.SecretName,
+						},
+						Key:      secretEnv.key,
+						Optional: &optional,
 					},
-					Key: "zap_api_key",
 				},
-			},
-		},
+			})
+		}
 	}
 	return env
 }
+
+func withAuthentication(auth *securityv1alpha1.Authentication) []string {
+	if auth == nil {
+		return nil
+	}
+	args := []string{"--auth-type", string(auth.Type)}
+	for _, opt := range []struct{ flag, value string }{
+		{"--login-url", auth.LoginURL},
+		{"--login-data", auth.LoginRequestData},
+		{"--logged-in-indicator", auth.LoggedInIndicator},
+		{"--logged-out-indicator", auth.LoggedOutIndicator},
+		{"--auth-header", auth.HeaderName},
+		{"--token-url", auth.TokenURL},
+		{"--scopes", strings.Join(auth.Scopes, ",")},
+	} {
+		if opt.value != "" {
+			args = append(args, opt.flag, opt.value)
+		}
+	}
+	return args
+}
+
+func withScope(scope *securityv1alpha1.Scope) []string {
+	if scope == nil {
+		return nil
+	}
+	var args []string
+	for _, regex := range scope.Include {
+		args = append(args, "--include", regex)
+	}
+	for _, regex := range scope.Exclude {
+		args = append(args, "--exclude", regex)
+	}
+	if len(scope.Technologies) > 0 {
+		args = append(args, "--technologies", strings.Join(scope.Technologies, ","))
+	}
+	return args
+}
+
+func withTimeouts(analyzer securityv1alpha1.Analyzer) []string {
+	var args []string
+	if analyzer.MaxDuration != nil {
+		args = append(args, "--max-duration", analyzer.MaxDuration.Duration.String())
+	}
+	if timeouts := analyzer.Timeouts; timeouts != nil {
+		for _, opt := range []struct {
+			flag    string
+			timeout *metav1.Duration
+		}{
+			{"--spider-timeout", timeouts.Spider},
+			{"--ajax-spider-timeout", timeouts.AjaxSpider},
+			{"--active-scan-timeout", timeouts.ActiveScan},
+		} {
+			if opt.timeout != nil {
+				args = append(args, opt.flag, opt.timeout.Duration.String())
+			}
+		}
+	}
+	return args
+}
+
+func withThresholds(thresholds *securityv1alpha1.Thresholds) string {
+	if thresholds == nil {
+		return ""
+	}
+	var items []string
+	for _, threshold := range []struct {
+		risk  string
+		value *int
+	}{
+		{"high", thresholds.High},
+		{"medium", thresholds.Medium},
+		{"low", thresholds.Low},
+		{"informational", thresholds.Informational},
+	} {
+		if threshold.value != nil {
+			items = append(items, threshold.risk+"="+strconv.Itoa(*threshold.value))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+func jobLabels(dast *securityv1alpha1.Dast) map[string]string {
+	labels := map[string]string{
+		"app":        componentName,
+		ZaProxyLabel: dast.Spec.ZaProxy.Name,
+		ScanLabel:    dast.Spec.Analyzer.Name,
+	}
+	if dast.Spec.Analyzer.Service != nil {
+		labels[ServiceNameLabel] = dast.Spec.Analyzer.Service.GetName()
+		labels[ServiceNamespaceLabel] = dast.Spec.Analyzer.Service.GetNamespace()
+	}
+	if dast.Spec.Analyzer.Ingress != nil {
+		labels[IngressNameLabel] = dast.Spec.Analyzer.Ingress.Name
+		labels[IngressNamespaceLabel] = dast.Spec.Analyzer.Ingress.Namespace
+	}
+	return labels
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analyzer
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+// TestJobOwnerReferences checks that the jobs are owned by their target only in the namespace of the target,
+// owners in other namespaces are invalid and get the jobs garbage collected
+func TestJobOwnerReferences(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "service-uid"}}
+	tests := []struct {
+		name     string
+		dast     *securityv1alpha1.Dast
+		expected string
+	}{
+		{
+			name: "service in the namespace of ZAP",
+			dast: &securityv1alpha1.Dast{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+				Spec:       securityv1alpha1.DastSpec{Analyzer: securityv1alpha1.Analyzer{Name: "app", Service: service}},
+			},
+			expected: "Service",
+		},
+		{
+			name: "service in another namespace",
+			dast: &securityv1alpha1.Dast{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "zaproxy"},
+				Spec:       securityv1alpha1.DastSpec{Analyzer: securityv1alpha1.Analyzer{Name: "app", Service: service}},
+			},
+		},
+		{
+			name: "ingress in the namespace of ZAP",
+			dast: &securityv1alpha1.Dast{
+				ObjectMeta: metav1.ObjectMeta{Name: "ingress-shop", Namespace: "default"},
+				Spec: securityv1alpha1.DastSpec{Analyzer: securityv1alpha1.Analyzer{
+					Name:    "ingress-shop",
+					Ingress: &securityv1alpha1.IngressTarget{Name: "shop", Namespace: "default", UID: "ingress-uid", Host: "shop.example.com"},
+				}},
+			},
+			expected: "Ingress",
+		},
+		{
+			name: "ingress in another namespace",
+			dast: &securityv1alpha1.Dast{
+				ObjectMeta: metav1.ObjectMeta{Name: "ingress-shop", Namespace: "zaproxy"},
+				Spec: securityv1alpha1.DastSpec{Analyzer: securityv1alpha1.Analyzer{
+					Name:    "ingress-shop",
+					Ingress: &securityv1alpha1.IngressTarget{Name: "shop", Namespace: "default", UID: "ingress-uid", Host: "shop.example.com"},
+				}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := newAnalyzerJob(test.dast, nil)
+			owners := job.GetOwnerReferences()
+			if test.expected == "" {
+				if len(owners) != 0 {
+					t.Errorf("job is owned across namespaces: %v", owners)
+				}
+				if job.GetLabels()[ServiceNamespaceLabel]+job.GetLabels()[IngressNamespaceLabel] != "default" {
+					t.Errorf("job isn't labeled with its target: %v", job.GetLabels())
+				}
+				return
+			}
+			if len(owners) != 1 || owners[0].Kind != test.expected || owners[0].APIVersion != "v1" && test.expected == "Service" {
+				t.Errorf("unexpected owners %v", owners)
+			}
+		})
+	}
+}
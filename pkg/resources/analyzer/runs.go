@@ -0,0 +1,113 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analyzer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"emperror.dev/emperror"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+// RescanAnnotation starts a new run of the scan when its value changes, it can hold a timestamp or a nonce
+const RescanAnnotation = "dast.security.banzaicloud.io/rescan"
+
+// JobName returns the name of the analyzer job of the current run of the Dast.
+// It's the analyzer name without rescan annotation, and the analyzer name with the hash of the annotation otherwise.
+func JobName(dast *securityv1alpha1.Dast) string {
+	rescan := dast.GetAnnotations()[RescanAnnotation]
+	if rescan == "" {
+		return dast.Spec.Analyzer.Name
+	}
+	name := dast.Spec.Analyzer.Name
+	if len(name) > 54 {
+		name = name[:54]
+	}
+	sum := sha256.Sum256([]byte(rescan))
+	return fmt.Sprintf("%s-%x", name, sum[:4])
+}
+
+// CleanupRuns stops the jobs of the previous runs of the scan and deletes the oldest ones, the latest history jobs are kept
+func CleanupRuns(c client.Client, dast *securityv1alpha1.Dast, history int) error {
+	ctx := context.TODO()
+	current := JobName(dast)
+
+	var jobs batchv1.JobList
+	if err := c.List(ctx, &jobs, client.InNamespace(dast.GetNamespace()), client.MatchingLabels{ScanLabel: dast.Spec.Analyzer.Name}); err != nil {
+		return emperror.Wrap(err, "failed to list analyzer jobs")
+	}
+	var previous []batchv1.Job
+	for _, job := range jobs.Items {
+		if job.GetName() != current {
+			previous = append(previous, job)
+		}
+	}
+	if current != dast.Spec.Analyzer.Name {
+		// jobs created before the scan label was introduced
+		var job batchv1.Job
+		err := c.Get(ctx, types.NamespacedName{Name: dast.Spec.Analyzer.Name, Namespace: dast.GetNamespace()}, &job)
+		switch {
+		case err == nil && job.GetLabels()[ScanLabel] == "":
+			previous = append(previous, job)
+		case err != nil && !apierrors.IsNotFound(err):
+			return emperror.Wrap(err, "failed to get analyzer job")
+		}
+	}
+
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[j].CreationTimestamp.Before(&previous[i].CreationTimestamp)
+	})
+	for i := range previous {
+		job := &previous[i]
+		if i < history {
+			if err := StopJob(c, job); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return emperror.WrapWith(err, "failed to delete analyzer job", "job", job.GetName())
+		}
+	}
+	return nil
+}
+
+// DeleteTargetJobs deletes the analyzer jobs of a deleted service or ingress in any namespace.
+// The jobs in other namespaces than the target have no owner reference, they are found by the labels of the target.
+func DeleteTargetJobs(c client.Client, labels client.MatchingLabels) error {
+	ctx := context.TODO()
+	var jobs batchv1.JobList
+	if err := c.List(ctx, &jobs, labels); err != nil {
+		return emperror.Wrap(err, "failed to list analyzer jobs")
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if err := c.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return emperror.WrapWith(err, "failed to delete analyzer job", "job", job.GetName(), "namespace", job.GetNamespace())
+		}
+	}
+	return nil
+}
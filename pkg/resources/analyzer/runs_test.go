@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analyzer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+func TestCleanupRuns(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	newJob := func(name string, age time.Duration, labeled bool) runtime.Object {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "zaproxy",
+			CreationTimestamp: metav1.Time{Time: now.Add(-age)},
+		}}
+		if labeled {
+			job.SetLabels(map[string]string{ScanLabel: "app"})
+		}
+		return job
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		newJob("app", 3*time.Hour, false),
+		newJob("app-1", 2*time.Hour, true),
+		newJob("app-2", time.Hour, true),
+		newJob("other", time.Hour, false),
+	)
+
+	dast := &securityv1alpha1.Dast{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "zaproxy", Annotations: map[string]string{RescanAnnotation: "3"}},
+		Spec:       securityv1alpha1.DastSpec{Analyzer: securityv1alpha1.Analyzer{Name: "app"}},
+	}
+	if name := JobName(dast); name == "app" || len(name) != len("app")+9 {
+		t.Errorf("unexpected job name of the rescan: %s", name)
+	}
+	if err := CleanupRuns(c, dast, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var jobs batchv1.JobList
+	if err := c.List(context.Background(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	kept := map[string]bool{}
+	for _, job := range jobs.Items {
+		kept[job.GetName()] = true
+	}
+	if len(kept) != 2 || !kept["app-2"] || !kept["other"] {
+		t.Errorf("unexpected jobs are kept: %v", kept)
+	}
+
+	var latest batchv1.Job
+	if err := c.Get(context.Background(), types.NamespacedName{Name: "app-2", Namespace: "zaproxy"}, &latest); err != nil {
+		t.Fatal(err)
+	}
+	if latest.Spec.Parallelism == nil || *latest.Spec.Parallelism != 0 {
+		t.Error("job of the previous run isn't stopped")
+	}
+}
+
+func TestDeleteTargetJobs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	newJob := func(name, namespace, ingress string) runtime.Object {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{IngressNameLabel: ingress, IngressNamespaceLabel: "default"},
+		}}
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		newJob("ingress-shop-a", "zaproxy", "shop"),
+		newJob("ingress-shop-b", "other-zaproxy", "shop"),
+		newJob("ingress-blog", "zaproxy", "blog"),
+	)
+
+	if err := DeleteTargetJobs(c, client.MatchingLabels{IngressNameLabel: "shop", IngressNamespaceLabel: "default"}); err != nil {
+		t.Fatal(err)
+	}
+	var jobs batchv1.JobList
+	if err := c.List(context.Background(), &jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs.Items) != 1 || jobs.Items[0].GetName() != "ingress-blog" {
+		t.Errorf("unexpected jobs are kept: %v", jobs.Items)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"emperror.dev/emperror"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+// exit codes of the dynamic analyzer
+const (
+	exitCodeThresholdExceeded = 2
+	exitCodeTimeout           = 3
+	exitCodeInterrupted       = 143
+)
+
+// ScanResult is the outcome of an analyzer job
+type ScanResult struct {
+	Finished bool
+	Passed   bool
+	Reason   string
+	Message  string
+	// AlertsSummary is the number of alerts per risk level reported by the analyzer
+	AlertsSummary map[string]int
+	// AlertsAfter is the id of the latest alert of ZAP before the scan, the alerts of the scan have higher ids.
+	// It's nil if the analyzer didn't report it.
+	AlertsAfter *int
+	// Pod is the name of the analyzer pod which reported the result, empty if no analyzer container terminated
+	Pod string
+}
+
+// terminationMessage is written by the analyzer when the scan is finished
+type terminationMessage struct {
+	AlertsSummary map[string]int `json:"alertsSummary"`
+	Message       string         `json:"message"`
+	AlertsAfter   *int           `json:"alertsAfter"`
+}
+
+// GetScanResult evaluates the analyzer job and the exit codes of its pods
+func GetScanResult(c client.Client, job *batchv1.Job) (*ScanResult, error) {
+	var pods corev1.PodList
+	if err := c.List(context.TODO(), &pods, client.InNamespace(job.GetNamespace()), client.MatchingLabels{"job-name": job.GetName()}); err != nil {
+		return nil, emperror.Wrap(err, "failed to list analyzer pods")
+	}
+
+	var last *corev1.ContainerStateTerminated
+	var lastPod string
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			// the ZAP sidecar, if any, doesn't report the scan
+			if len(job.Spec.Template.Spec.Containers) > 0 && status.Name != job.Spec.Template.Spec.Containers[0].Name {
+				continue
+			}
+			terminated := status.State.Terminated
+			if terminated == nil {
+				continue
+			}
+			if last == nil || last.FinishedAt.Before(&terminated.FinishedAt) {
+				last = terminated
+				lastPod = pod.GetName()
+			}
+			// a completed scan or a scan exceeding the thresholds is final even before the job is completed
+			if terminated.ExitCode == 0 || terminated.ExitCode == exitCodeThresholdExceeded || terminated.ExitCode == exitCodeTimeout {
+				result := scanResultFromExitCode(terminated)
+				result.Pod = pod.GetName()
+				return result, nil
+			}
+		}
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			if last != nil {
+				result := scanResultFromExitCode(last)
+				result.Pod = lastPod
+				return result, nil
+			}
+			reason := securityv1alpha1.ReasonError
+			if condition.Reason == "DeadlineExceeded" {
+				reason = securityv1alpha1.ReasonTimeout
+			}
+			return &ScanResult{Finished: true, Reason: reason, Message: condition.Message}, nil
+		}
+	}
+
+	return &ScanResult{Reason: securityv1alpha1.ReasonScanInProgress, Message: "analyzer job is running"}, nil
+}
+
+func scanResultFromExitCode(terminated *corev1.ContainerStateTerminated) *ScanResult {
+	result := &ScanResult{Finished: true}
+	msg := terminationMessage{}
+	if err := json.Unmarshal([]byte(terminated.Message), &msg); err == nil {
+		result.Message = msg.Message
+		result.AlertsSummary = msg.AlertsSummary
+		result.AlertsAfter = msg.AlertsAfter
+	}
+
+	switch terminated.ExitCode {
+	case 0:
+		result.Passed = true
+		result.Reason = securityv1alpha1.ReasonScanCompleted
+		if result.Message == "" {
+			result.Message = "scan completed, results are below the thresholds"
+		}
+	case exitCodeThresholdExceeded:
+		result.Reason = securityv1alpha1.ReasonThresholdExceeded
+	case exitCodeTimeout:
+		result.Reason = securityv1alpha1.ReasonTimeout
+		if result.Message == "" {
+			result.Message = "scan timed out, results are partial"
+		}
+	case exitCodeInterrupted:
+		result.Reason = securityv1alpha1.ReasonInterrupted
+		result.Message = "scan was interrupted"
+	default:
+		result.Reason = securityv1alpha1.ReasonError
+		result.Message = fmt.Sprintf("analyzer exited with code %d", terminated.ExitCode)
+	}
+	return result
+}
+
+// SetScanConditions sets the ScanPassed and ScanFailed conditions according to the result
+func SetScanConditions(conditions *[]metav1.Condition, result *ScanResult) {
+	passed := metav1.ConditionFalse
+	failed := metav1.ConditionFalse
+	if result.Finished {
+		if result.Passed {
+			passed = metav1.ConditionTrue
+		} else {
+			failed = metav1.ConditionTrue
+		}
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    securityv1alpha1.ScanPassed,
+		Status:  passed,
+		Reason:  result.Reason,
+		Message: result.Message,
+	})
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    securityv1alpha1.ScanFailed,
+		Status:  failed,
+		Reason:  result.Reason,
+		Message: result.Message,
+	})
+}
+
+// StopJob stops the running pods of a job, whose scan is superseded by a new run
+func StopJob(c client.Client, job *batchv1.Job) error {
+	if job.Spec.Parallelism != nil && *job.Spec.Parallelism == 0 {
+		return nil
+	}
+	if job.Status.CompletionTime != nil {
+		return nil
+	}
+	parallelism := int32(0)
+	job.Spec.Parallelism = &parallelism
+	return emperror.Wrap(c.Update(context.TODO(), job), "failed to stop analyzer job")
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analyzer
+
+import (
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+func TestGetScanResult(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	failed := []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+
+	tests := []struct {
+		name       string
+		exitCode   *int32
+		message    string
+		conditions []batchv1.JobCondition
+		expected   ScanResult
+	}{
+		{
+			name:     "running",
+			expected: ScanResult{Reason: securityv1alpha1.ReasonScanInProgress, Message: "analyzer job is running"},
+		},
+		{
+			name:     "passed",
+			exitCode: int32Ptr(0),
+			message:  `{"alertsSummary":{"High":0},"message":"0 high alerts","alertsAfter":4}`,
+			expected: ScanResult{Finished: true, Passed: true, Reason: securityv1alpha1.ReasonScanCompleted, Message: "0 high alerts", AlertsSummary: map[string]int{"High": 0}, AlertsAfter: intPtr(4)},
+		},
+		{
+			name:     "threshold exceeded",
+			exitCode: int32Ptr(exitCodeThresholdExceeded),
+			message:  `{"alertsSummary":{"High":2},"message":"2 high alerts exceed the threshold 0"}`,
+			expected: ScanResult{Finished: true, Reason: securityv1alpha1.ReasonThresholdExceeded, Message: "2 high alerts exceed the threshold 0", AlertsSummary: map[string]int{"High": 2}},
+		},
+		{
+			// the job isn't failed until the job controller notices the failed pod
+			name:     "error before the job failed",
+			exitCode: int32Ptr(1),
+			expected: ScanResult{Reason: securityv1alpha1.ReasonScanInProgress, Message: "analyzer job is running"},
+		},
+		{
+			name:       "error",
+			exitCode:   int32Ptr(1),
+			conditions: failed,
+			expected:   ScanResult{Finished: true, Reason: securityv1alpha1.ReasonError, Message: "analyzer exited with code 1"},
+		},
+		{
+			name:       "deadline exceeded",
+			conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "DeadlineExceeded", Message: "Job was active longer than specified deadline"}},
+			expected:   ScanResult{Finished: true, Reason: securityv1alpha1.ReasonTimeout, Message: "Job was active longer than specified deadline"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "zaproxy"},
+				Status:     batchv1.JobStatus{Conditions: test.conditions},
+			}
+			var objects []runtime.Object
+			if test.exitCode != nil {
+				objects = append(objects, &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "zaproxy", Labels: map[string]string{"job-name": "app"}},
+					Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: *test.exitCode, Message: test.message}},
+					}}},
+				})
+			}
+			c := fake.NewFakeClientWithScheme(scheme, objects...)
+
+			result, err := GetScanResult(c, job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Finished != test.expected.Finished || result.Passed != test.expected.Passed || result.Reason != test.expected.Reason ||
+				result.Message != test.expected.Message || len(result.AlertsSummary) != len(test.expected.AlertsSummary) {
+				t.Errorf("expected %+v, got %+v", test.expected, *result)
+			}
+			if (result.AlertsAfter == nil) != (test.expected.AlertsAfter == nil) || (result.AlertsAfter != nil && *result.AlertsAfter != *test.expected.AlertsAfter) {
+				t.Errorf("expected alerts after %v, got %v", test.expected.AlertsAfter, result.AlertsAfter)
+			}
+		})
+	}
+}
+
+func TestGetScanResultWithSidecar(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "zaproxy"},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}, {Name: "zap-proxy"}},
+		}}},
+	}
+	// the sidecar exited, but the analyzer is still running
+	c := fake.NewFakeClientWithScheme(scheme, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-1", Namespace: "zaproxy", Labels: map[string]string{"job-name": "app"}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			{Name: "zap-proxy", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+		}},
+	})
+
+	result, err := GetScanResult(c, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Finished {
+		t.Errorf("scan is finished by the sidecar: %+v", *result)
+	}
+
+	// the alerts of the sidecar are read from the log of the pod of the analyzer
+	c = fake.NewFakeClientWithScheme(scheme, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-2", Namespace: "zaproxy", Labels: map[string]string{"job-name": "app"}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+			{Name: "zap-proxy", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+		}},
+	})
+	result, err = GetScanResult(c, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Finished || result.Pod != "app-2" {
+		t.Errorf("unexpected result of the analyzer pod: %+v", *result)
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func intPtr(i int) *int {
+	return &i
+}
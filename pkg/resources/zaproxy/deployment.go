@@ -27,6 +27,9 @@ import (
 	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
 )
 
+// APIKeyEnv is the environment variable of the ZAP API key in the containers of the analyzer job
+const APIKeyEnv = "ZAPAPIKEY"
+
 // deployment return a deployment for zaproxy
 func (r *Reconciler) deployment(log logr.Logger) runtime.Object {
 
@@ -46,6 +49,8 @@ func newDeployment(dast *securityv1alpha1.Dast) *appsv1.Deployment {
 		zapImage = dast.Spec.ZaProxy.Image
 	}
 
+	volumes, volumeMounts := withScripts(dast.Spec.Analyzer.Scripts)
+
 	replicas := int32(1)
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -65,12 +70,14 @@ func newDeployment(dast *securityv1alpha1.Dast) *appsv1.Deployment {
 					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
+					Volumes: volumes,
 					Containers: []corev1.Container{
 						{
-							Name:    "zap-proxy",
-							Image:   zapImage,
-							Command: []string{"zap.sh"},
-							Args:    withArgs(dast.Spec.ZaProxy),
+							Name:         "zap-proxy",
+							Image:        zapImage,
+							Command:      []string{"zap.sh"},
+							Args:         withArgs(dast.Spec.ZaProxy, "0.0.0.0"),
+							VolumeMounts: volumeMounts,
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "http",
@@ -102,11 +109,30 @@ func newDeployment(dast *securityv1alpha1.Dast) *appsv1.Deployment {
 	}
 }
 
-func withArgs(zaProxy securityv1alpha1.ZaProxy) []string {
+// Sidecar returns a ZAP container listening on localhost:8080 and its volumes, which is run in the pod of an analyzer job.
+// Its API key is read from the APIKeyEnv environment variable of the container.
+func Sidecar(dast *securityv1alpha1.Dast) (corev1.Container, []corev1.Volume) {
+	zapImage := dast.Spec.ZaProxy.Image
+	if zapImage == "" {
+		zapImage = "owasp/zap2docker-live"
+	}
+	zaProxy := dast.Spec.ZaProxy
+	zaProxy.APIKey = "$(" + APIKeyEnv + ")"
+	volumes, volumeMounts := withScripts(dast.Spec.Analyzer.Scripts)
+	return corev1.Container{
+		Name:         "zap-proxy",
+		Image:        zapImage,
+		Command:      []string{"zap.sh"},
+		Args:         withArgs(zaProxy, "127.0.0.1"),
+		VolumeMounts: volumeMounts,
+	}, volumes
+}
+
+func withArgs(zaProxy securityv1alpha1.ZaProxy, host string) []string {
 	args := []string{
 		"-daemon",
 		"-host",
-		"0.0.0.0",
+		host,
 		"-port",
 		"8080",
 		"-config",
@@ -125,3 +151,32 @@ func withArgs(zaProxy securityv1alpha1.ZaProxy) []string {
 
 	return args
 }
+
+func withScripts(scripts []securityv1alpha1.Script) ([]corev1.Volume, []corev1.VolumeMount) {
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	mounted := map[string]bool{}
+	for _, script := range scripts {
+		if mounted[script.ConfigMap] {
+			continue
+		}
+		mounted[script.ConfigMap] = true
+		volumeName := "script-" + script.ConfigMap
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: script.ConfigMap,
+					},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: ScriptsMountPath + "/" + script.ConfigMap,
+			ReadOnly:  true,
+		})
+	}
+	return volumes, volumeMounts
+}
@@ -28,6 +28,9 @@ import (
 
 const (
 	componentName = "zaproxy"
+	// ScriptsMountPath is the directory of the mounted script ConfigMaps in the ZAP container
+	ScriptsMountPath    = "/home/zap/dast-scripts"
+	defaultScriptEngine = "Oracle Nashorn"
 )
 
 var labelSelector = map[string]string{
@@ -71,3 +74,20 @@ func (r *Reconciler) Reconcile(log logr.Logger) error {
 
 	return nil
 }
+
+// ScriptPath returns the path of the script in the ZAP container
+func ScriptPath(script securityv1alpha1.Script) string {
+	key := script.Key
+	if key == "" {
+		key = script.Name
+	}
+	return ScriptsMountPath + "/" + script.ConfigMap + "/" + key
+}
+
+// ScriptEngine returns the engine of the script
+func ScriptEngine(script securityv1alpha1.Script) string {
+	if script.Engine == "" {
+		return defaultScriptEngine
+	}
+	return script.Engine
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"context"
+	"encoding/json"
+
+	"emperror.dev/emperror"
+	"emperror.dev/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+const (
+	// AcceptBaselineLabel asks the operator to accept the alerts of the latest scan of the service into its baseline
+	AcceptBaselineLabel = "dast.security.banzaicloud.io/accept-baseline"
+	// AcceptedByAnnotation is the user, who is authorized to accept the baseline, it's set by the baseline webhook
+	AcceptedByAnnotation = "dast.security.banzaicloud.io/accepted-by"
+	// AcceptAlertsAnnotation restricts the acceptance to the alerts matching the AlertFilter in JSON, which are added to the baseline
+	AcceptAlertsAnnotation = "dast.security.banzaicloud.io/accept-alerts"
+)
+
+// AlertFilter selects the alerts accepted into the baseline, the alerts of the latest scans of every port are selected without scan and port
+type AlertFilter struct {
+	ScanID   string `json:"scan,omitempty"`
+	Port     int32  `json:"port,omitempty"`
+	PluginID string `json:"pluginId"`
+	// URL and Param match every alert of the plugin if they're empty
+	URL   string `json:"url,omitempty"`
+	Param string `json:"param,omitempty"`
+}
+
+// Matches reports whether the alert is selected by the filter
+func (f *AlertFilter) Matches(alert Alert) bool {
+	return alert.PluginID == f.PluginID && (f.URL == "" || alert.URL == f.URL) && (f.Param == "" || alert.Param == f.Param)
+}
+
+// ParseAlertFilter parses the accept-alerts annotation, the filter is nil if the annotation isn't set
+func ParseAlertFilter(annotations map[string]string) (*AlertFilter, error) {
+	value, ok := annotations[AcceptAlertsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	filter := &AlertFilter{}
+	if err := json.Unmarshal([]byte(value), filter); err != nil {
+		return nil, emperror.Wrap(err, "invalid accept-alerts annotation")
+	}
+	if filter.PluginID == "" {
+		return nil, errors.New("accept-alerts annotation requires the plugin id")
+	}
+	return filter, nil
+}
+
+// GetBaseline returns the baseline of the service, nil if it doesn't exist or it is expired
+func GetBaseline(ctx context.Context, c client.Reader, namespace, service string) (*securityv1alpha1.DastBaseline, error) {
+	var baseline securityv1alpha1.DastBaseline
+	if err := c.Get(ctx, types.NamespacedName{Name: service, Namespace: namespace}, &baseline); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if baseline.Spec.Service != service || baseline.Expired(metav1.Now()) {
+		return nil, nil
+	}
+	return &baseline, nil
+}
+
+// NewBaseline snapshots the alerts of the scan into a baseline
+func NewBaseline(scan *Scan, expiresAt *metav1.Time) *securityv1alpha1.DastBaseline {
+	baseline := &securityv1alpha1.DastBaseline{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      scan.Service,
+			Namespace: scan.Namespace,
+		},
+		Spec: securityv1alpha1.DastBaselineSpec{
+			Service:   scan.Service,
+			ScanID:    scan.ID,
+			ExpiresAt: expiresAt,
+		},
+	}
+	AddToBaseline(baseline, scan.Alerts)
+	return baseline
+}
+
+// AddToBaseline accepts the alerts in the baseline, it returns the number of alerts, which weren't accepted yet
+func AddToBaseline(baseline *securityv1alpha1.DastBaseline, alerts []Alert) int {
+	seen := map[string]bool{}
+	for _, alert := range baseline.Spec.Alerts {
+		seen[alertKey(alert.PluginID, alert.URL, alert.Method, alert.Param)] = true
+	}
+	added := 0
+	for _, alert := range alerts {
+		if seen[alert.Key()] {
+			continue
+		}
+		seen[alert.Key()] = true
+		baseline.Spec.Alerts = append(baseline.Spec.Alerts, securityv1alpha1.BaselineAlert{
+			PluginID: alert.PluginID,
+			URL:      alert.URL,
+			Method:   alert.Method,
+			Param:    alert.Param,
+			Name:     alert.Name,
+			Risk:     alert.Risk,
+		})
+		added++
+	}
+	return added
+}
+
+// ExcludeBaseline returns the alerts which aren't accepted by the baseline, all of them if baseline is nil
+func ExcludeBaseline(alerts []Alert, baseline *securityv1alpha1.DastBaseline) []Alert {
+	if baseline == nil {
+		return alerts
+	}
+	accepted := map[string]bool{}
+	for _, alert := range baseline.Spec.Alerts {
+		accepted[alertKey(alert.PluginID, alert.URL, alert.Method, alert.Param)] = true
+	}
+	filtered := []Alert{}
+	for _, alert := range alerts {
+		if !accepted[alert.Key()] {
+			filtered = append(filtered, alert)
+		}
+	}
+	return filtered
+}
+
+// ExceedsThresholds reports whether the summary is above any of the thresholds, unset thresholds aren't checked
+func ExceedsThresholds(summary map[string]int, thresholds *securityv1alpha1.Thresholds) bool {
+	if thresholds == nil {
+		return false
+	}
+	for risk, threshold := range map[string]*int{
+		"High":          thresholds.High,
+		"Medium":        thresholds.Medium,
+		"Low":           thresholds.Low,
+		"Informational": thresholds.Informational,
+	} {
+		if threshold != nil && summary[risk] > *threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarize returns the number of alerts per risk level
+func Summarize(alerts []Alert) map[string]int {
+	summary := map[string]int{
+		"High":          0,
+		"Medium":        0,
+		"Low":           0,
+		"Informational": 0,
+	}
+	for _, alert := range alerts {
+		summary[alert.Risk]++
+	}
+	return summary
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+func TestNewBaseline(t *testing.T) {
+	xss := Alert{PluginID: "40012", Name: "XSS", Risk: RiskHigh, URL: "http://app/search", Method: "GET", Param: "q"}
+	header := Alert{PluginID: "10021", Name: "X-Content-Type-Options Header Missing", Risk: "Low", URL: "http://app/"}
+	expiresAt := &metav1.Time{Time: time.Now().Add(time.Hour)}
+
+	baseline := NewBaseline(&Scan{ID: "1", Namespace: "test", Service: "app", Alerts: []Alert{xss, header, header}}, expiresAt)
+	if baseline.GetName() != "app" || baseline.GetNamespace() != "test" {
+		t.Errorf("unexpected baseline %s/%s", baseline.GetNamespace(), baseline.GetName())
+	}
+	if baseline.Spec.Service != "app" || baseline.Spec.ScanID != "1" || baseline.Spec.ExpiresAt != expiresAt {
+		t.Errorf("unexpected baseline spec %+v", baseline.Spec)
+	}
+	expected := []securityv1alpha1.BaselineAlert{
+		{PluginID: "40012", URL: "http://app/search", Method: "GET", Param: "q", Name: "XSS", Risk: RiskHigh},
+		{PluginID: "10021", URL: "http://app/", Name: "X-Content-Type-Options Header Missing", Risk: "Low"},
+	}
+	if len(baseline.Spec.Alerts) != len(expected) {
+		t.Fatalf("unexpected baseline alerts %v", baseline.Spec.Alerts)
+	}
+	for i := range expected {
+		if baseline.Spec.Alerts[i] != expected[i] {
+			t.Errorf("unexpected baseline alert %+v, expected %+v", baseline.Spec.Alerts[i], expected[i])
+		}
+	}
+}
+
+func TestAddToBaseline(t *testing.T) {
+	xss := Alert{PluginID: "40012", Name: "XSS", Risk: RiskHigh, URL: "http://app/search", Method: "GET", Param: "q"}
+	sqli := Alert{PluginID: "40018", Name: "SQL Injection", Risk: RiskHigh, URL: "http://app/login", Method: "POST", Param: "user"}
+	otherParam := xss
+	otherParam.Param = "page"
+
+	baseline := NewBaseline(&Scan{ID: "1", Service: "app", Alerts: []Alert{xss}}, nil)
+	if added := AddToBaseline(baseline, []Alert{xss, sqli, sqli, otherParam}); added != 2 {
+		t.Errorf("unexpected number of added alerts %d", added)
+	}
+	if len(baseline.Spec.Alerts) != 3 {
+		t.Errorf("unexpected baseline alerts %v", baseline.Spec.Alerts)
+	}
+	if added := AddToBaseline(baseline, []Alert{sqli}); added != 0 {
+		t.Errorf("accepted alert is added again")
+	}
+}
+
+func TestExcludeBaseline(t *testing.T) {
+	xss := Alert{PluginID: "40012", Name: "XSS", Risk: RiskHigh, URL: "http://app/search", Method: "GET", Param: "q"}
+	sqli := Alert{PluginID: "40018", Name: "SQL Injection", Risk: RiskHigh, URL: "http://app/login", Method: "POST", Param: "user"}
+	otherURL := xss
+	otherURL.URL = "http://app/admin/search"
+	alerts := []Alert{xss, sqli, otherURL}
+
+	if filtered := ExcludeBaseline(alerts, nil); len(filtered) != len(alerts) {
+		t.Errorf("alerts are excluded without a baseline: %v", filtered)
+	}
+	baseline := NewBaseline(&Scan{ID: "1", Service: "app", Alerts: []Alert{xss}}, nil)
+	filtered := ExcludeBaseline(alerts, baseline)
+	if len(filtered) != 2 || filtered[0].Key() != sqli.Key() || filtered[1].Key() != otherURL.Key() {
+		t.Errorf("unexpected filtered alerts %v", filtered)
+	}
+	if summary := Summarize(filtered); summary[RiskHigh] != 2 {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
+
+func TestParseAlertFilter(t *testing.T) {
+	filter, err := ParseAlertFilter(map[string]string{AcceptAlertsAnnotation: `{"scan":"1","pluginId":"40012","url":"http://app.default.svc:80/"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *filter != (AlertFilter{ScanID: "1", PluginID: "40012", URL: "http://app.default.svc:80/"}) {
+		t.Errorf("unexpected filter %v", filter)
+	}
+	if !filter.Matches(Alert{PluginID: "40012", URL: "http://app.default.svc:80/", Param: "q"}) {
+		t.Error("alert of the filtered URL doesn't match")
+	}
+	if filter.Matches(Alert{PluginID: "40012", URL: "http://app.default.svc:8080/"}) {
+		t.Error("alert of another URL matches")
+	}
+
+	if filter, err := ParseAlertFilter(nil); err != nil || filter != nil {
+		t.Errorf("unexpected filter %v without annotation: %v", filter, err)
+	}
+	for _, value := range []string{"40012", `{"url":"http://app.default.svc:80/"}`} {
+		if _, err := ParseAlertFilter(map[string]string{AcceptAlertsAnnotation: value}); err == nil {
+			t.Errorf("invalid filter %q is accepted", value)
+		}
+	}
+}
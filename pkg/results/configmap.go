@@ -0,0 +1,320 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+
+	"emperror.dev/emperror"
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ScanLabel marks the ConfigMaps holding scan results
+	ScanLabel = "dast.security.banzaicloud.io/result"
+	// ServiceLabel holds the name of the scanned service
+	ServiceLabel = "dast.security.banzaicloud.io/result-service"
+	// IngressLabel holds the name of the ingress, whose host was scanned
+	IngressLabel = "dast.security.banzaicloud.io/result-ingress"
+
+	scanKey   = "scan.json"
+	alertsKey = "alerts.json.gz"
+	diffKey   = "diff.json.gz"
+
+	// maxConfigMapSize is the size limit of the stored data, it leaves room for the metadata under the 1 MiB limit of ConfigMaps
+	maxConfigMapSize = 1000 * 1024
+)
+
+// reportsByPriority is the order the reports are kept in, if the scan doesn't fit in a ConfigMap
+var reportsByPriority = []string{ReportXML, ReportJSON, ReportMarkdown, ReportHTML}
+
+// NewConfigMapStore creates a Store which keeps every scan in a ConfigMap in the namespace of the service.
+// Alerts and reports are compressed, only the latest history scans of a service are kept.
+func NewConfigMapStore(c client.Client, reader client.Reader, history int) Store {
+	return &configMapStore{
+		client:  c,
+		reader:  reader,
+		history: history,
+	}
+}
+
+// NewSignedConfigMapStore creates a ConfigMap Store, which signs the stored scans with the key.
+// Scans without valid signature, e.g. ConfigMaps created by the users of the namespace, aren't listed or returned.
+func NewSignedConfigMapStore(c client.Client, reader client.Reader, history int, key []byte) Store {
+	return &configMapStore{
+		client:  c,
+		reader:  reader,
+		history: history,
+		key:     key,
+	}
+}
+
+type configMapStore struct {
+	client  client.Client
+	reader  client.Reader
+	history int
+	// key signs the stored scans, the signatures aren't checked if it's empty
+	key []byte
+}
+
+// trusted reports whether the ConfigMap was stored by the store
+func (s *configMapStore) trusted(configMap *corev1.ConfigMap) bool {
+	return len(s.key) == 0 || verified(s.key, configMap)
+}
+
+func configMapName(id string) string {
+	return "dast-scan-" + id
+}
+
+func reportKey(format string) string {
+	return "report." + format + ".gz"
+}
+
+func (s *configMapStore) Save(ctx context.Context, scan *Scan, reports map[string][]byte) error {
+	alertsJSON, err := json.Marshal(scan.Alerts)
+	if err != nil {
+		return emperror.Wrap(err, "failed to marshal alerts")
+	}
+
+	binaryData := map[string][]byte{}
+	if binaryData[alertsKey], err = compress(alertsJSON); err != nil {
+		return err
+	}
+	if scan.Diff != nil {
+		diffJSON, err := json.Marshal(scan.Diff)
+		if err != nil {
+			return emperror.Wrap(err, "failed to marshal diff")
+		}
+		if binaryData[diffKey], err = compress(diffJSON); err != nil {
+			return err
+		}
+	}
+	size := len(binaryData[alertsKey]) + len(binaryData[diffKey])
+	if size > maxConfigMapSize {
+		return emperror.With(errors.New("alerts of the scan exceed the size limit of the ConfigMap"), "id", scan.ID, "size", size)
+	}
+
+	// the reports are kept in the order of priority while they fit
+	meta := *scan
+	meta.Alerts = nil
+	meta.Diff = nil
+	meta.OmittedReports = nil
+	for _, format := range reportsByPriority {
+		report, ok := reports[format]
+		if !ok {
+			continue
+		}
+		compressed, err := compress(report)
+		if err != nil {
+			return err
+		}
+		if size+len(compressed) > maxConfigMapSize {
+			meta.OmittedReports = append(meta.OmittedReports, format)
+			continue
+		}
+		size += len(compressed)
+		binaryData[reportKey(format)] = compressed
+	}
+	scanJSON, err := json.Marshal(meta)
+	if err != nil {
+		return emperror.Wrap(err, "failed to marshal scan")
+	}
+	scan.OmittedReports = meta.OmittedReports
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName(scan.ID),
+			Namespace: scan.Namespace,
+			Labels: map[string]string{
+				ScanLabel:    "true",
+				ServiceLabel: scan.Service,
+			},
+		},
+		Data: map[string]string{
+			scanKey: string(scanJSON),
+		},
+		BinaryData: binaryData,
+	}
+	if scan.Ingress != "" && len(validation.IsValidLabelValue(scan.Ingress)) == 0 {
+		configMap.Labels[IngressLabel] = scan.Ingress
+	}
+	if len(s.key) > 0 {
+		sign(s.key, configMap)
+	}
+	if err := s.client.Create(ctx, configMap); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return emperror.WrapWith(err, "failed to store scan", "id", scan.ID)
+		}
+		var existing corev1.ConfigMap
+		if err := s.reader.Get(ctx, types.NamespacedName{Name: configMap.GetName(), Namespace: configMap.GetNamespace()}, &existing); err != nil {
+			return emperror.WrapWith(err, "failed to get stored scan", "id", scan.ID)
+		}
+		if s.trusted(&existing) {
+			return nil
+		}
+		// a ConfigMap with the name of the scan, which wasn't stored by the operator, is replaced
+		configMap.ResourceVersion = existing.ResourceVersion
+		if err := s.client.Update(ctx, configMap); err != nil {
+			return emperror.WrapWith(err, "failed to replace untrusted scan", "id", scan.ID)
+		}
+	}
+
+	return s.prune(ctx, scan.Namespace, scan.Service, scan.Port)
+}
+
+// prune deletes the scans of the port of the service above the history limit
+func (s *configMapStore) prune(ctx context.Context, namespace, service string, port int32) error {
+	if s.history <= 0 {
+		return nil
+	}
+	scans, err := s.List(ctx, namespace, service)
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, scan := range scans {
+		if scan.Port != port {
+			continue
+		}
+		if kept < s.history {
+			kept++
+			continue
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapName(scan.ID),
+				Namespace: namespace,
+			},
+		}
+		if err := s.client.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+			return emperror.WrapWith(err, "failed to delete scan", "id", scan.ID)
+		}
+	}
+	return nil
+}
+
+func (s *configMapStore) List(ctx context.Context, namespace, service string) ([]Scan, error) {
+	var configMaps corev1.ConfigMapList
+	if err := s.reader.List(ctx, &configMaps, client.InNamespace(namespace), client.MatchingLabels{ScanLabel: "true", ServiceLabel: service}); err != nil {
+		return nil, emperror.Wrap(err, "failed to list scans")
+	}
+
+	scans := make([]Scan, 0, len(configMaps.Items))
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		if !s.trusted(configMap) {
+			continue
+		}
+		scan := Scan{}
+		if err := json.Unmarshal([]byte(configMap.Data[scanKey]), &scan); err != nil {
+			return nil, emperror.WrapWith(err, "failed to unmarshal scan", "configmap", configMap.GetName())
+		}
+		scans = append(scans, scan)
+	}
+	sort.SliceStable(scans, func(i, j int) bool {
+		if scans[i].CompletionTime == nil || scans[j].CompletionTime == nil {
+			return scans[j].CompletionTime == nil
+		}
+		return scans[j].CompletionTime.Before(scans[i].CompletionTime)
+	})
+	return scans, nil
+}
+
+func (s *configMapStore) Get(ctx context.Context, namespace, service, id string) (*Scan, error) {
+	configMap, err := s.get(ctx, namespace, service, id)
+	if err != nil {
+		return nil, err
+	}
+	scan := &Scan{}
+	if err := json.Unmarshal([]byte(configMap.Data[scanKey]), scan); err != nil {
+		return nil, emperror.WrapWith(err, "failed to unmarshal scan", "id", id)
+	}
+	alertsJSON, err := decompress(configMap.BinaryData[alertsKey])
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(alertsJSON, &scan.Alerts); err != nil {
+		return nil, emperror.WrapWith(err, "failed to unmarshal alerts", "id", id)
+	}
+	if compressed, ok := configMap.BinaryData[diffKey]; ok {
+		diffJSON, err := decompress(compressed)
+		if err != nil {
+			return nil, err
+		}
+		scan.Diff = &Diff{}
+		if err := json.Unmarshal(diffJSON, scan.Diff); err != nil {
+			return nil, emperror.WrapWith(err, "failed to unmarshal diff", "id", id)
+		}
+	}
+	return scan, nil
+}
+
+func (s *configMapStore) Report(ctx context.Context, namespace, service, id, format string) ([]byte, error) {
+	configMap, err := s.get(ctx, namespace, service, id)
+	if err != nil {
+		return nil, err
+	}
+	report, ok := configMap.BinaryData[reportKey(format)]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "reports"}, format)
+	}
+	return decompress(report)
+}
+
+func (s *configMapStore) get(ctx context.Context, namespace, service, id string) (*corev1.ConfigMap, error) {
+	var configMap corev1.ConfigMap
+	if err := s.reader.Get(ctx, types.NamespacedName{Name: configMapName(id), Namespace: namespace}, &configMap); err != nil {
+		return nil, err
+	}
+	if configMap.GetLabels()[ServiceLabel] != service || !s.trusted(&configMap) {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "scans"}, id)
+	}
+	return &configMap, nil
+}
+
+func compress(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		return nil, emperror.Wrap(err, "failed to compress")
+	}
+	if err := w.Close(); err != nil {
+		return nil, emperror.Wrap(err, "failed to compress")
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(content []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, emperror.Wrap(err, "failed to decompress")
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
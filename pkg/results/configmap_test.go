@@ -0,0 +1,246 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestConfigMapStore(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	store := NewConfigMapStore(c, c, 2)
+
+	now := time.Now()
+	for i, id := range []string{"1", "2", "3"} {
+		scan := &Scan{
+			ID:             id,
+			Namespace:      "default",
+			Service:        "app",
+			CompletionTime: &metav1.Time{Time: now.Add(time.Duration(i) * time.Minute)},
+			Alerts:         []Alert{{PluginID: "40012", Name: "XSS", Risk: RiskHigh, URL: "http://app.default.svc:80/"}},
+		}
+		reports, err := Reports(scan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(ctx, scan, reports); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scans, err := store.List(ctx, "default", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 2 || scans[0].ID != "3" || scans[1].ID != "2" {
+		t.Fatalf("unexpected scans %v", scans)
+	}
+	scan, err := store.Get(ctx, "default", "app", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.Alerts) != 1 || scan.Alerts[0].PluginID != "40012" {
+		t.Errorf("unexpected alerts %v", scan.Alerts)
+	}
+	if _, err := store.Get(ctx, "default", "other", "3"); !apierrors.IsNotFound(err) {
+		t.Errorf("scan of another service is returned: %v", err)
+	}
+	if report, err := store.Report(ctx, "default", "app", "3", ReportXML); err != nil || len(report) == 0 {
+		t.Errorf("xml report isn't stored: %v", err)
+	}
+
+	// the result labels don't overlap the labels of the analyzer jobs
+	var configMap corev1.ConfigMap
+	if err := c.Get(ctx, client.ObjectKey{Name: "dast-scan-3", Namespace: "default"}, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"dast.security.banzaicloud.io/result": "true", "dast.security.banzaicloud.io/result-service": "app"}
+	if len(configMap.GetLabels()) != len(expected) {
+		t.Errorf("unexpected labels %v", configMap.GetLabels())
+	}
+	for key, value := range expected {
+		if configMap.GetLabels()[key] != value {
+			t.Errorf("unexpected labels %v", configMap.GetLabels())
+		}
+	}
+}
+
+// TestConfigMapStorePorts checks that the scans of the ports of a service are kept and compared separately
+func TestConfigMapStorePorts(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	store := NewConfigMapStore(c, c, 1)
+
+	now := time.Now()
+	for i, scan := range []*Scan{
+		{ID: "legacy", Namespace: "default", Service: "app"},
+		{ID: "80-1", Namespace: "default", Service: "app", Port: 80},
+		{ID: "8080-1", Namespace: "default", Service: "app", Port: 8080},
+		{ID: "80-2", Namespace: "default", Service: "app", Port: 80},
+	} {
+		scan.CompletionTime = &metav1.Time{Time: now.Add(time.Duration(i) * time.Minute)}
+		if err := store.Save(ctx, scan, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scans, err := store.List(ctx, "default", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 3 || scans[0].ID != "80-2" || scans[1].ID != "8080-1" || scans[2].ID != "legacy" {
+		t.Fatalf("history isn't kept per port %v", scans)
+	}
+	latest, err := LatestOfPort(ctx, store, "default", "app", 8080)
+	if err != nil || latest == nil || latest.ID != "8080-1" {
+		t.Errorf("unexpected latest scan of the port %v: %v", latest, err)
+	}
+	if latest, err := LatestOfPort(ctx, store, "default", "app", 443); err != nil || latest != nil {
+		t.Errorf("unexpected latest scan of an unscanned port %v: %v", latest, err)
+	}
+	perPort, err := LatestPerPort(ctx, store, "default", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perPort) != 2 || perPort[0].ID != "80-2" || perPort[1].ID != "8080-1" {
+		t.Errorf("unexpected latest scans per port %v", perPort)
+	}
+}
+
+func TestConfigMapStoreSizeLimit(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	store := NewConfigMapStore(c, c, 10)
+
+	// random content doesn't compress
+	large := make([]byte, maxConfigMapSize/2)
+	if _, err := rand.Read(large); err != nil {
+		t.Fatal(err)
+	}
+	reports := map[string][]byte{
+		ReportXML:      []byte("<OWASPZAPReport/>"),
+		ReportJSON:     large,
+		ReportHTML:     large,
+		ReportMarkdown: []byte("# report"),
+	}
+	scan := &Scan{ID: "1", Namespace: "default", Service: "app"}
+	if err := store.Save(ctx, scan, reports); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.Get(ctx, "default", "app", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.OmittedReports) != 1 || stored.OmittedReports[0] != ReportHTML {
+		t.Errorf("unexpected omitted reports %v", stored.OmittedReports)
+	}
+	for _, format := range []string{ReportXML, ReportJSON, ReportMarkdown} {
+		if _, err := store.Report(ctx, "default", "app", "1", format); err != nil {
+			t.Errorf("%s report isn't stored: %v", format, err)
+		}
+	}
+	if _, err := store.Report(ctx, "default", "app", "1", ReportHTML); !apierrors.IsNotFound(err) {
+		t.Errorf("omitted report is found: %v", err)
+	}
+}
+
+func TestSignedConfigMapStore(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	key, err := NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewSignedConfigMapStore(c, c, 10, key)
+	forger := NewConfigMapStore(c, c, 10)
+	now := time.Now()
+
+	if err := store.Save(ctx, &Scan{ID: "1", Namespace: "default", Service: "app", CompletionTime: &metav1.Time{Time: now}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// a forged passing scan, which is newer than the stored one
+	if err := forger.Save(ctx, &Scan{ID: "2", Namespace: "default", Service: "app", CompletionTime: &metav1.Time{Time: now.Add(time.Minute)}, Passed: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	scans, err := store.List(ctx, "default", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 1 || scans[0].ID != "1" {
+		t.Errorf("forged scan is listed: %v", scans)
+	}
+	if _, err := store.Get(ctx, "default", "app", "2"); !apierrors.IsNotFound(err) {
+		t.Errorf("forged scan is returned: %v", err)
+	}
+	if latest, err := LatestOfPort(ctx, store, "default", "app", 0); err != nil || latest == nil || latest.ID != "1" || latest.Passed {
+		t.Errorf("unexpected latest scan %v: %v", latest, err)
+	}
+
+	// a stored scan with modified data isn't trusted
+	var configMap corev1.ConfigMap
+	if err := c.Get(ctx, client.ObjectKey{Name: configMapName("1"), Namespace: "default"}, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	configMap.Data[scanKey] = strings.Replace(configMap.Data[scanKey], `"passed":false`, `"passed":true`, 1)
+	if err := c.Update(ctx, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "default", "app", "1"); !apierrors.IsNotFound(err) {
+		t.Errorf("modified scan is returned: %v", err)
+	}
+
+	// the scan of the operator replaces a forged ConfigMap with its name
+	if err := store.Save(ctx, &Scan{ID: "2", Namespace: "default", Service: "app", CompletionTime: &metav1.Time{Time: now}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	scan, err := store.Get(ctx, "default", "app", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.Passed {
+		t.Error("forged scan isn't replaced")
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	key, err := LoadSigningKey(ctx, c, c, "dast-operator-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSigningKey(ctx, c, c, "dast-operator-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 32 || string(key) != string(loaded) {
+		t.Error("signing key isn't kept")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RiskHigh is the risk level of high alerts
+const RiskHigh = "High"
+
+// Diff holds the changes of the alerts compared to the previous scan of the service
+type Diff struct {
+	// PreviousID is the id of the compared scan, empty for the first scan of the service
+	PreviousID string  `json:"previousId,omitempty"`
+	New        []Alert `json:"new"`
+	Fixed      []Alert `json:"fixed"`
+	Unchanged  []Alert `json:"unchanged"`
+}
+
+// Key identifies an alert across scans
+func (a Alert) Key() string {
+	return alertKey(a.PluginID, a.URL, a.Method, a.Param)
+}
+
+// Hash is a stable identifier of the alert for external trackers, it's the same for the alert in every scan
+func (a Alert) Hash() string {
+	sum := sha256.Sum256([]byte(a.Key()))
+	return hex.EncodeToString(sum[:])
+}
+
+func alertKey(pluginID, url, method, param string) string {
+	return strings.Join([]string{pluginID, url, method, param}, "|")
+}
+
+// Compare diffs the alerts of the current scan with the previous one.
+// Without a previous scan every alert is new.
+func Compare(previous, current *Scan) *Diff {
+	diff := &Diff{
+		New:       []Alert{},
+		Fixed:     []Alert{},
+		Unchanged: []Alert{},
+	}
+	before := map[string]bool{}
+	if previous != nil {
+		diff.PreviousID = previous.ID
+		for _, alert := range previous.Alerts {
+			before[alert.Key()] = true
+		}
+	}
+
+	after := map[string]bool{}
+	for _, alert := range current.Alerts {
+		key := alert.Key()
+		if after[key] {
+			continue
+		}
+		after[key] = true
+		if before[key] {
+			diff.Unchanged = append(diff.Unchanged, alert)
+		} else {
+			diff.New = append(diff.New, alert)
+		}
+	}
+	if previous != nil {
+		fixed := map[string]bool{}
+		for _, alert := range previous.Alerts {
+			key := alert.Key()
+			if !after[key] && !fixed[key] {
+				fixed[key] = true
+				diff.Fixed = append(diff.Fixed, alert)
+			}
+		}
+	}
+	return diff
+}
+
+// NewSummary returns the number of new alerts per risk level
+func (d *Diff) NewSummary() map[string]int {
+	return Summarize(d.New)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+func TestCompare(t *testing.T) {
+	xss := Alert{PluginID: "40012", Name: "XSS", Risk: RiskHigh, URL: "http://app/search", Method: "GET", Param: "q"}
+	sqli := Alert{PluginID: "40018", Name: "SQL Injection", Risk: RiskHigh, URL: "http://app/login", Method: "POST", Param: "user"}
+	header := Alert{PluginID: "10021", Name: "X-Content-Type-Options Header Missing", Risk: "Low", URL: "http://app/"}
+
+	first := Compare(nil, &Scan{ID: "1", Alerts: []Alert{xss, header}})
+	if first.PreviousID != "" || len(first.New) != 2 || len(first.Fixed) != 0 || len(first.Unchanged) != 0 {
+		t.Errorf("unexpected diff of the first scan %+v", first)
+	}
+
+	previous := &Scan{ID: "1", Alerts: []Alert{xss, header, header}}
+	diff := Compare(previous, &Scan{ID: "2", Alerts: []Alert{sqli, header}})
+	if diff.PreviousID != "1" {
+		t.Errorf("unexpected previous scan %s", diff.PreviousID)
+	}
+	if len(diff.New) != 1 || diff.New[0].Key() != sqli.Key() {
+		t.Errorf("unexpected new alerts %v", diff.New)
+	}
+	if len(diff.Fixed) != 1 || diff.Fixed[0].Key() != xss.Key() {
+		t.Errorf("unexpected fixed alerts %v", diff.Fixed)
+	}
+	if len(diff.Unchanged) != 1 || diff.Unchanged[0].Key() != header.Key() {
+		t.Errorf("unexpected unchanged alerts %v", diff.Unchanged)
+	}
+	if diff.NewSummary()[RiskHigh] != 1 {
+		t.Errorf("unexpected new summary %v", diff.NewSummary())
+	}
+}
+
+// TestFetchAlertsOfScan checks that the alerts of a previous scan kept by ZAP aren't reported again,
+// so alerts fixed since the previous scan are reported as fixed
+func TestFetchAlertsOfScan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"alerts":[
+			{"id":"1","pluginId":"40012","alert":"XSS","risk":"High","url":"http://app/search","method":"GET","param":"q"},
+			{"id":"2","pluginId":"10021","alert":"X-Content-Type-Options Header Missing","risk":"Low","url":"http://app/"},
+			{"id":"5","pluginId":"10021","alert":"X-Content-Type-Options Header Missing","risk":"Low","url":"http://app/"}
+		]}`)
+	}))
+	defer server.Close()
+	zapClient, err := zap.NewClient(&zap.Config{Proxy: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, _, err := FetchAlerts(zapClient, "http://app", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("unexpected alerts without watermark %v", all)
+	}
+
+	after := 2
+	alerts, summary, err := FetchAlerts(zapClient, "http://app", &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || summary[RiskHigh] != 0 || summary["Low"] != 1 {
+		t.Fatalf("unexpected alerts of the scan %v", alerts)
+	}
+	diff := Compare(&Scan{ID: "1", Alerts: all[:2]}, &Scan{ID: "2", Alerts: alerts})
+	if len(diff.Fixed) != 1 || diff.Fixed[0].PluginID != "40012" {
+		t.Errorf("unexpected fixed alerts %v", diff.Fixed)
+	}
+}
+
+// TestFetchAlertsOfPort checks that the alerts of other ports of the host, which match the target as URL prefix, are skipped
+func TestFetchAlertsOfPort(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"alerts":[
+			{"id":"1","pluginId":"40012","alert":"XSS","risk":"High","url":"http://app.default.svc.cluster.local:80/search"},
+			{"id":"2","pluginId":"40012","alert":"XSS","risk":"High","url":"http://app.default.svc.cluster.local:8080/search"},
+			{"id":"3","pluginId":"10021","alert":"X-Content-Type-Options Header Missing","risk":"Low","url":"http://app.default.svc.cluster.local/"}
+		]}`)
+	}))
+	defer server.Close()
+	zapClient, err := zap.NewClient(&zap.Config{Proxy: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alerts, _, err := FetchAlerts(zapClient, "http://app.default.svc.cluster.local:80", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].URL != "http://app.default.svc.cluster.local:80/search" || alerts[1].PluginID != "10021" {
+		t.Errorf("unexpected alerts of the port %v", alerts)
+	}
+}
+
+// TestAlertsFromLog checks that the alerts written by an analyzer with a ZaProxy sidecar are read from the last alerts line of its log
+func TestAlertsFromLog(t *testing.T) {
+	if _, _, err := AlertsFromLog([]byte("scan started\n"), "http://app"); err == nil {
+		t.Error("log without alerts is accepted")
+	}
+
+	log := "scan started\n" +
+		alertsLine(t, `[{"id":"1","pluginId":"10021","alert":"X-Content-Type-Options Header Missing","risk":"Low","url":"http://app/"}]`) +
+		"retrying\n" +
+		alertsLine(t, `[
+			{"id":"1","pluginId":"40012","alert":"XSS","risk":"High","url":"http://app/search","method":"GET","param":"q"},
+			{"id":"2","pluginId":"40012","alert":"XSS","risk":"High","url":"http://other/search"}
+		]`) +
+		"summary: map[High:1]"
+	alerts, summary, err := AlertsFromLog([]byte(log), "http://app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Name != "XSS" || alerts[0].Param != "q" || summary[RiskHigh] != 1 {
+		t.Errorf("unexpected alerts of the log %v %v", alerts, summary)
+	}
+}
+
+// alertsLine encodes the alerts the way the analyzer writes them to its log
+func alertsLine(t *testing.T, alerts string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(alerts)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return "\n" + AlertsLogPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()) + "\n"
+}
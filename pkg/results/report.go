@@ -0,0 +1,246 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"emperror.dev/emperror"
+)
+
+var riskCodes = map[string]string{
+	"Informational": "0",
+	"Low":           "1",
+	"Medium":        "2",
+	"High":          "3",
+}
+
+var confidenceCodes = map[string]string{
+	"False Positive": "0",
+	"Low":            "1",
+	"Medium":         "2",
+	"High":           "3",
+	"Confirmed":      "4",
+}
+
+// reportSite holds the alerts of a site in the layout of the ZAP reports
+type reportSite struct {
+	Name   string        `json:"@name" xml:"name,attr"`
+	Host   string        `json:"@host" xml:"host,attr"`
+	Port   string        `json:"@port" xml:"port,attr"`
+	SSL    string        `json:"@ssl" xml:"ssl,attr"`
+	Alerts []reportAlert `json:"alerts" xml:"alerts>alertitem"`
+}
+
+// reportAlert is the alert of a plugin with its instances in the layout of the ZAP reports
+type reportAlert struct {
+	PluginID   string           `json:"pluginid" xml:"pluginid"`
+	Alert      string           `json:"alert" xml:"alert"`
+	Name       string           `json:"name" xml:"name"`
+	RiskCode   string           `json:"riskcode" xml:"riskcode"`
+	Confidence string           `json:"confidence" xml:"confidence"`
+	RiskDesc   string           `json:"riskdesc" xml:"riskdesc"`
+	Desc       string           `json:"desc" xml:"desc"`
+	Instances  []reportInstance `json:"instances" xml:"instances>instance"`
+	Count      string           `json:"count" xml:"count"`
+	Solution   string           `json:"solution" xml:"solution"`
+	CWEID      string           `json:"cweid" xml:"cweid"`
+}
+
+type reportInstance struct {
+	URI      string `json:"uri" xml:"uri"`
+	Method   string `json:"method" xml:"method"`
+	Param    string `json:"param" xml:"param"`
+	Evidence string `json:"evidence" xml:"evidence"`
+}
+
+// report is the content of the reports of a scan
+type report struct {
+	XMLName   xml.Name     `json:"-" xml:"OWASPZAPReport"`
+	Version   string       `json:"@version" xml:"version,attr"`
+	Generated string       `json:"@generated" xml:"generated,attr"`
+	Sites     []reportSite `json:"site" xml:"site"`
+	scan      *Scan
+}
+
+// Reports renders the reports of the scan in every supported format.
+// They contain only the alerts of the scan, the JSON and XML reports follow the layout of the ZAP reports.
+func Reports(scan *Scan) (map[string][]byte, error) {
+	r := newReport(scan)
+	reports := map[string][]byte{}
+	var err error
+	if reports[ReportJSON], err = json.MarshalIndent(r, "", "  "); err != nil {
+		return nil, emperror.Wrap(err, "failed to render json report")
+	}
+	content, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, emperror.Wrap(err, "failed to render xml report")
+	}
+	reports[ReportXML] = append([]byte(xml.Header), content...)
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, r); err != nil {
+		return nil, emperror.Wrap(err, "failed to render html report")
+	}
+	reports[ReportHTML] = buf.Bytes()
+	reports[ReportMarkdown] = r.markdown()
+	return reports, nil
+}
+
+func newReport(scan *Scan) *report {
+	generated := time.Now()
+	if scan.CompletionTime != nil {
+		generated = scan.CompletionTime.Time
+	}
+	r := &report{
+		Version:   "dast-operator",
+		Generated: generated.UTC().Format(time.RFC1123),
+		scan:      scan,
+	}
+
+	sites := map[string]*reportSite{}
+	alerts := map[string]*reportAlert{}
+	alertSites := map[string]string{}
+	for _, alert := range scan.Alerts {
+		site := siteOf(alert.URL)
+		if sites[site.Name] == nil {
+			sites[site.Name] = &site
+		}
+		key := site.Name + "|" + alert.PluginID + "|" + alert.Name
+		item := alerts[key]
+		if item == nil {
+			item = &reportAlert{
+				PluginID:   alert.PluginID,
+				Alert:      alert.Name,
+				Name:       alert.Name,
+				RiskCode:   riskCodes[alert.Risk],
+				Confidence: confidenceCodes[alert.Confidence],
+				RiskDesc:   fmt.Sprintf("%s (%s)", alert.Risk, alert.Confidence),
+				Desc:       alert.Description,
+				Solution:   alert.Solution,
+				CWEID:      alert.CWEID,
+			}
+			alerts[key] = item
+			alertSites[key] = site.Name
+		}
+		item.Instances = append(item.Instances, reportInstance{
+			URI:      alert.URL,
+			Method:   alert.Method,
+			Param:    alert.Param,
+			Evidence: alert.Evidence,
+		})
+	}
+	for key, item := range alerts {
+		item.Count = strconv.Itoa(len(item.Instances))
+		site := sites[alertSites[key]]
+		site.Alerts = append(site.Alerts, *item)
+	}
+	for _, site := range sites {
+		sort.Slice(site.Alerts, func(i, j int) bool {
+			if site.Alerts[i].RiskCode != site.Alerts[j].RiskCode {
+				return site.Alerts[i].RiskCode > site.Alerts[j].RiskCode
+			}
+			return site.Alerts[i].PluginID < site.Alerts[j].PluginID
+		})
+		r.Sites = append(r.Sites, *site)
+	}
+	sort.Slice(r.Sites, func(i, j int) bool {
+		return r.Sites[i].Name < r.Sites[j].Name
+	})
+	return r
+}
+
+// siteOf returns the site of the URL as scheme://host:port
+func siteOf(rawURL string) reportSite {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return reportSite{Name: rawURL}
+	}
+	ssl := u.Scheme == "https"
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if ssl {
+			port = "443"
+		}
+	}
+	return reportSite{
+		Name: u.Scheme + "://" + net.JoinHostPort(u.Hostname(), port),
+		Host: u.Hostname(),
+		Port: port,
+		SSL:  strconv.FormatBool(ssl),
+	}
+}
+
+func (r *report) markdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Scan report of %s/%s\n\n", r.scan.Namespace, r.scan.Service)
+	fmt.Fprintf(&buf, "Target: %s, scan: %s, generated: %s\n\n", r.scan.Target, r.scan.ID, r.Generated)
+	buf.WriteString("| Risk level | Number of alerts |\n| --- | --- |\n")
+	summary := Summarize(r.scan.Alerts)
+	for _, risk := range []string{"High", "Medium", "Low", "Informational"} {
+		fmt.Fprintf(&buf, "| %s | %d |\n", risk, summary[risk])
+	}
+	for _, site := range r.Sites {
+		fmt.Fprintf(&buf, "\n## %s\n", site.Name)
+		for _, alert := range site.Alerts {
+			fmt.Fprintf(&buf, "\n### %s\n\n%s\n\nSolution: %s\n\n", alert.Alert, alert.RiskDesc, alert.Solution)
+			for _, instance := range alert.Instances {
+				fmt.Fprintf(&buf, "- %s %s %s\n", instance.Method, instance.URI, instance.Param)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Scan report of {{ .Scan.Namespace }}/{{ .Scan.Service }}</title></head>
+<body>
+<h1>Scan report of {{ .Scan.Namespace }}/{{ .Scan.Service }}</h1>
+<p>Target: {{ .Scan.Target }}, scan: {{ .Scan.ID }}, generated: {{ .Generated }}</p>
+{{- range .Sites }}
+<h2>{{ .Name }}</h2>
+{{- range .Alerts }}
+<h3>{{ .Alert }}</h3>
+<table>
+<tr><th>Risk</th><td>{{ .RiskDesc }}</td></tr>
+<tr><th>Description</th><td>{{ .Desc }}</td></tr>
+<tr><th>Solution</th><td>{{ .Solution }}</td></tr>
+<tr><th>CWE</th><td>{{ .CWEID }}</td></tr>
+{{- range .Instances }}
+<tr><th>Instance</th><td>{{ .Method }} {{ .URI }} {{ .Param }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+// Scan returns the reported scan, it's used by the HTML template
+func (r *report) Scan() *Scan {
+	return r.scan
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestReports(t *testing.T) {
+	scan := &Scan{
+		ID:        "1",
+		Namespace: "default",
+		Service:   "app",
+		Target:    "http://app.default.svc:80",
+		Alerts: []Alert{
+			{PluginID: "40012", Name: "Cross Site Scripting", Risk: RiskHigh, Confidence: "Medium", URL: "http://app.default.svc/search", Method: "GET", Param: "q"},
+			{PluginID: "40012", Name: "Cross Site Scripting", Risk: RiskHigh, Confidence: "Medium", URL: "http://app.default.svc/login", Method: "POST", Param: "user"},
+			{PluginID: "10021", Name: "X-Content-Type-Options Header Missing", Risk: "Low", Confidence: "Medium", URL: "http://app.default.svc/"},
+		},
+	}
+	reports, err := Reports(scan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{ReportHTML, ReportXML, ReportJSON, ReportMarkdown} {
+		if len(reports[format]) == 0 {
+			t.Errorf("%s report is missing", format)
+		}
+	}
+
+	var parsed struct {
+		Sites []struct {
+			Name   string `xml:"name,attr"`
+			Port   string `xml:"port,attr"`
+			Alerts []struct {
+				PluginID  string `xml:"pluginid"`
+				RiskCode  string `xml:"riskcode"`
+				Count     string `xml:"count"`
+				Instances []struct {
+					URI string `xml:"uri"`
+				} `xml:"instances>instance"`
+			} `xml:"alerts>alertitem"`
+		} `xml:"site"`
+	}
+	if err := xml.Unmarshal(reports[ReportXML], &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Sites) != 1 || parsed.Sites[0].Name != "http://app.default.svc:80" || parsed.Sites[0].Port != "80" {
+		t.Fatalf("unexpected sites %+v", parsed.Sites)
+	}
+	alerts := parsed.Sites[0].Alerts
+	if len(alerts) != 2 || alerts[0].PluginID != "40012" || alerts[0].RiskCode != "3" || alerts[0].Count != "2" || len(alerts[0].Instances) != 2 {
+		t.Errorf("unexpected alerts %+v", alerts)
+	}
+
+	var report struct {
+		Sites []map[string]interface{} `json:"site"`
+	}
+	if err := json.Unmarshal(reports[ReportJSON], &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Sites) != 1 || report.Sites[0]["@name"] != "http://app.default.svc:80" {
+		t.Errorf("unexpected json report %s", reports[ReportJSON])
+	}
+	if !strings.Contains(string(reports[ReportHTML]), "Cross Site Scripting") || !strings.Contains(string(reports[ReportMarkdown]), "| High | 2 |") {
+		t.Error("alerts are missing from the html or markdown report")
+	}
+}
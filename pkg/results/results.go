@@ -0,0 +1,250 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"emperror.dev/emperror"
+	"emperror.dev/errors"
+	"github.com/zaproxy/zap-api-go/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AlertsLogPrefix marks the line of the analyzer log with the alerts of a scan run by a ZaProxy sidecar
+const AlertsLogPrefix = "dast-alerts: "
+
+// Report formats downloadable from the results API
+const (
+	ReportHTML     = "html"
+	ReportXML      = "xml"
+	ReportJSON     = "json"
+	ReportMarkdown = "md"
+)
+
+// Scan is the stored result of a finished scan of a service
+type Scan struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	// Port is the scanned port of the service, the ports are scanned separately by target selectors.
+	// It's zero for ingress hosts and for the scans stored before the ports were recorded.
+	Port           int32          `json:"port,omitempty"`
+	Target         string         `json:"target"`
+	Job            string         `json:"job"`
+	StartTime      *metav1.Time   `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time   `json:"completionTime,omitempty"`
+	Passed         bool           `json:"passed"`
+	Reason         string         `json:"reason,omitempty"`
+	Message        string         `json:"message,omitempty"`
+	Summary        map[string]int `json:"summary"`
+	// NewHigh is the number of high alerts not reported by the previous scan
+	NewHigh int     `json:"newHigh"`
+	Alerts  []Alert `json:"alerts,omitempty"`
+	Diff    *Diff   `json:"diff,omitempty"`
+	// Ingress is the name of the ingress, whose host was scanned through the ingress controller
+	Ingress string `json:"ingress,omitempty"`
+	// Host is the scanned host of the ingress
+	Host string `json:"host,omitempty"`
+	// Backends are the backend services of the scanned host of the ingress
+	Backends []string `json:"backends,omitempty"`
+	// OmittedReports are the formats of the reports, which weren't stored because of the size limit of the store
+	OmittedReports []string `json:"omittedReports,omitempty"`
+}
+
+// IngressScanKey is the name the scans of an ingress host are stored under instead of the service name.
+// It contains a dot, so it can't collide with service names.
+func IngressScanKey(ingress, host string) string {
+	sum := sha256.Sum256([]byte(ingress + "/" + host))
+	return "ingress." + hex.EncodeToString(sum[:8])
+}
+
+// Alert is a single finding of ZAP
+type Alert struct {
+	PluginID    string `json:"pluginId"`
+	Name        string `json:"name"`
+	Risk        string `json:"risk"`
+	Confidence  string `json:"confidence,omitempty"`
+	URL         string `json:"url"`
+	Method      string `json:"method,omitempty"`
+	Param       string `json:"param,omitempty"`
+	Evidence    string `json:"evidence,omitempty"`
+	Description string `json:"description,omitempty"`
+	Solution    string `json:"solution,omitempty"`
+	CWEID       string `json:"cweid,omitempty"`
+}
+
+// FetchAlerts returns the alerts of the target and the summary of them per risk level.
+// If after is set, only the alerts with higher ids are returned, they are the alerts of the latest scan.
+// ZAP matches the alerts by URL prefix, so the alerts of other ports of the host are skipped.
+func FetchAlerts(zapClient zap.Interface, target string, after *int) ([]Alert, map[string]int, error) {
+	resp, err := zapClient.Core().Alerts(target, "", "", "")
+	if err != nil {
+		return nil, nil, emperror.Wrap(err, "failed to get alerts from ZaProxy")
+	}
+	items, ok := resp["alerts"].([]interface{})
+	if !ok {
+		return nil, nil, emperror.With(fmt.Errorf("unexpected alerts response"), "response", resp)
+	}
+	return parseAlerts(items, target, after)
+}
+
+// AlertsFromLog returns the alerts of the target and their number per risk level written to the log of an analyzer with a ZaProxy sidecar.
+// The alerts are on the last line starting with AlertsLogPrefix, as base64 encoded gzipped JSON.
+func AlertsFromLog(log []byte, target string) ([]Alert, map[string]int, error) {
+	var encoded []byte
+	for _, line := range bytes.Split(log, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte(AlertsLogPrefix)) {
+			encoded = bytes.TrimPrefix(line, []byte(AlertsLogPrefix))
+		}
+	}
+	if encoded == nil {
+		return nil, nil, errors.New("no alerts in the analyzer log")
+	}
+	r, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(encoded)))
+	if err != nil {
+		return nil, nil, emperror.Wrap(err, "failed to decode alerts of the analyzer log")
+	}
+	var items []interface{}
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, nil, emperror.Wrap(err, "failed to decode alerts of the analyzer log")
+	}
+	return parseAlerts(items, target, nil)
+}
+
+// parseAlerts converts the alerts of the ZaProxy API raised for the site of the target, after the given alert id if set
+func parseAlerts(items []interface{}, target string, after *int) ([]Alert, map[string]int, error) {
+	site := siteOf(target).Name
+	alerts := make([]Alert, 0, len(items))
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if after != nil {
+			id, err := strconv.Atoi(stringField(fields, "id"))
+			if err != nil {
+				return nil, nil, emperror.With(fmt.Errorf("unexpected alert id"), "alert", fields)
+			}
+			if id <= *after {
+				continue
+			}
+		}
+		if siteOf(stringField(fields, "url")).Name != site {
+			continue
+		}
+		alert := Alert{
+			PluginID:    stringField(fields, "pluginId"),
+			Name:        stringField(fields, "alert"),
+			Risk:        stringField(fields, "risk"),
+			Confidence:  stringField(fields, "confidence"),
+			URL:         stringField(fields, "url"),
+			Method:      stringField(fields, "method"),
+			Param:       stringField(fields, "param"),
+			Evidence:    stringField(fields, "evidence"),
+			Description: stringField(fields, "description"),
+			Solution:    stringField(fields, "solution"),
+			CWEID:       stringField(fields, "cweid"),
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, Summarize(alerts), nil
+}
+
+// Store persists scan results
+type Store interface {
+	// Save stores the scan with its reports
+	Save(ctx context.Context, scan *Scan, reports map[string][]byte) error
+	// List returns the scans of a service without alerts, ordered by completion time, the latest first
+	List(ctx context.Context, namespace, service string) ([]Scan, error)
+	// Get returns a scan with its alerts
+	Get(ctx context.Context, namespace, service, id string) (*Scan, error)
+	// Report returns a report of a scan in the given format
+	Report(ctx context.Context, namespace, service, id, format string) ([]byte, error)
+}
+
+// LatestOfPort returns the latest scan of the port of the service with its alerts, nil if the port has no stored scans
+func LatestOfPort(ctx context.Context, store Store, namespace, service string, port int32) (*Scan, error) {
+	scans, err := store.List(ctx, namespace, service)
+	if err != nil {
+		return nil, err
+	}
+	for _, scan := range scans {
+		if scan.Port == port {
+			return store.Get(ctx, namespace, service, scan.ID)
+		}
+	}
+	return nil, nil
+}
+
+// LatestPerPort returns the latest scan of every scanned port of the service with its alerts ordered by port, empty if the service has no stored scans.
+// The scans stored without port are superseded by the scans of any port.
+func LatestPerPort(ctx context.Context, store Store, namespace, service string) ([]*Scan, error) {
+	scans, err := store.List(ctx, namespace, service)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int32]bool{}
+	var latest []*Scan
+	for _, scan := range scans {
+		if seen[scan.Port] {
+			continue
+		}
+		seen[scan.Port] = true
+		stored, err := store.Get(ctx, namespace, service, scan.ID)
+		if err != nil {
+			return nil, err
+		}
+		latest = append(latest, stored)
+	}
+	if len(latest) > 1 && seen[0] {
+		ported := latest[:0]
+		for _, scan := range latest {
+			if scan.Port != 0 {
+				ported = append(ported, scan)
+			}
+		}
+		latest = ported
+	}
+	sort.Slice(latest, func(i, j int) bool {
+		return latest[i].Port < latest[j].Port
+	})
+	return latest, nil
+}
+
+func stringField(fields map[string]interface{}, key string) string {
+	switch value := fields[key].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+
+	"emperror.dev/emperror"
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SignatureAnnotation holds the HMAC of the stored scan, which proves that the scan was stored by the operator
+	SignatureAnnotation = "dast.security.banzaicloud.io/result-signature"
+	// SigningKeySecret is the name of the Secret in the namespace of the operator, which holds the key of the signatures
+	SigningKeySecret = "dast-results-signing-key"
+
+	signingKey = "key"
+)
+
+// signature returns the HMAC of the namespace, the name, the service label and the data of the ConfigMap
+func signature(key []byte, configMap *corev1.ConfigMap) string {
+	mac := hmac.New(sha256.New, key)
+	write := func(value []byte) {
+		// the values are length prefixed, so they can't be shifted into each other
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+		mac.Write(length[:])
+		mac.Write(value)
+	}
+	write([]byte(configMap.GetNamespace()))
+	write([]byte(configMap.GetName()))
+	write([]byte(configMap.GetLabels()[ServiceLabel]))
+	write([]byte(configMap.Data[scanKey]))
+	keys := make([]string, 0, len(configMap.BinaryData))
+	for key := range configMap.BinaryData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		write([]byte(key))
+		write(configMap.BinaryData[key])
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sign sets the signature annotation of the ConfigMap
+func sign(key []byte, configMap *corev1.ConfigMap) {
+	if configMap.Annotations == nil {
+		configMap.Annotations = map[string]string{}
+	}
+	configMap.Annotations[SignatureAnnotation] = signature(key, configMap)
+}
+
+// verified reports whether the ConfigMap was signed with the key
+func verified(key []byte, configMap *corev1.ConfigMap) bool {
+	expected, err := hex.DecodeString(signature(key, configMap))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(configMap.GetAnnotations()[SignatureAnnotation])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, actual)
+}
+
+// LoadSigningKey returns the key of the scan signatures from the Secret in the namespace of the operator.
+// The Secret is created with a random key if it doesn't exist.
+func LoadSigningKey(ctx context.Context, c client.Client, reader client.Reader, namespace string) ([]byte, error) {
+	var secret corev1.Secret
+	err := reader.Get(ctx, types.NamespacedName{Name: SigningKeySecret, Namespace: namespace}, &secret)
+	if err == nil {
+		if key := secret.Data[signingKey]; len(key) > 0 {
+			return key, nil
+		}
+		return nil, errors.NewWithDetails("signing key secret has no key", "namespace", namespace)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, emperror.WrapWith(err, "failed to get signing key", "namespace", namespace)
+	}
+
+	key, err := NewSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	secret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SigningKeySecret,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{signingKey: key},
+	}
+	if err := c.Create(ctx, &secret); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// created by another replica of the operator
+			return LoadSigningKey(ctx, c, reader, namespace)
+		}
+		return nil, emperror.WrapWith(err, "failed to create signing key", "namespace", namespace)
+	}
+	return key, nil
+}
+
+// NewSigningKey returns a random key of the scan signatures
+func NewSigningKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, emperror.Wrap(err, "failed to generate signing key")
+	}
+	return key, nil
+}
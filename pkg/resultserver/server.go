@@ -0,0 +1,232 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resultserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+var reportContentTypes = map[string]string{
+	results.ReportHTML:     "text/html",
+	results.ReportXML:      "application/xml",
+	results.ReportJSON:     "application/json",
+	results.ReportMarkdown: "text/markdown",
+}
+
+// Server serves the stored scan results.
+// Callers authenticate with a bearer token, which is checked by a TokenReview,
+// and need the permission to get the scanned service.
+type Server struct {
+	Addr    string
+	CertDir string
+	Client  client.Client
+	Store   results.Store
+	Log     logr.Logger
+}
+
+// Start runs the server until stop is closed, it implements manager.Runnable.
+// The bearer tokens of the callers are only accepted over TLS.
+func (s *Server) Start(stop <-chan struct{}) error {
+	if s.CertDir == "" {
+		return errors.New("the results API requires a certificate directory")
+	}
+	srv := &http.Server{
+		Addr:    s.Addr,
+		Handler: s,
+	}
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			s.Log.Error(err, "failed to shut down results server")
+		}
+	}()
+
+	s.Log.Info("starting results server", "addr", s.Addr)
+	err := srv.ListenAndServeTLS(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// ServeHTTP routes
+//
+//	GET /namespaces/{ns}/services/{svc}/scans
+//	GET /namespaces/{ns}/services/{svc}/scans/{id}
+//	GET /namespaces/{ns}/services/{svc}/scans/{id}/alerts?risk={risk}
+//	GET /namespaces/{ns}/services/{svc}/scans/{id}/diff
+//	GET /namespaces/{ns}/services/{svc}/scans/{id}/reports/{format}
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 5 || parts[0] != "namespaces" || parts[2] != "services" || parts[4] != "scans" {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, service := parts[1], parts[3]
+
+	if status, err := s.authorize(r, namespace, service); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	ctx := r.Context()
+	switch {
+	case len(parts) == 5:
+		scans, err := s.Store.List(ctx, namespace, service)
+		s.respond(w, scans, err)
+	case len(parts) == 6:
+		scan, err := s.Store.Get(ctx, namespace, service, parts[5])
+		s.respond(w, scan, err)
+	case len(parts) == 7 && parts[6] == "alerts":
+		scan, err := s.Store.Get(ctx, namespace, service, parts[5])
+		if err != nil {
+			s.respond(w, nil, err)
+			return
+		}
+		s.respond(w, filterAlerts(scan.Alerts, r.URL.Query().Get("risk")), nil)
+	case len(parts) == 7 && parts[6] == "diff":
+		scan, err := s.Store.Get(ctx, namespace, service, parts[5])
+		if err != nil {
+			s.respond(w, nil, err)
+			return
+		}
+		if scan.Diff == nil {
+			http.Error(w, "scan has no diff", http.StatusNotFound)
+			return
+		}
+		s.respond(w, scan.Diff, nil)
+	case len(parts) == 8 && parts[6] == "reports":
+		contentType, ok := reportContentTypes[parts[7]]
+		if !ok {
+			http.Error(w, "unsupported report format", http.StatusBadRequest)
+			return
+		}
+		report, err := s.Store.Report(ctx, namespace, service, parts[5], parts[7])
+		if err != nil {
+			s.respond(w, nil, err)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(report)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorize checks the bearer token with a TokenReview and the permission to get the service with a SubjectAccessReview
+func (s *Server) authorize(r *http.Request, namespace, service string) (int, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return http.StatusUnauthorized, apierrors.NewUnauthorized("bearer token required")
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}
+	if err := s.Client.Create(r.Context(), review); err != nil {
+		s.Log.Error(err, "token review failed")
+		return http.StatusInternalServerError, err
+	}
+	if !review.Status.Authenticated {
+		return http.StatusUnauthorized, apierrors.NewUnauthorized("invalid token")
+	}
+
+	user := review.Status.User
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	access := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Resource:  "services",
+				Name:      service,
+			},
+		},
+	}
+	if err := s.Client.Create(r.Context(), access); err != nil {
+		s.Log.Error(err, "subject access review failed")
+		return http.StatusInternalServerError, err
+	}
+	if !access.Status.Allowed {
+		return http.StatusForbidden, apierrors.NewForbidden(corev1.Resource("services"), service, fmt.Errorf("user %q cannot get the service", user.Username))
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Server) respond(w http.ResponseWriter, body interface{}, err error) {
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.Log.Error(err, "failed to get scan results")
+		http.Error(w, "failed to get scan results", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.Log.Error(err, "failed to write response")
+	}
+}
+
+// filterAlerts returns the alerts with the given risk level, all of them if risk is empty
+func filterAlerts(alerts []results.Alert, risk string) []results.Alert {
+	if risk == "" {
+		return alerts
+	}
+	filtered := []results.Alert{}
+	for _, alert := range alerts {
+		if strings.EqualFold(alert.Risk, risk) {
+			filtered = append(filtered, alert)
+		}
+	}
+	return filtered
+}
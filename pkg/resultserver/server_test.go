@@ -0,0 +1,186 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resultserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+// reviewClient authenticates the tokens of the users and allows the developer to get the app service
+type reviewClient struct {
+	client.Client
+}
+
+func (c reviewClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	switch review := obj.(type) {
+	case *authenticationv1.TokenReview:
+		switch review.Spec.Token {
+		case "developer-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "developer"}}
+		case "guest-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "guest"}}
+		}
+		return nil
+	case *authorizationv1.SubjectAccessReview:
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "developer" && attributes.Verb == "get" && attributes.Resource == "services" &&
+			attributes.Namespace == "default" && attributes.Name == "app"
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme)
+	store := results.NewConfigMapStore(c, c, 10)
+	if err := store.Save(context.Background(), &results.Scan{
+		ID:             "1",
+		Namespace:      "default",
+		Service:        "app",
+		CompletionTime: &metav1.Time{Time: time.Now()},
+		Alerts: []results.Alert{
+			{PluginID: "1", Name: "XSS", Risk: results.RiskHigh, URL: "http://app"},
+			{PluginID: "2", Name: "Cookie", Risk: "Low", URL: "http://app"},
+		},
+	}, map[string][]byte{results.ReportXML: []byte("<report/>")}); err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(&Server{Client: reviewClient{c}, Store: store, Log: zap.New()})
+}
+
+func get(t *testing.T, server *httptest.Server, path, token string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAuthorize(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{name: "missing token", path: "/namespaces/default/services/app/scans", status: http.StatusUnauthorized},
+		{name: "invalid token", path: "/namespaces/default/services/app/scans", token: "invalid", status: http.StatusUnauthorized},
+		{name: "denied", path: "/namespaces/default/services/app/scans", token: "guest-token", status: http.StatusForbidden},
+		{name: "denied service", path: "/namespaces/default/services/other/scans", token: "developer-token", status: http.StatusForbidden},
+		{name: "allowed", path: "/namespaces/default/services/app/scans", token: "developer-token", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := get(t, server, test.path, test.token)
+			defer resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Errorf("expected status %d, got %d", test.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+	}{
+		{name: "scans", path: "/namespaces/default/services/app/scans", status: http.StatusOK, contentType: "application/json"},
+		{name: "scan", path: "/namespaces/default/services/app/scans/1", status: http.StatusOK, contentType: "application/json"},
+		{name: "unknown scan", path: "/namespaces/default/services/app/scans/2", status: http.StatusNotFound},
+		{name: "alerts", path: "/namespaces/default/services/app/scans/1/alerts?risk=high", status: http.StatusOK, contentType: "application/json"},
+		{name: "alerts of unknown scan", path: "/namespaces/default/services/app/scans/2/alerts", status: http.StatusNotFound},
+		{name: "scan without diff", path: "/namespaces/default/services/app/scans/1/diff", status: http.StatusNotFound},
+		{name: "report", path: "/namespaces/default/services/app/scans/1/reports/xml", status: http.StatusOK, contentType: "application/xml"},
+		{name: "unknown format", path: "/namespaces/default/services/app/scans/1/reports/pdf", status: http.StatusBadRequest},
+		{name: "missing report", path: "/namespaces/default/services/app/scans/1/reports/html", status: http.StatusNotFound},
+		{name: "report of unknown scan", path: "/namespaces/default/services/app/scans/2/reports/xml", status: http.StatusNotFound},
+		{name: "unknown route", path: "/namespaces/default/services/app/scans/1/unknown", status: http.StatusNotFound},
+		{name: "unknown resource", path: "/namespaces/default/pods/app/scans", status: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := get(t, server, test.path, "developer-token")
+			defer resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Errorf("expected status %d, got %d", test.status, resp.StatusCode)
+			}
+			if test.contentType != "" && resp.Header.Get("Content-Type") != test.contentType {
+				t.Errorf("expected content type %s, got %s", test.contentType, resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+
+	resp := get(t, server, "/namespaces/default/services/app/scans/1/alerts?risk=high", "developer-token")
+	defer resp.Body.Close()
+	var alerts []results.Alert
+	if err := json.NewDecoder(resp.Body).Decode(&alerts); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Name != "XSS" {
+		t.Errorf("unexpected high alerts %v", alerts)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	resp, err := server.Client().Post(server.URL+"/namespaces/default/services/app/scans", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
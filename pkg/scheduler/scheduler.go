@@ -0,0 +1,256 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"emperror.dev/emperror"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+)
+
+const (
+	// RetryInterval is the period of repeating the requests of queued jobs
+	RetryInterval = 15 * time.Second
+	// staleAfter is the time after queued requests, which aren't repeated, are dropped.
+	// Admitted jobs, which aren't listed yet, are counted as running for the same time.
+	staleAfter = 4 * RetryInterval
+)
+
+// Request is an analyzer job waiting to be created
+type Request struct {
+	// Job is the name and namespace of the analyzer job
+	Job types.NamespacedName
+	// ZaProxy is the name of the ZAP instance used by the job in the namespace of the job
+	ZaProxy string
+	// Namespace is the namespace of the scanned object, the queue is fair between namespaces
+	Namespace string
+	// Priority of the job, jobs with higher priority are admitted first
+	Priority int
+}
+
+// Scheduler limits the number of analyzer jobs running at once in the cluster and per ZAP instance.
+// The running jobs are listed from the cluster, so the limits hold across operator restarts.
+// Waiting jobs are queued by priority and fairly between the namespaces of the scanned objects.
+type Scheduler struct {
+	client        client.Reader
+	maxConcurrent int
+	maxPerZaProxy int
+	now           func() time.Time
+
+	mu       sync.Mutex
+	queue    map[types.NamespacedName]*entry
+	admitted map[types.NamespacedName]*entry
+}
+
+type entry struct {
+	Request
+	enqueued time.Time
+	seen     time.Time
+}
+
+// usage is the number of running jobs
+type usage struct {
+	total      int
+	zaProxies  map[types.NamespacedName]int
+	namespaces map[string]int
+}
+
+// New creates a scheduler, 0 means no limit
+func New(c client.Reader, maxConcurrent, maxPerZaProxy int) *Scheduler {
+	return &Scheduler{
+		client:        c,
+		maxConcurrent: maxConcurrent,
+		maxPerZaProxy: maxPerZaProxy,
+		now:           time.Now,
+		queue:         map[types.NamespacedName]*entry{},
+		admitted:      map[types.NamespacedName]*entry{},
+	}
+}
+
+// Admit reports whether the job can be created now, otherwise the job is queued and its position in the queue is returned.
+// The request of a queued job has to be repeated until it's admitted, requests, which aren't repeated, are dropped from the queue.
+// Every job is admitted by a nil scheduler.
+func (s *Scheduler) Admit(ctx context.Context, req Request) (bool, int, error) {
+	if s == nil {
+		return true, 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if e, ok := s.admitted[req.Job]; ok && now.Sub(e.seen) <= staleAfter {
+		// the created job isn't listed yet
+		return true, 0, nil
+	}
+	e, ok := s.queue[req.Job]
+	if !ok {
+		e = &entry{enqueued: now}
+		s.queue[req.Job] = e
+	}
+	e.Request = req
+	e.seen = now
+	for key, e := range s.queue {
+		if now.Sub(e.seen) > staleAfter {
+			delete(s.queue, key)
+		}
+	}
+
+	running, err := s.running(ctx, now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	// the entries ahead of the job reserve the free slots until their requests are repeated
+	for i, e := range s.ordered(running) {
+		if !s.fits(e, running) {
+			if e.Job == req.Job {
+				return false, i + 1, nil
+			}
+			continue
+		}
+		if e.Job == req.Job {
+			delete(s.queue, req.Job)
+			s.admitted[req.Job] = e
+			return true, 0, nil
+		}
+		running.add(e)
+	}
+	return false, len(s.queue), nil
+}
+
+// running counts the active analyzer jobs and the admitted jobs, which aren't listed yet
+func (s *Scheduler) running(ctx context.Context, now time.Time) (*usage, error) {
+	var jobs batchv1.JobList
+	if err := s.client.List(ctx, &jobs, client.MatchingLabels(analyzer.JobLabels())); err != nil {
+		return nil, emperror.Wrap(err, "failed to list analyzer jobs")
+	}
+	running := &usage{
+		zaProxies:  map[types.NamespacedName]int{},
+		namespaces: map[string]int{},
+	}
+	listed := map[types.NamespacedName]bool{}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		key := types.NamespacedName{Name: job.GetName(), Namespace: job.GetNamespace()}
+		listed[key] = true
+		if !active(job) {
+			continue
+		}
+		running.add(&entry{Request: Request{
+			Job:       key,
+			ZaProxy:   job.GetLabels()[analyzer.ZaProxyLabel],
+			Namespace: subjectNamespace(job),
+		}})
+	}
+	for key, e := range s.admitted {
+		if listed[key] || now.Sub(e.seen) > staleAfter {
+			delete(s.admitted, key)
+			continue
+		}
+		running.add(e)
+	}
+	return running, nil
+}
+
+// ordered returns the queued entries by descending priority, then round-robin between the namespaces
+// taking the running jobs of the namespaces into account, then in the order of arrival
+func (s *Scheduler) ordered(running *usage) []*entry {
+	entries := make([]*entry, 0, len(s.queue))
+	for _, e := range s.queue {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].enqueued.Equal(entries[j].enqueued) {
+			return entries[i].enqueued.Before(entries[j].enqueued)
+		}
+		return entries[i].Job.String() < entries[j].Job.String()
+	})
+
+	type group struct {
+		priority  int
+		namespace string
+	}
+	ranks := map[group]int{}
+	turns := make(map[*entry]int, len(entries))
+	for _, e := range entries {
+		g := group{priority: e.Priority, namespace: e.Namespace}
+		turns[e] = running.namespaces[e.Namespace] + ranks[g]
+		ranks[g]++
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority > entries[j].Priority
+		}
+		return turns[entries[i]] < turns[entries[j]]
+	})
+	return entries
+}
+
+// fits reports whether the entry can run within the limits
+func (s *Scheduler) fits(e *entry, running *usage) bool {
+	if s.maxConcurrent > 0 && running.total >= s.maxConcurrent {
+		return false
+	}
+	return s.maxPerZaProxy <= 0 || running.zaProxies[e.zaProxy()] < s.maxPerZaProxy
+}
+
+func (u *usage) add(e *entry) {
+	u.total++
+	u.zaProxies[e.zaProxy()]++
+	u.namespaces[e.Namespace]++
+}
+
+func (e *entry) zaProxy() types.NamespacedName {
+	return types.NamespacedName{Name: e.ZaProxy, Namespace: e.Job.Namespace}
+}
+
+// active reports whether the job may still run analyzer pods
+func active(job *batchv1.Job) bool {
+	if job.Spec.Parallelism != nil && *job.Spec.Parallelism == 0 {
+		return false
+	}
+	if job.Status.CompletionTime != nil {
+		return false
+	}
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return false
+		}
+	}
+	return true
+}
+
+// subjectNamespace returns the namespace of the object scanned by the job
+func subjectNamespace(job *batchv1.Job) string {
+	labels := job.GetLabels()
+	if namespace, ok := labels[analyzer.ServiceNamespaceLabel]; ok {
+		return namespace
+	}
+	if namespace, ok := labels[analyzer.IngressNamespaceLabel]; ok {
+		return namespace
+	}
+	return job.GetNamespace()
+}
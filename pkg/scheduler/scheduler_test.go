@@ -0,0 +1,125 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/banzaicloud/dast-operator/pkg/resources/analyzer"
+)
+
+func newJob(name, zaProxy, namespace string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "zaproxy",
+			Labels: map[string]string{
+				"app":                          "analyzer",
+				analyzer.ZaProxyLabel:          zaProxy,
+				analyzer.ServiceNamespaceLabel: namespace,
+			},
+		},
+	}
+}
+
+func newRequest(name, zaProxy, namespace string, priority int) Request {
+	return Request{
+		Job:       types.NamespacedName{Name: name, Namespace: "zaproxy"},
+		ZaProxy:   zaProxy,
+		Namespace: namespace,
+		Priority:  priority,
+	}
+}
+
+func newScheduler(t *testing.T, maxConcurrent, maxPerZaProxy int, objs ...runtime.Object) (*Scheduler, *time.Time) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	s := New(fake.NewFakeClientWithScheme(scheme, objs...), maxConcurrent, maxPerZaProxy)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func admit(t *testing.T, s *Scheduler, req Request) (bool, int) {
+	admitted, position, err := s.Admit(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admitted, position
+}
+
+func TestAdmitLimits(t *testing.T) {
+	finished := newJob("finished", "zap", "a")
+	parallelism := int32(0)
+	finished.Spec.Parallelism = &parallelism
+	s, _ := newScheduler(t, 2, 1, newJob("running", "zap", "a"), finished)
+
+	if admitted, position := admit(t, s, newRequest("first", "zap", "a", 0)); admitted || position != 1 {
+		t.Errorf("job exceeding the limit of the ZAP instance is admitted: %v, %d", admitted, position)
+	}
+	if admitted, _ := admit(t, s, newRequest("other", "other-zap", "a", 0)); !admitted {
+		t.Error("job of another ZAP instance isn't admitted")
+	}
+	if admitted, position := admit(t, s, newRequest("third", "third-zap", "a", 0)); admitted || position != 2 {
+		t.Errorf("job exceeding the global limit is admitted: %v, %d", admitted, position)
+	}
+	if admitted, _ := admit(t, s, newRequest("other", "other-zap", "a", 0)); !admitted {
+		t.Error("admitted job isn't admitted again")
+	}
+}
+
+func TestAdmitOrder(t *testing.T) {
+	s, now := newScheduler(t, 1, 0, newJob("running", "zap", "a"))
+
+	requests := map[string]Request{}
+	for _, req := range []Request{
+		newRequest("a-1", "zap", "a", 0),
+		newRequest("a-2", "zap", "a", 0),
+		newRequest("b-1", "zap", "b", 0),
+		newRequest("c-1", "zap", "c", 10),
+	} {
+		*now = now.Add(time.Second)
+		if admitted, _ := admit(t, s, req); admitted {
+			t.Fatalf("%s is admitted above the limit", req.Job.Name)
+		}
+		requests[req.Job.Name] = req
+	}
+
+	// higher priority first, then the namespace without running jobs
+	for i, name := range []string{"c-1", "b-1", "a-1", "a-2"} {
+		if _, position := admit(t, s, requests[name]); position != i+1 {
+			t.Errorf("position of %s is %d, expected %d", name, position, i+1)
+		}
+	}
+
+	// requests, which aren't repeated, are dropped
+	*now = now.Add(staleAfter + time.Second)
+	if _, position := admit(t, s, newRequest("a-2", "zap", "a", 0)); position != 1 {
+		t.Errorf("stale requests aren't dropped, position is %d", position)
+	}
+}
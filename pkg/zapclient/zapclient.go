@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zapclient
+
+import (
+	"emperror.dev/emperror"
+	"github.com/go-logr/logr"
+	"github.com/zaproxy/zap-api-go/zap"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+)
+
+// New creates a ZAP client for the ZAP instance deployed by the operator
+func New(zapAddr, zapNamespace, apiKey string) (zap.Interface, error) {
+	// TODO use https
+	cfg := &zap.Config{
+		Proxy:  "http://" + zapAddr + "." + zapNamespace + ".svc.cluster.local:8080",
+		APIKey: apiKey,
+	}
+	client, err := zap.NewClient(cfg)
+	if err != nil {
+		return nil, emperror.Wrap(err, "failed to create zap interface")
+	}
+	return client, nil
+}
+
+// NewFromSecret creates a ZAP client using the API key stored in the secret of the ZAP instance
+func NewFromSecret(zapAddr, zapNamespace string, c client.Client, log logr.Logger) (zap.Interface, error) {
+	secret, err := k8sutil.GetSercretByName(zapAddr, zapNamespace, c, log)
+	if err != nil {
+		return nil, err
+	}
+	return New(zapAddr, zapNamespace, string(secret.Data["zap_api_key"]))
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/notifier"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	denyOnAnnotation = "dast.security.banzaicloud.io/deny-on"
+	// denyOnRegressions denies the object only if the latest scan reported new alerts above the thresholds
+	denyOnRegressions = "regressions"
+)
+
+// ValidatorConfig configures the validating webhooks
+type ValidatorConfig struct {
+	Client client.Client
+	Store  results.Store
+	// Notifier is notified about denied objects
+	Notifier *notifier.Notifier
+	// ResourceBackends decides whether backends, which aren't services, are allowed without scanning
+	ResourceBackends k8sutil.ResourceBackendPolicy
+	// DefaultThresholds are used for the risk levels without threshold annotation, 0 if not set
+	DefaultThresholds *securityv1alpha1.Thresholds
+	// Mode is the enforcement mode of the namespaces without enforcement mode annotation, enforce if not set
+	Mode EnforcementMode
+	// Recorder records events of the objects above the thresholds, optional
+	Recorder record.EventRecorder
+	// FailurePolicy decides whether objects are allowed if the scan results can't be evaluated, fail closed if not set
+	FailurePolicy FailurePolicy
+	// CacheTTL is the time the summaries of the services are cached for, summaries aren't cached if not positive
+	CacheTTL time.Duration
+	// Timeout limits the evaluation of the scan results, not limited if not positive
+	Timeout time.Duration
+	// ScanMaxAge is the maximum age of the accepted scans, not limited if not positive
+	ScanMaxAge time.Duration
+	// AutoEnrolZaProxy is the ZAP, which scans the backend services without zaproxy annotation, services aren't enrolled if nil
+	AutoEnrolZaProxy *types.NamespacedName
+	// OverrideMaxDuration limits the expiry of break-glass overrides, not limited if not positive
+	OverrideMaxDuration time.Duration
+	Log                 logr.Logger
+}
+
+// ErrScanRejected is returned for backend services, whose latest scan isn't accepted, because it's missing, unsuccessful or stale
+var ErrScanRejected = errors.Sentinel("latest scan isn't accepted")
+
+// FailurePolicy decides what happens with objects, whose scan results can't be evaluated
+type FailurePolicy string
+
+const (
+	// FailOpen allows the object with an admission warning
+	FailOpen FailurePolicy = "open"
+	// FailClosed handles the object like objects above the thresholds
+	FailClosed FailurePolicy = "closed"
+)
+
+// ParseFailurePolicy validates a failure policy, empty means fail closed
+func ParseFailurePolicy(policy string) (FailurePolicy, error) {
+	switch FailurePolicy(policy) {
+	case "":
+		return FailClosed, nil
+	case FailOpen, FailClosed:
+		return FailurePolicy(policy), nil
+	default:
+		return "", errors.Errorf("invalid failure policy %q, must be open or closed", policy)
+	}
+}
+
+// backendChecker evaluates the scan results of the backend services of an object
+type backendChecker struct {
+	ValidatorConfig
+	cache *summaryCache
+}
+
+func newBackendChecker(config ValidatorConfig) *backendChecker {
+	return &backendChecker{
+		ValidatorConfig: config,
+		cache:           newSummaryCache(config.CacheTTL),
+	}
+}
+
+// check allows the object if the scan results of the backend services are below the thresholds of the object
+func (b *backendChecker) check(ctx context.Context, req admission.Request, obj *unstructured.Unstructured, backendServices []map[string]string) admission.Response {
+	reason, details, err := b.checkBackends(ctx, obj, backendServices, dryRun(req))
+	if err != nil {
+		return b.failure(ctx, req, obj, err)
+	}
+	if len(details) > 0 {
+		return b.violation(ctx, req, obj, reason, details)
+	}
+	return b.allow(ctx, obj, reason)
+}
+
+// checkBackends evaluates the latest stored scans of the backend services with the thresholds of the object.
+// It returns the reason of the decision, and the details of the violation, which are empty if the object is allowed.
+// Errors are returned only if the scan results can't be evaluated. Services aren't enrolled by dry-run requests.
+func (b *backendChecker) checkBackends(ctx context.Context, obj *unstructured.Unstructured, backendServices []map[string]string, dryRun bool) (string, []string, error) {
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+
+	annotations, err := k8sutil.ResolveAnnotations(ctx, b.Client, obj)
+	if err != nil {
+		return "", nil, err
+	}
+	tresholds, err := getTresholds(annotations, b.DefaultThresholds)
+	if err != nil {
+		return "invalid threshold annotation", []string{err.Error()}, nil
+	}
+	regressions := annotations[denyOnAnnotation] == denyOnRegressions
+	exceeded, err := b.exceeded(ctx, backendServices, obj.GetNamespace(), tresholds, regressions, dryRun)
+	switch {
+	case errors.Is(err, ErrScanInProgress):
+		return ErrScanInProgress.Error(), []string{err.Error()}, nil
+	case errors.Is(err, ErrScanRejected), errors.Is(err, errNoStoredScan):
+		return ErrScanRejected.Error(), []string{err.Error()}, nil
+	case err != nil:
+		return "", nil, err
+	}
+
+	if regressions {
+		if len(exceeded) > 0 {
+			return "new alerts of the latest scan are above treshold", exceeded, nil
+		}
+		return "new alerts of the latest scan are below treshold", nil, nil
+	}
+	if len(exceeded) > 0 {
+		return "scan results are above treshold", exceeded, nil
+	}
+	return "scan results are below treshold", nil, nil
+}
+
+// failure handles the object, whose scan results can't be evaluated, according to the failure policy
+func (b *backendChecker) failure(ctx context.Context, req admission.Request, obj *unstructured.Unstructured, err error) admission.Response {
+	b.Log.Error(err, "failed to evaluate scan results", "kind", obj.GetKind(), "name", obj.GetName(), "namespace", obj.GetNamespace(), "policy", b.FailurePolicy)
+	reason := "scan results can't be evaluated"
+	if b.FailurePolicy == FailOpen {
+		admissionDecisions.WithLabelValues(obj.GetKind(), obj.GetNamespace(), string(b.enforcementMode(ctx, obj.GetNamespace())), "failed-open").Inc()
+		response := admission.Allowed(reason)
+		response.Warnings = []string{fmt.Sprintf("DAST: %s: %s", reason, err)}
+		return response
+	}
+	return b.violation(ctx, req, obj, reason, []string{err.Error()})
+}
+
+// exceeded returns the exceeded thresholds of the services, only the new alerts of the latest scan are counted for regressions
+func (b *backendChecker) exceeded(ctx context.Context, services []map[string]string, namespace string, tresholds map[string]int, regressions, dryRun bool) ([]string, error) {
+	var exceeded []string
+	for _, service := range services {
+		namespace := backendNamespace(service, namespace)
+		summary, err := b.serviceSummary(ctx, service, namespace, regressions, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		exceeded = append(exceeded, exceededTresholds(namespace, service["name"], summary, tresholds)...)
+	}
+	return exceeded, nil
+}
+
+// serviceSummary returns the cached summary of the service, or evaluates the latest stored scan of it
+func (b *backendChecker) serviceSummary(ctx context.Context, service map[string]string, namespace string, regressions, dryRun bool) (map[string]int, error) {
+	key := strings.Join([]string{namespace, service["name"], service["port"], strconv.FormatBool(regressions)}, "/")
+	if summary, ok := b.cache.get(key); ok {
+		return summary, nil
+	}
+
+	if b.AutoEnrolZaProxy != nil {
+		if err := b.enrol(ctx, service["name"], namespace, dryRun); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := storedSummary(ctx, b.Store, b.Client, namespace, service["name"], regressions, b.ScanMaxAge)
+	if errors.Is(err, errNoStoredScan) && b.AutoEnrolZaProxy != nil {
+		return nil, errors.WrapIff(ErrScanInProgress, "service %s/%s isn't scanned yet", namespace, service["name"])
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.cache.set(key, summary)
+	return summary, nil
+}
+
+// deny notifies about the denied object in the background, so notifications don't delay the admission.
+// Denied dry-run requests aren't notified.
+func (b *backendChecker) deny(req admission.Request, obj *unstructured.Unstructured, reason string) admission.Response {
+	if dryRun(req) {
+		return admission.Denied(reason)
+	}
+	event := &notifier.Event{
+		Type:      securityv1alpha1.EventAdmissionDenied,
+		Namespace: obj.GetNamespace(),
+		Kind:      obj.GetKind(),
+		Name:      obj.GetName(),
+		Message:   reason,
+	}
+	if obj.GetKind() == "Ingress" {
+		event.Ingress = obj.GetName()
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := b.Notifier.Notify(ctx, event); err != nil {
+			b.Log.Error(err, "failed to send notifications", "kind", obj.GetKind(), "name", obj.GetName())
+		}
+	}()
+	return admission.Denied(reason)
+}
+
+// backendNamespace returns the namespace of a backend service, backends without namespace are in the namespace of the object
+func backendNamespace(service map[string]string, namespace string) string {
+	if ns := service["namespace"]; ns != "" {
+		return ns
+	}
+	return namespace
+}
+
+// storedSummary returns the summary of the latest stored scans of the ports of the service, or the summary of their new alerts.
+// Alerts accepted by the baseline of the service aren't counted.
+// Missing, unsuccessful and stale scans are rejected, scans above the thresholds of the analyzer are evaluated with the thresholds of the webhooks.
+func storedSummary(ctx context.Context, store results.Store, c client.Client, namespace, service string, newOnly bool, maxAge time.Duration) (map[string]int, error) {
+	if store == nil {
+		return nil, errors.New("scan results aren't stored")
+	}
+	scans, err := results.LatestPerPort(ctx, store, namespace, service)
+	if err != nil {
+		return nil, err
+	}
+	if len(scans) == 0 {
+		return nil, errors.WrapIff(errNoStoredScan, "service %s/%s", namespace, service)
+	}
+	var alerts []results.Alert
+	for _, scan := range scans {
+		if newOnly && scan.Diff == nil {
+			return nil, errors.WrapIff(errNoStoredScan, "service %s/%s%s", namespace, service, portSuffix(scan))
+		}
+		if !scan.Passed && scan.Reason != securityv1alpha1.ReasonThresholdExceeded {
+			return nil, errors.WrapIff(ErrScanRejected, "latest scan %s of service %s/%s%s failed: %s", scan.ID, namespace, service, portSuffix(scan), scan.Reason)
+		}
+		if maxAge > 0 && scan.CompletionTime != nil && time.Since(scan.CompletionTime.Time) > maxAge {
+			return nil, errors.WrapIff(ErrScanRejected, "latest scan %s of service %s/%s%s is older than %s", scan.ID, namespace, service, portSuffix(scan), maxAge)
+		}
+		if newOnly {
+			alerts = append(alerts, scan.Diff.New...)
+		} else {
+			alerts = append(alerts, scan.Alerts...)
+		}
+	}
+	baseline, err := results.GetBaseline(ctx, c, namespace, service)
+	if err != nil {
+		return nil, err
+	}
+	return results.Summarize(results.ExcludeBaseline(alerts, baseline)), nil
+}
+
+// portSuffix describes the scanned port of the service in messages, empty if the scan has no port
+func portSuffix(scan *results.Scan) string {
+	if scan.Port == 0 {
+		return ""
+	}
+	return fmt.Sprintf(":%d", scan.Port)
+}
+
+// riskLevels are the risk levels of the alerts in descending order
+var riskLevels = []string{"High", "Medium", "Low", "Informational"}
+
+// exceededTresholds describes the risk levels of the summary, which are above the thresholds
+func exceededTresholds(namespace, service string, summary, tresholds map[string]int) []string {
+	var exceeded []string
+	for _, risk := range riskLevels {
+		if summary[risk] > tresholds[risk] {
+			exceeded = append(exceeded, fmt.Sprintf("%s/%s: %s alerts %d > %d", namespace, service, risk, summary[risk], tresholds[risk]))
+		}
+	}
+	return exceeded
+}
+
+// getTresholds returns the thresholds defined by the annotations, the defaults are used for the missing annotations.
+// An invalid threshold annotation is an error.
+func getTresholds(annotations map[string]string, defaults *securityv1alpha1.Thresholds) (map[string]int, error) {
+	treshold := map[string]int{
+		"High":          0,
+		"Medium":        0,
+		"Low":           0,
+		"Informational": 0,
+	}
+	if defaults != nil {
+		for risk, value := range map[string]*int{
+			"High":          defaults.High,
+			"Medium":        defaults.Medium,
+			"Low":           defaults.Low,
+			"Informational": defaults.Informational,
+		} {
+			if value != nil {
+				treshold[risk] = *value
+			}
+		}
+	}
+	for risk, annotation := range map[string]string{
+		"High":          "dast.security.banzaicloud.io/high",
+		"Medium":        "dast.security.banzaicloud.io/medium",
+		"Low":           "dast.security.banzaicloud.io/low",
+		"Informational": "dast.security.banzaicloud.io/informational",
+	} {
+		value, ok := annotations[annotation]
+		if !ok {
+			continue
+		}
+		max, err := strconv.Atoi(value)
+		if err != nil || max < 0 {
+			return nil, errors.Errorf("invalid %s annotation %q, it must be a non-negative number", annotation, value)
+		}
+		treshold[risk] = max
+	}
+	return treshold, nil
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func newFakeClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := securityv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewFakeClientWithScheme(scheme, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+}
+
+func TestCheckStoredResults(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := results.NewConfigMapStore(c, c, 10)
+	if err := store.Save(ctx, &results.Scan{
+		ID:             "1",
+		Namespace:      "default",
+		Service:        "app",
+		CompletionTime: &metav1.Time{Time: time.Now()},
+		Passed:         true,
+		Alerts:         []results.Alert{{PluginID: "1", Name: "XSS", Risk: results.RiskHigh, URL: "http://app"}},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := newBackendChecker(ValidatorConfig{Client: c, Store: store, CacheTTL: time.Minute, Log: zap.New()})
+	ingress := newIngress("default")
+	response := checker.check(ctx, admission.Request{}, ingress, []map[string]string{{"name": "app", "port": "80"}})
+	if response.Allowed {
+		t.Error("ingress above the thresholds is allowed")
+	}
+
+	ingress.SetAnnotations(map[string]string{"dast.security.banzaicloud.io/high": "1"})
+	response = checker.check(ctx, admission.Request{}, ingress, []map[string]string{{"name": "app", "port": "80"}})
+	if !response.Allowed {
+		t.Errorf("ingress below the thresholds is denied: %s", response.Result.Reason)
+	}
+
+	// the summary is cached, the deleted scan isn't noticed until the entry expires
+	var scans corev1.ConfigMapList
+	if err := c.List(ctx, &scans); err != nil {
+		t.Fatal(err)
+	}
+	for i := range scans.Items {
+		if err := c.Delete(ctx, &scans.Items[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	response = checker.check(ctx, admission.Request{}, ingress, []map[string]string{{"name": "app", "port": "80"}})
+	if !response.Allowed {
+		t.Errorf("cached summary isn't used: %s", response.Result.Reason)
+	}
+}
+
+// TestCheckStoredResultsOfPorts checks that the latest scans of every port of the service are evaluated, not only the latest scan of the service
+func TestCheckStoredResultsOfPorts(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := results.NewConfigMapStore(c, c, 10)
+	now := time.Now()
+	for _, scan := range []*results.Scan{
+		{ID: "1", Port: 8080, CompletionTime: &metav1.Time{Time: now.Add(-time.Minute)}, Reason: securityv1alpha1.ReasonThresholdExceeded,
+			Alerts: []results.Alert{{PluginID: "1", Name: "XSS", Risk: results.RiskHigh, URL: "http://app:8080"}}},
+		{ID: "2", Port: 80, CompletionTime: &metav1.Time{Time: now}, Passed: true},
+	} {
+		scan.Namespace, scan.Service = "default", "app"
+		if err := store.Save(ctx, scan, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checker := newBackendChecker(ValidatorConfig{Client: c, Store: store, Log: zap.New()})
+	response := checker.check(ctx, admission.Request{}, newIngress("default"), []map[string]string{{"name": "app", "port": "80"}})
+	if response.Allowed {
+		t.Error("ingress with a port above the thresholds is allowed")
+	}
+	if !strings.Contains(string(response.Result.Reason), "High alerts 1 > 0") {
+		t.Errorf("unexpected reason %q", response.Result.Reason)
+	}
+}
+
+func TestCheckInvalidThresholds(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := results.NewConfigMapStore(c, c, 10)
+	if err := store.Save(ctx, &results.Scan{ID: "1", Namespace: "default", Service: "app", CompletionTime: &metav1.Time{Time: time.Now()}, Passed: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := newBackendChecker(ValidatorConfig{Client: c, Store: store, FailurePolicy: FailOpen, Log: zap.New()})
+	for _, value := range []string{"many", "-1"} {
+		ingress := newIngress("default")
+		ingress.SetAnnotations(map[string]string{"dast.security.banzaicloud.io/high": value})
+		response := checker.check(ctx, admission.Request{}, ingress, []map[string]string{{"name": "app", "port": "80"}})
+		if response.Allowed {
+			t.Errorf("ingress with high=%s is allowed", value)
+		}
+	}
+}
+
+func TestCheckFailurePolicy(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+
+	for policy, allowed := range map[FailurePolicy]bool{"": false, FailClosed: false, FailOpen: true} {
+		checker := newBackendChecker(ValidatorConfig{Client: c, Store: nil, FailurePolicy: policy, Log: zap.New()})
+		response := checker.check(ctx, admission.Request{}, newIngress("default"), []map[string]string{{"name": "unscanned", "port": "80"}})
+		if response.Allowed != allowed {
+			t.Errorf("policy %q: allowed is %v, expected %v", policy, response.Allowed, allowed)
+		}
+		if allowed && len(response.Warnings) != 1 {
+			t.Errorf("policy %q: unexpected warnings %v", policy, response.Warnings)
+		}
+	}
+}
+
+func TestCheckRejectedScans(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := results.NewConfigMapStore(c, c, 10)
+	now := &metav1.Time{Time: time.Now()}
+	alerts := []results.Alert{{PluginID: "1", Name: "XSS", Risk: results.RiskHigh, URL: "http://app"}}
+	for _, scan := range []*results.Scan{
+		{ID: "1", Namespace: "default", Service: "failed", CompletionTime: now, Reason: securityv1alpha1.ReasonError},
+		{ID: "2", Namespace: "default", Service: "timeout", CompletionTime: now, Reason: securityv1alpha1.ReasonTimeout},
+		{ID: "3", Namespace: "default", Service: "stale", CompletionTime: &metav1.Time{Time: now.Add(-48 * time.Hour)}, Passed: true},
+		{ID: "4", Namespace: "default", Service: "exceeded", CompletionTime: now, Reason: securityv1alpha1.ReasonThresholdExceeded, Alerts: alerts},
+	} {
+		if err := store.Save(ctx, scan, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checker := newBackendChecker(ValidatorConfig{Client: c, Store: store, FailurePolicy: FailOpen, ScanMaxAge: 24 * time.Hour, Log: zap.New()})
+	tests := map[string]bool{"failed": false, "timeout": false, "stale": false, "unscanned": false, "exceeded": true}
+	for service, allowed := range tests {
+		// the alerts of the scan above the default thresholds are below the thresholds of the ingress
+		ingress := newIngress("default")
+		ingress.SetAnnotations(map[string]string{"dast.security.banzaicloud.io/high": "1"})
+		response := checker.check(ctx, admission.Request{}, ingress, []map[string]string{{"name": service, "port": "80"}})
+		if response.Allowed != allowed {
+			t.Errorf("%s: allowed is %v, expected %v: %s", service, response.Allowed, allowed, response.Result.Reason)
+		}
+	}
+}
+
+func TestCheckAutoEnrol(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	if err := c.Create(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+	store := results.NewConfigMapStore(c, c, 10)
+	checker := newBackendChecker(ValidatorConfig{
+		Client:           c,
+		Store:            store,
+		FailurePolicy:    FailOpen,
+		AutoEnrolZaProxy: &types.NamespacedName{Name: "dast-test", Namespace: "zaproxy"},
+		Log:              zap.New(),
+	})
+
+	// a dry run doesn't enrol the service
+	dryRun := true
+	response := checker.check(ctx, admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{DryRun: &dryRun}}, newIngress("default"), []map[string]string{{"name": "app", "port": "80"}})
+	if response.Allowed || !strings.Contains(string(response.Result.Reason), "scan in progress, retry later") {
+		t.Fatalf("unexpected dry run response %v: %s", response.Allowed, response.Result.Reason)
+	}
+	var service corev1.Service
+	if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, &service); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.GetAnnotations()[zaproxyAnnotation]; ok {
+		t.Fatalf("service is enrolled by a dry run: %v", service.GetAnnotations())
+	}
+
+	// the service is enrolled by the first admission, and it's in progress until the scan is stored
+	for i := 0; i < 2; i++ {
+		response := checker.check(ctx, admission.Request{}, newIngress("default"), []map[string]string{{"name": "app", "port": "80"}})
+		if response.Allowed || !strings.Contains(string(response.Result.Reason), "scan in progress, retry later") {
+			t.Fatalf("unexpected response %v: %s", response.Allowed, response.Result.Reason)
+		}
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, &service); err != nil {
+		t.Fatal(err)
+	}
+	if annotations := service.GetAnnotations(); annotations[zaproxyAnnotation] != "dast-test" || annotations[zaproxyNamespaceAnnotation] != "zaproxy" {
+		t.Errorf("service isn't enrolled: %v", annotations)
+	}
+
+	if err := store.Save(ctx, &results.Scan{ID: "1", Namespace: "default", Service: "app", Passed: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if response := checker.check(ctx, admission.Request{}, newIngress("default"), []map[string]string{{"name": "app", "port": "80"}}); !response.Allowed {
+		t.Errorf("scanned service is denied: %s", response.Result.Reason)
+	}
+}
+
+func TestCheckForgedScan(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	key, err := results.NewSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := results.NewSignedConfigMapStore(c, c, 10, key)
+	// the users of the namespace can create result ConfigMaps without the signing key of the operator
+	forger := results.NewConfigMapStore(c, c, 10)
+	now := &metav1.Time{Time: time.Now()}
+	if err := store.Save(ctx, &results.Scan{ID: "1", Namespace: "default", Service: "signed", CompletionTime: now, Passed: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := forger.Save(ctx, &results.Scan{ID: "2", Namespace: "default", Service: "forged", CompletionTime: now, Passed: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := newBackendChecker(ValidatorConfig{Client: c, Store: store, FailurePolicy: FailOpen, Log: zap.New()})
+	for service, allowed := range map[string]bool{"signed": true, "forged": false} {
+		response := checker.check(ctx, admission.Request{}, newIngress("default"), []map[string]string{{"name": service, "port": "80"}})
+		if response.Allowed != allowed {
+			t.Errorf("%s: allowed is %v, expected %v: %s", service, response.Allowed, allowed, response.Result.Reason)
+		}
+	}
+}
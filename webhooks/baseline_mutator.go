@@ -0,0 +1,107 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+// +kubebuilder:webhook:path=/baseline,mutating=true,failurePolicy=fail,groups="",resources=services,verbs=create;update,versions=v1,name=dast-baseline.security.banzaicloud.io
+
+// AcceptVerb is the virtual verb on dastbaselines, which allows users to accept the alerts of services into their baseline
+const AcceptVerb = "accept"
+
+// NewBaselineMutator creates a mutator, which authorizes the acceptance of baselines and records the accepting user on the service
+func NewBaselineMutator(config ValidatorConfig) BaselineMutator {
+	return &baselineMutator{
+		backendChecker: newBackendChecker(config),
+	}
+}
+
+// BaselineMutator implements Handle
+type BaselineMutator interface {
+	Handle(context.Context, admission.Request) admission.Response
+}
+
+type baselineMutator struct {
+	*backendChecker
+	decoder *admission.Decoder
+}
+
+// Handle denies labelling services with the accept-baseline label, unless the user is allowed to accept baselines in the namespace.
+// The user is recorded in the accepted-by annotation of the service.
+func (a *baselineMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := &unstructured.Unstructured{}
+	if err := a.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if obj.GetLabels()[results.AcceptBaselineLabel] != "true" {
+		return admission.Allowed("baseline isn't accepted")
+	}
+	acceptedBy := obj.GetAnnotations()[results.AcceptedByAnnotation]
+	if req.Operation == admissionv1beta1.Update {
+		oldObj := &unstructured.Unstructured{}
+		if err := a.decoder.DecodeRaw(req.OldObject, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// the accepted alerts can't be changed under the authorization of another user
+		if oldObj.GetLabels()[results.AcceptBaselineLabel] == "true" && acceptedBy != "" && oldObj.GetAnnotations()[results.AcceptedByAnnotation] == acceptedBy &&
+			oldObj.GetAnnotations()[results.AcceptAlertsAnnotation] == obj.GetAnnotations()[results.AcceptAlertsAnnotation] {
+			return admission.Allowed("baseline acceptance is already authorized")
+		}
+	}
+
+	user := req.UserInfo.Username
+	allowed, err := a.authorize(ctx, req, obj.GetNamespace(), AcceptVerb, "dastbaselines")
+	if err != nil {
+		a.Log.Error(err, "failed to authorize baseline acceptance", "service", obj.GetName(), "namespace", obj.GetNamespace(), "user", user)
+		return admission.Denied("baseline acceptance can't be authorized")
+	}
+	if !allowed {
+		message := fmt.Sprintf("user %s isn't allowed to accept the baseline of services in namespace %s", user, obj.GetNamespace())
+		a.record(req, obj, "BaselineRejected", message)
+		return admission.Denied(message)
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[results.AcceptedByAnnotation] = user
+	obj.SetAnnotations(annotations)
+	marshaled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	a.Log.Info("baseline acceptance authorized", "service", obj.GetName(), "namespace", obj.GetNamespace(), "user", user)
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// InjectDecoder injects the decoder.
+func (a *baselineMutator) InjectDecoder(d *admission.Decoder) error {
+	a.decoder = d
+	return nil
+}
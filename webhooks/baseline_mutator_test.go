@@ -0,0 +1,110 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func newService(labels, annotations map[string]string) runtime.RawExtension {
+	service := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: labels, Annotations: annotations},
+	}
+	raw, _ := json.Marshal(service)
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestBaselineMutator(t *testing.T) {
+	accept := map[string]string{results.AcceptBaselineLabel: "true"}
+	acceptedBy := map[string]string{results.AcceptedByAnnotation: "admin"}
+	spoofed := map[string]string{results.AcceptedByAnnotation: "someone"}
+	suppressed := map[string]string{results.AcceptedByAnnotation: "admin", results.AcceptAlertsAnnotation: `{"pluginId":"10038"}`}
+	widened := map[string]string{results.AcceptedByAnnotation: "admin", results.AcceptAlertsAnnotation: `{"pluginId":"40012"}`}
+	tests := []struct {
+		name      string
+		user      string
+		operation admissionv1beta1.Operation
+		object    runtime.RawExtension
+		oldObject runtime.RawExtension
+		allowed   bool
+		patched   bool
+	}{
+		{name: "not accepted", user: "developer", operation: admissionv1beta1.Create, object: newService(nil, spoofed), allowed: true},
+		{name: "accepted", user: "admin", operation: admissionv1beta1.Create, object: newService(accept, nil), allowed: true, patched: true},
+		{name: "forbidden", user: "developer", operation: admissionv1beta1.Update, object: newService(accept, nil), oldObject: newService(nil, nil)},
+		{name: "spoofed user", user: "developer", operation: admissionv1beta1.Update, object: newService(accept, spoofed), oldObject: newService(nil, spoofed)},
+		{name: "already authorized", user: "developer", operation: admissionv1beta1.Update, object: newService(accept, acceptedBy), oldObject: newService(accept, acceptedBy), allowed: true},
+		{name: "changed user", user: "developer", operation: admissionv1beta1.Update, object: newService(accept, spoofed), oldObject: newService(accept, acceptedBy)},
+		{name: "suppressed", user: "developer", operation: admissionv1beta1.Update, object: newService(accept, suppressed), oldObject: newService(accept, suppressed), allowed: true},
+		{name: "changed alerts", user: "developer", operation: admissionv1beta1.Update, object: newService(accept, widened), oldObject: newService(accept, suppressed)},
+		{name: "removed", user: "developer", operation: admissionv1beta1.Update, object: newService(nil, nil), oldObject: newService(accept, acceptedBy), allowed: true},
+	}
+	decoder, err := admission.NewDecoder(clientgoscheme.Scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mutator := NewBaselineMutator(ValidatorConfig{Client: reviewClient{newFakeClient(t)}, Log: zap.New()})
+			if err := mutator.(*baselineMutator).InjectDecoder(decoder); err != nil {
+				t.Fatal(err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: test.operation,
+				Namespace: "default",
+				Object:    test.object,
+				OldObject: test.oldObject,
+				UserInfo:  authenticationv1.UserInfo{Username: test.user},
+			}}
+
+			response := mutator.Handle(context.Background(), req)
+			if response.Allowed != test.allowed {
+				t.Fatalf("allowed is %v, expected %v: %s", response.Allowed, test.allowed, response.Result.Reason)
+			}
+			if !test.allowed && !strings.Contains(string(response.Result.Reason), "isn't allowed to accept") {
+				t.Errorf("unexpected reason %q", response.Result.Reason)
+			}
+			if !test.patched {
+				if len(response.Patches) != 0 {
+					t.Errorf("unexpected patches %v", response.Patches)
+				}
+				return
+			}
+			if len(response.Patches) != 1 || response.Patches[0].Path != "/metadata/annotations" {
+				t.Fatalf("unexpected patches %v", response.Patches)
+			}
+			if value := response.Patches[0].Value.(map[string]interface{})[results.AcceptedByAnnotation]; value != "admin" {
+				t.Errorf("unexpected accepting user %v", value)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummaryCache(t *testing.T) {
+	now := time.Now()
+	cache := newSummaryCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.set("default/app", map[string]int{"High": 1})
+	if summary, ok := cache.get("default/app"); !ok || summary["High"] != 1 {
+		t.Fatalf("unexpected cached summary %v, %v", summary, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("default/app"); ok {
+		t.Error("expired summary is returned")
+	}
+
+	disabled := newSummaryCache(0)
+	disabled.set("default/app", map[string]int{"High": 1})
+	if _, ok := disabled.get("default/app"); ok {
+		t.Error("summary is cached without ttl")
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap in the namespace of the operator, which holds the effective config of the webhooks
+	ConfigMapName = "dast-webhook-config"
+	// ConfigLabel marks the ConfigMap of the effective config of the webhooks
+	ConfigLabel = "dast.security.banzaicloud.io/webhook-config"
+	configKey   = "config.yaml"
+)
+
+// EffectiveConfig is the config of the webhooks published by the operator, so clients evaluate objects the same way as the webhooks
+type EffectiveConfig struct {
+	DefaultThresholds   *securityv1alpha1.Thresholds  `json:"defaultThresholds,omitempty"`
+	Mode                EnforcementMode               `json:"enforcementMode"`
+	FailurePolicy       FailurePolicy                 `json:"failurePolicy"`
+	ResourceBackends    k8sutil.ResourceBackendPolicy `json:"resourceBackends"`
+	ScanMaxAge          metav1.Duration               `json:"scanMaxAge"`
+	OverrideMaxDuration metav1.Duration               `json:"overrideMaxDuration"`
+	// AutoEnrolZaProxy is the auto-enrol ZAP in namespace/name format, empty if services aren't enrolled
+	AutoEnrolZaProxy string `json:"autoEnrolZaProxy,omitempty"`
+}
+
+// PublishConfig creates or updates the ConfigMap of the effective config of the webhooks in the namespace of the operator
+func PublishConfig(ctx context.Context, c client.Client, reader client.Reader, namespace string, config ValidatorConfig) error {
+	effective := EffectiveConfig{
+		DefaultThresholds:   config.DefaultThresholds,
+		Mode:                config.Mode,
+		FailurePolicy:       config.FailurePolicy,
+		ResourceBackends:    config.ResourceBackends,
+		ScanMaxAge:          metav1.Duration{Duration: config.ScanMaxAge},
+		OverrideMaxDuration: metav1.Duration{Duration: config.OverrideMaxDuration},
+	}
+	if config.AutoEnrolZaProxy != nil {
+		effective.AutoEnrolZaProxy = config.AutoEnrolZaProxy.String()
+	}
+	content, err := yaml.Marshal(effective)
+	if err != nil {
+		return errors.WrapIf(err, "failed to marshal webhook config")
+	}
+
+	var configMap corev1.ConfigMap
+	err = reader.Get(ctx, types.NamespacedName{Name: ConfigMapName, Namespace: namespace}, &configMap)
+	if apierrors.IsNotFound(err) {
+		configMap = corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapName,
+				Namespace: namespace,
+				Labels:    map[string]string{ConfigLabel: "true"},
+			},
+			Data: map[string]string{configKey: string(content)},
+		}
+		return errors.WrapIfWithDetails(c.Create(ctx, &configMap), "failed to create webhook config", "namespace", namespace)
+	}
+	if err != nil {
+		return errors.WrapIfWithDetails(err, "failed to get webhook config", "namespace", namespace)
+	}
+	if configMap.Labels == nil {
+		configMap.Labels = map[string]string{}
+	}
+	configMap.Labels[ConfigLabel] = "true"
+	configMap.Data = map[string]string{configKey: string(content)}
+	return errors.WrapIfWithDetails(c.Update(ctx, &configMap), "failed to update webhook config", "namespace", namespace)
+}
+
+// LoadConfig sets the effective config of the webhooks published by the operator in the config.
+// The ConfigMap is looked up in every namespace by its label if the namespace of the operator is empty.
+func LoadConfig(ctx context.Context, c client.Reader, namespace string, config *ValidatorConfig) error {
+	var configMap corev1.ConfigMap
+	if namespace != "" {
+		if err := c.Get(ctx, types.NamespacedName{Name: ConfigMapName, Namespace: namespace}, &configMap); err != nil {
+			return errors.WrapIfWithDetails(err, "failed to get webhook config of the operator", "namespace", namespace)
+		}
+	} else {
+		var list corev1.ConfigMapList
+		if err := c.List(ctx, &list, client.MatchingLabels{ConfigLabel: "true"}); err != nil {
+			return errors.WrapIf(err, "failed to list webhook configs of the operator")
+		}
+		if len(list.Items) != 1 {
+			return errors.Errorf("found %d webhook configs of the operator instead of one, set the namespace of the operator", len(list.Items))
+		}
+		configMap = list.Items[0]
+	}
+
+	var effective EffectiveConfig
+	if err := yaml.Unmarshal([]byte(configMap.Data[configKey]), &effective); err != nil {
+		return errors.WrapIfWithDetails(err, "invalid webhook config", "namespace", configMap.GetNamespace())
+	}
+	config.DefaultThresholds = effective.DefaultThresholds
+	config.Mode = effective.Mode
+	config.FailurePolicy = effective.FailurePolicy
+	config.ResourceBackends = effective.ResourceBackends
+	config.ScanMaxAge = effective.ScanMaxAge.Duration
+	config.OverrideMaxDuration = effective.OverrideMaxDuration.Duration
+	config.AutoEnrolZaProxy = nil
+	if effective.AutoEnrolZaProxy != "" {
+		parts := strings.Split(effective.AutoEnrolZaProxy, "/")
+		if len(parts) != 2 {
+			return errors.Errorf("invalid auto-enrol zaproxy %q in webhook config", effective.AutoEnrolZaProxy)
+		}
+		config.AutoEnrolZaProxy = &types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func TestPublishConfig(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+
+	var config ValidatorConfig
+	if err := LoadConfig(ctx, c, "", &config); err == nil {
+		t.Error("missing webhook config is loaded")
+	}
+
+	thresholds, err := k8sutil.ParseThresholds("high=0,medium=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := ValidatorConfig{
+		DefaultThresholds: thresholds,
+		Mode:              EnforcementWarn,
+		FailurePolicy:     FailOpen,
+		ResourceBackends:  k8sutil.ResourceBackendDeny,
+		ScanMaxAge:        24 * time.Hour,
+		AutoEnrolZaProxy:  &types.NamespacedName{Name: "dast-test", Namespace: "zaproxy"},
+	}
+	if err := PublishConfig(ctx, c, c, "dast-operator-system", published); err != nil {
+		t.Fatal(err)
+	}
+	published.Mode = EnforcementAudit
+	if err := PublishConfig(ctx, c, c, "dast-operator-system", published); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, namespace := range []string{"dast-operator-system", ""} {
+		var config ValidatorConfig
+		if err := LoadConfig(ctx, c, namespace, &config); err != nil {
+			t.Fatal(err)
+		}
+		if config.Mode != EnforcementAudit || config.FailurePolicy != FailOpen || config.ResourceBackends != k8sutil.ResourceBackendDeny ||
+			config.ScanMaxAge != 24*time.Hour || config.DefaultThresholds == nil || *config.DefaultThresholds.Medium != 5 ||
+			config.AutoEnrolZaProxy == nil || *config.AutoEnrolZaProxy != *published.AutoEnrolZaProxy {
+			t.Errorf("unexpected loaded config %+v", config)
+		}
+	}
+}
+
+func TestExplainAutoEnrol(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	if err := c.Create(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PublishConfig(ctx, c, c, "dast-operator-system", ValidatorConfig{
+		AutoEnrolZaProxy: &types.NamespacedName{Name: "dast-test", Namespace: "zaproxy"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	config := ValidatorConfig{Client: c, Store: results.NewConfigMapStore(c, c, 10), Log: zap.New()}
+	if err := LoadConfig(ctx, c, "", &config); err != nil {
+		t.Fatal(err)
+	}
+
+	ingress := &networkingv1.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			DefaultBackend: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{Name: "app", Port: networkingv1.ServiceBackendPort{Number: 80}},
+			},
+		},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ingress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the unscanned service would be enrolled by the webhook, but explain doesn't modify it
+	explanation := Explain(ctx, config, &unstructured.Unstructured{Object: content}, authenticationv1.UserInfo{})
+	if explanation.Allowed || !strings.Contains(explanation.Message, "would be enrolled") {
+		t.Errorf("unexpected explanation: %+v", explanation)
+	}
+	var service corev1.Service
+	if err := c.Get(ctx, types.NamespacedName{Name: "app", Namespace: "default"}, &service); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.GetAnnotations()[zaproxyAnnotation]; ok {
+		t.Error("explain enrolled the service")
+	}
+}
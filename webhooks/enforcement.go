@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// EnforcementMode decides what happens with objects, which are above the thresholds
+type EnforcementMode string
+
+const (
+	// EnforcementEnforce denies the object
+	EnforcementEnforce EnforcementMode = "enforce"
+	// EnforcementWarn admits the object with admission warnings
+	EnforcementWarn EnforcementMode = "warn"
+	// EnforcementAudit admits the object, the decision is only logged, counted and recorded as an event
+	EnforcementAudit EnforcementMode = "audit"
+
+	// enforcementModeAnnotation overrides the enforcement mode of the webhooks for a namespace
+	enforcementModeAnnotation = "dast.security.banzaicloud.io/enforcement-mode"
+)
+
+// ParseEnforcementMode validates an enforcement mode, empty means enforce
+func ParseEnforcementMode(mode string) (EnforcementMode, error) {
+	switch EnforcementMode(mode) {
+	case "":
+		return EnforcementEnforce, nil
+	case EnforcementEnforce, EnforcementWarn, EnforcementAudit:
+		return EnforcementMode(mode), nil
+	default:
+		return "", errors.Errorf("invalid enforcement mode %q, must be enforce, warn or audit", mode)
+	}
+}
+
+var admissionDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "dast_admission_decisions_total",
+	Help: "Number of admission decisions of the DAST webhooks",
+}, []string{"kind", "namespace", "mode", "decision"})
+
+func init() {
+	metrics.Registry.MustRegister(admissionDecisions)
+}
+
+// enforcementMode returns the mode of the namespace, or the global mode if the namespace has no valid enforcement mode annotation
+func (b *backendChecker) enforcementMode(ctx context.Context, namespace string) EnforcementMode {
+	mode := b.Mode
+	if mode == "" {
+		mode = EnforcementEnforce
+	}
+	if namespace == "" {
+		return mode
+	}
+	var ns corev1.Namespace
+	if err := b.Client.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		b.Log.Error(err, "failed to get namespace, using the global enforcement mode", "namespace", namespace)
+		return mode
+	}
+	annotation, ok := ns.GetAnnotations()[enforcementModeAnnotation]
+	if !ok {
+		return mode
+	}
+	nsMode, err := ParseEnforcementMode(annotation)
+	if err != nil {
+		b.Log.Error(err, "invalid enforcement mode of namespace, using the global enforcement mode", "namespace", namespace)
+		return mode
+	}
+	return nsMode
+}
+
+// allow admits the object, which is below the thresholds
+func (b *backendChecker) allow(ctx context.Context, obj *unstructured.Unstructured, reason string) admission.Response {
+	admissionDecisions.WithLabelValues(obj.GetKind(), obj.GetNamespace(), string(b.enforcementMode(ctx, obj.GetNamespace())), "allowed").Inc()
+	return admission.Allowed(reason)
+}
+
+// violation handles the object, which is above the thresholds, according to the enforcement mode of its namespace.
+// Denied objects are admitted if they have an authorized override.
+func (b *backendChecker) violation(ctx context.Context, req admission.Request, obj *unstructured.Unstructured, reason string, details []string) admission.Response {
+	mode := b.enforcementMode(ctx, obj.GetNamespace())
+	message := reason
+	if len(details) > 0 {
+		message = fmt.Sprintf("%s: %s", reason, strings.Join(details, ", "))
+	}
+
+	switch mode {
+	case EnforcementWarn:
+		return b.warn(req, obj, reason, details)
+	case EnforcementAudit:
+		admissionDecisions.WithLabelValues(obj.GetKind(), obj.GetNamespace(), string(mode), "audited").Inc()
+		b.Log.Info("admission audited", "kind", obj.GetKind(), "name", obj.GetName(), "namespace", obj.GetNamespace(), "reason", message)
+		b.record(req, obj, "AdmissionAudited", message)
+		return admission.Allowed(reason)
+	default:
+		response, overridden, rejected := b.override(ctx, req, obj, message)
+		if overridden {
+			admissionDecisions.WithLabelValues(obj.GetKind(), obj.GetNamespace(), string(mode), "overridden").Inc()
+			return response
+		}
+		if rejected != "" {
+			message = fmt.Sprintf("%s, %s", message, rejected)
+		}
+		admissionDecisions.WithLabelValues(obj.GetKind(), obj.GetNamespace(), string(mode), "denied").Inc()
+		b.record(req, obj, "AdmissionDenied", message)
+		return b.deny(req, obj, message)
+	}
+}
+
+// warn admits the object, which is above the thresholds, with admission warnings
+func (b *backendChecker) warn(req admission.Request, obj *unstructured.Unstructured, reason string, details []string) admission.Response {
+	message := reason
+	if len(details) > 0 {
+		message = fmt.Sprintf("%s: %s", reason, strings.Join(details, ", "))
+	}
+	admissionDecisions.WithLabelValues(obj.GetKind(), obj.GetNamespace(), string(EnforcementWarn), "warned").Inc()
+	b.record(req, obj, "AdmissionWarned", message)
+	response := admission.Allowed(reason)
+	response.Warnings = append([]string{fmt.Sprintf("DAST: %s", reason)}, details...)
+	return response
+}
+
+// record creates a warning event for the object, if the validator has an event recorder.
+// Dry-run requests have no side effects, so no events are recorded for them.
+func (b *backendChecker) record(req admission.Request, obj *unstructured.Unstructured, reason, message string) {
+	if b.Recorder == nil || dryRun(req) {
+		return
+	}
+	b.Recorder.Event(obj, corev1.EventTypeWarning, reason, message)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/banzaicloud/dast-operator/pkg/notifier"
+)
+
+func newIngress(namespace string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("networking.k8s.io/v1")
+	obj.SetKind("Ingress")
+	obj.SetName("app")
+	obj.SetNamespace(namespace)
+	return obj
+}
+
+func TestViolation(t *testing.T) {
+	namespaces := []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "warned", Annotations: map[string]string{enforcementModeAnnotation: "warn"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "audited", Annotations: map[string]string{enforcementModeAnnotation: "audit"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Annotations: map[string]string{enforcementModeAnnotation: "block"}}},
+	}
+
+	tests := []struct {
+		mode      EnforcementMode
+		namespace string
+		allowed   bool
+		warnings  int
+		event     string
+	}{
+		{mode: "", namespace: "default", allowed: false, event: "AdmissionDenied"},
+		{mode: EnforcementAudit, namespace: "default", allowed: true, event: "AdmissionAudited"},
+		{mode: EnforcementEnforce, namespace: "warned", allowed: true, warnings: 2, event: "AdmissionWarned"},
+		{mode: EnforcementEnforce, namespace: "audited", allowed: true, event: "AdmissionAudited"},
+		{mode: EnforcementWarn, namespace: "invalid", allowed: true, warnings: 2, event: "AdmissionWarned"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.mode)+"/"+test.namespace, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, namespaces[0], namespaces[1], namespaces[2], namespaces[3])
+			recorder := record.NewFakeRecorder(1)
+			checker := newBackendChecker(ValidatorConfig{Client: c, Mode: test.mode, Recorder: recorder, Log: zap.New()})
+
+			response := checker.violation(context.Background(), admission.Request{}, newIngress(test.namespace), "scan results are above treshold", []string{"default/app: High alerts 1 > 0"})
+			if response.Allowed != test.allowed {
+				t.Errorf("allowed is %v, expected %v", response.Allowed, test.allowed)
+			}
+			if len(response.Warnings) != test.warnings {
+				t.Errorf("unexpected warnings %v", response.Warnings)
+			}
+			event := <-recorder.Events
+			if !strings.Contains(event, test.event) || !strings.Contains(event, "High alerts 1 > 0") {
+				t.Errorf("unexpected event %q", event)
+			}
+		})
+	}
+}
+
+func TestParseEnforcementMode(t *testing.T) {
+	for mode, expected := range map[string]EnforcementMode{"": EnforcementEnforce, "warn": EnforcementWarn, "audit": EnforcementAudit} {
+		parsed, err := ParseEnforcementMode(mode)
+		if err != nil || parsed != expected {
+			t.Errorf("parsed %q as %q, %v", mode, parsed, err)
+		}
+	}
+	if _, err := ParseEnforcementMode("block"); err == nil {
+		t.Error("invalid mode is accepted")
+	}
+}
+
+// listCounter signals the List calls of the notifier
+type listCounter struct {
+	client.Client
+	lists chan struct{}
+}
+
+func (c *listCounter) List(context.Context, runtime.Object, ...client.ListOption) error {
+	c.lists <- struct{}{}
+	return nil
+}
+
+// TestViolationDryRun checks that dry-run requests, which are declared to have no side effects, record no events and send no notifications
+func TestViolationDryRun(t *testing.T) {
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	notifications := &listCounter{lists: make(chan struct{}, 2)}
+	recorder := record.NewFakeRecorder(2)
+	checker := newBackendChecker(ValidatorConfig{Client: reviewClient{c}, Recorder: recorder, Notifier: notifier.New(notifications, nil, zap.New()), Log: zap.New()})
+	dryRun := true
+	// the override of the developer is rejected
+	overridden := newIngress("default")
+	overridden.SetAnnotations(map[string]string{overrideReasonAnnotation: "incident", overrideExpiresAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339)})
+
+	for _, obj := range []*unstructured.Unstructured{newIngress("default"), overridden} {
+		req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{DryRun: &dryRun, UserInfo: authenticationv1.UserInfo{Username: "developer"}}}
+		response := checker.violation(context.Background(), req, obj, "scan results are above treshold", nil)
+		if response.Allowed {
+			t.Errorf("dry-run request is allowed: %+v", response)
+		}
+	}
+	response := checker.violation(context.Background(), admission.Request{}, newIngress("default"), "scan results are above treshold", nil)
+	if response.Allowed {
+		t.Errorf("request is allowed: %+v", response)
+	}
+
+	// only the denial of the request is notified
+	select {
+	case <-notifications.lists:
+	case <-time.After(5 * time.Second):
+		t.Fatal("denial isn't notified")
+	}
+	select {
+	case <-notifications.lists:
+		t.Error("denial of dry-run request is notified")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("unexpected events %d", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "AdmissionDenied") {
+		t.Errorf("unexpected event %q", event)
+	}
+}
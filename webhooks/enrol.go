@@ -0,0 +1,83 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	zaproxyAnnotation          = "dast.security.banzaicloud.io/zaproxy"
+	zaproxyNamespaceAnnotation = "dast.security.banzaicloud.io/zaproxy-namespace"
+	// autoEnrolledAnnotation marks the services annotated by the webhooks
+	autoEnrolledAnnotation = "dast.security.banzaicloud.io/auto-enrolled"
+)
+
+// ErrScanInProgress is returned for backend services, which don't have scan results yet, but they are scanned
+var ErrScanInProgress = errors.Sentinel("scan in progress, retry later")
+
+// errNoStoredScan is returned for backend services without stored scan results
+var errNoStoredScan = errors.Sentinel("no stored scan results")
+
+// dryRun reports whether the admission request is a dry run, which must not have side effects
+func dryRun(req admission.Request) bool {
+	return req.DryRun != nil && *req.DryRun
+}
+
+// enrol annotates the service for scanning with the auto-enrol ZAP, if it isn't annotated yet.
+// ErrScanInProgress is returned for enrolled services. The service isn't modified by dry-run requests.
+func (b *backendChecker) enrol(ctx context.Context, name, namespace string, dryRun bool) error {
+	var service corev1.Service
+	if err := b.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &service); err != nil {
+		return errors.WrapIfWithDetails(err, "failed to get service", "service", name, "namespace", namespace)
+	}
+	resolved, err := k8sutil.ResolveService(ctx, b.Client, &service)
+	if err != nil {
+		return err
+	}
+	if _, ok := resolved.GetAnnotations()[zaproxyAnnotation]; ok {
+		return nil
+	}
+	if k8sutil.OptedOut(&service) {
+		return errors.NewWithDetails("service opted out of scanning", "service", name, "namespace", namespace)
+	}
+	if dryRun {
+		return errors.WrapIff(ErrScanInProgress, "service %s/%s would be enrolled", namespace, name)
+	}
+
+	patch := client.MergeFrom(service.DeepCopy())
+	annotations := service.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[zaproxyAnnotation] = b.AutoEnrolZaProxy.Name
+	annotations[zaproxyNamespaceAnnotation] = b.AutoEnrolZaProxy.Namespace
+	annotations[autoEnrolledAnnotation] = "true"
+	service.SetAnnotations(annotations)
+	if err := b.Client.Patch(ctx, &service, patch); err != nil {
+		return errors.WrapIfWithDetails(err, "failed to enrol service", "service", name, "namespace", namespace)
+	}
+	b.Log.Info("service enrolled for scanning", "service", name, "namespace", namespace, "zaproxy", b.AutoEnrolZaProxy)
+	return errors.WrapIff(ErrScanInProgress, "service %s/%s is enrolled", namespace, name)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Explanation is the outcome of a dry-run admission of an object by the webhooks
+type Explanation struct {
+	Allowed bool
+	// Mode is the enforcement mode of the namespace of the object
+	Mode EnforcementMode
+	// Message is the reason of the decision with the exceeded thresholds
+	Message  string
+	Warnings []string
+	// Backends are the backend services of the object, whose scan results were evaluated
+	Backends []map[string]string
+}
+
+// Explain evaluates the object the same way as the validating webhooks do on create, as if it was created by the user.
+// It's a dry-run: events aren't recorded, notifications aren't sent and the services, which would be enrolled, aren't modified.
+func Explain(ctx context.Context, config ValidatorConfig, obj *unstructured.Unstructured, user authenticationv1.UserInfo) *Explanation {
+	config.Recorder = nil
+	config.Notifier = nil
+	b := newBackendChecker(config)
+
+	req := admission.Request{}
+	req.Operation = admissionv1beta1.Create
+	req.UserInfo = user
+	dryRun := true
+	req.DryRun = &dryRun
+	backends, response := b.evaluate(ctx, req, obj)
+
+	explanation := &Explanation{
+		Allowed:  response.Allowed,
+		Mode:     b.enforcementMode(ctx, obj.GetNamespace()),
+		Warnings: response.Warnings,
+		Backends: backends,
+	}
+	if response.Result != nil {
+		explanation.Message = response.Result.Message
+		if explanation.Message == "" {
+			explanation.Message = string(response.Result.Reason)
+		}
+	}
+	return explanation
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := results.NewConfigMapStore(c, c, 10)
+	if err := store.Save(ctx, &results.Scan{
+		ID:             "1",
+		Namespace:      "default",
+		Service:        "app",
+		CompletionTime: &metav1.Time{Time: time.Now()},
+		Alerts:         []results.Alert{{PluginID: "1", Name: "XSS", Risk: results.RiskHigh, URL: "http://app"}},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ingress := &networkingv1.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			DefaultBackend: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{Name: "app", Port: networkingv1.ServiceBackendPort{Number: 80}},
+			},
+		},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ingress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	explanation := Explain(ctx, ValidatorConfig{Client: c, Store: store, Log: zap.New()}, &unstructured.Unstructured{Object: content}, authenticationv1.UserInfo{})
+	if explanation.Allowed {
+		t.Error("ingress above the thresholds is allowed")
+	}
+	if explanation.Mode != EnforcementEnforce {
+		t.Errorf("unexpected enforcement mode %q", explanation.Mode)
+	}
+	if len(explanation.Backends) != 1 || !strings.Contains(explanation.Message, "default/app") {
+		t.Errorf("unexpected explanation: %+v", explanation)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/banzaicloud/dast-operator/pkg/k8sutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/exposure,mutating=false,failurePolicy=fail,groups="networking.istio.io";"route.openshift.io",resources=virtualservices;routes,verbs=create;update,versions=v1alpha3;v1beta1;v1,name=dast-exposure.security.banzaicloud.io
+
+// NewExposureValidator creates a validator for every kind with registered exposure extractor
+func NewExposureValidator(config ValidatorConfig) ExposureValidator {
+	return &exposureValidator{
+		backendChecker: newBackendChecker(config),
+	}
+}
+
+// ExposureValidator implements Handle
+type ExposureValidator interface {
+	Handle(context.Context, admission.Request) admission.Response
+}
+
+type exposureValidator struct {
+	*backendChecker
+	decoder *admission.Decoder
+}
+
+// Handle extracts the backend services of the object and checks their scan results.
+func (a *exposureValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := &unstructured.Unstructured{}
+
+	err := a.decoder.Decode(req, obj)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	_, response := a.evaluate(ctx, req, obj)
+	return response
+}
+
+// evaluate extracts the backend services of the object and checks their scan results, it returns the backend services and the response
+func (b *backendChecker) evaluate(ctx context.Context, req admission.Request, obj *unstructured.Unstructured) ([]map[string]string, admission.Response) {
+	backendServices, err := k8sutil.GetExposedBackendServices(obj, b.Client, b.ResourceBackends, b.Log)
+	if errors.Is(err, k8sutil.ErrResourceBackend) {
+		return nil, b.violation(ctx, req, obj, "backends, which aren't services, can't be scanned", nil)
+	}
+	if err != nil {
+		return nil, admission.Errored(http.StatusBadRequest, err)
+	}
+	b.Log.Info("Services", "kind", obj.GetKind(), "backend_services", backendServices)
+	return backendServices, b.check(ctx, req, obj, backendServices)
+}
+
+// InjectDecoder injects the decoder.
+func (a *exposureValidator) InjectDecoder(d *admission.Decoder) error {
+	a.decoder = d
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	securityv1alpha1 "github.com/banzaicloud/dast-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+const (
+	// overrideReasonAnnotation admits the object despite the scan results, if the user is allowed to override
+	overrideReasonAnnotation = "dast.security.banzaicloud.io/override-reason"
+	// overrideExpiresAnnotation is the RFC3339 time until the override is honoured
+	overrideExpiresAnnotation = "dast.security.banzaicloud.io/override-expires"
+
+	// OverrideVerb is the virtual verb on dasts, which allows users to override denied admissions
+	OverrideVerb = "override"
+)
+
+var admissionOverrides = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "dast_admission_overrides_total",
+	Help: "Number of break-glass overrides of denied admissions",
+}, []string{"kind", "namespace", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(admissionOverrides)
+}
+
+// override admits the denied object if it has an override reason and expiry, and the user is allowed to override in the namespace.
+// The returned message explains rejected overrides.
+func (b *backendChecker) override(ctx context.Context, req admission.Request, obj *unstructured.Unstructured, message string) (admission.Response, bool, string) {
+	annotations := obj.GetAnnotations()
+	reason, ok := annotations[overrideReasonAnnotation]
+	if !ok {
+		return admission.Response{}, false, ""
+	}
+	user := req.UserInfo.Username
+
+	rejected, err := b.overrideRejection(ctx, req, obj, reason)
+	if err != nil {
+		b.Log.Error(err, "failed to check override", "kind", obj.GetKind(), "name", obj.GetName(), "namespace", obj.GetNamespace(), "user", user)
+		rejected = "override can't be authorized"
+	}
+	if rejected != "" {
+		admissionOverrides.WithLabelValues(obj.GetKind(), obj.GetNamespace(), "rejected").Inc()
+		b.record(req, obj, "OverrideRejected", fmt.Sprintf("%s by %s: %s", rejected, user, message))
+		return admission.Response{}, false, rejected
+	}
+
+	expires := annotations[overrideExpiresAnnotation]
+	admissionOverrides.WithLabelValues(obj.GetKind(), obj.GetNamespace(), "allowed").Inc()
+	b.Log.Info("admission overridden", "kind", obj.GetKind(), "name", obj.GetName(), "namespace", obj.GetNamespace(), "user", user, "reason", reason, "expires", expires, "denied", message)
+	b.record(req, obj, "AdmissionOverridden", fmt.Sprintf("overridden by %s until %s: %s, denied: %s", user, expires, reason, message))
+
+	response := admission.Allowed("admission overridden")
+	response.Warnings = []string{fmt.Sprintf("DAST: admission overridden until %s: %s", expires, message)}
+	return response, true, ""
+}
+
+// overrideRejection returns why the override isn't honoured, empty if it's honoured
+func (b *backendChecker) overrideRejection(ctx context.Context, req admission.Request, obj *unstructured.Unstructured, reason string) (string, error) {
+	if reason == "" {
+		return "override reason is empty", nil
+	}
+	expires, ok := obj.GetAnnotations()[overrideExpiresAnnotation]
+	if !ok {
+		return fmt.Sprintf("override requires the %s annotation", overrideExpiresAnnotation), nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return fmt.Sprintf("invalid override expiry %q, must be an RFC3339 time", expires), nil
+	}
+	now := time.Now()
+	if !now.Before(expiresAt) {
+		return fmt.Sprintf("override expired at %s", expires), nil
+	}
+	if b.OverrideMaxDuration > 0 && expiresAt.Sub(now) > b.OverrideMaxDuration {
+		return fmt.Sprintf("override expiry is later than %s", b.OverrideMaxDuration), nil
+	}
+
+	allowed, err := b.authorize(ctx, req, obj.GetNamespace(), OverrideVerb, "dasts")
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return fmt.Sprintf("user %s isn't allowed to override", req.UserInfo.Username), nil
+	}
+	return "", nil
+}
+
+// authorize reports whether the user of the request is allowed to use the verb on the resources of the security group in the namespace
+func (b *backendChecker) authorize(ctx context.Context, req admission.Request, namespace, verb, resource string) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range req.UserInfo.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   req.UserInfo.Username,
+			UID:    req.UserInfo.UID,
+			Groups: req.UserInfo.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     securityv1alpha1.GroupVersion.Group,
+				Resource:  resource,
+			},
+		},
+	}
+	if err := b.Client.Create(ctx, review); err != nil {
+		return false, errors.WrapIf(err, "failed to create subject access review")
+	}
+	return review.Status.Allowed, nil
+}
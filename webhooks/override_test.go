@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// reviewClient allows the subject access reviews of the admins for overrides and baseline acceptance
+type reviewClient struct {
+	client.Client
+}
+
+func (c reviewClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "admin" &&
+			(attributes.Verb == OverrideVerb && attributes.Resource == "dasts" || attributes.Verb == AcceptVerb && attributes.Resource == "dastbaselines")
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestOverride(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	tests := []struct {
+		name        string
+		user        string
+		annotations map[string]string
+		allowed     bool
+		message     string
+	}{
+		{name: "no override", user: "admin", annotations: map[string]string{}, message: "above treshold"},
+		{name: "allowed", user: "admin", annotations: map[string]string{overrideReasonAnnotation: "incident", overrideExpiresAnnotation: future}, allowed: true},
+		{name: "forbidden", user: "developer", annotations: map[string]string{overrideReasonAnnotation: "incident", overrideExpiresAnnotation: future}, message: "isn't allowed to override"},
+		{name: "no expiry", user: "admin", annotations: map[string]string{overrideReasonAnnotation: "incident"}, message: "requires the"},
+		{name: "expired", user: "admin", annotations: map[string]string{overrideReasonAnnotation: "incident", overrideExpiresAnnotation: time.Now().Add(-time.Hour).Format(time.RFC3339)}, message: "expired"},
+		{name: "too long", user: "admin", annotations: map[string]string{overrideReasonAnnotation: "incident", overrideExpiresAnnotation: time.Now().Add(48 * time.Hour).Format(time.RFC3339)}, message: "later than"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := newBackendChecker(ValidatorConfig{Client: reviewClient{newFakeClient(t)}, OverrideMaxDuration: 24 * time.Hour, Log: zap.New()})
+			ingress := newIngress("default")
+			ingress.SetAnnotations(test.annotations)
+			req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{UserInfo: authenticationv1.UserInfo{Username: test.user}}}
+
+			response := checker.violation(context.Background(), req, ingress, "scan results are above treshold", nil)
+			if response.Allowed != test.allowed {
+				t.Fatalf("allowed is %v, expected %v", response.Allowed, test.allowed)
+			}
+			if !test.allowed && !strings.Contains(string(response.Result.Reason), test.message) {
+				t.Errorf("unexpected reason %q", response.Result.Reason)
+			}
+			if test.allowed && len(response.Warnings) != 1 {
+				t.Errorf("unexpected warnings %v", response.Warnings)
+			}
+		})
+	}
+}
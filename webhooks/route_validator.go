@@ -0,0 +1,27 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+// +kubebuilder:webhook:path=/route,mutating=false,failurePolicy=fail,groups="gateway.networking.k8s.io",resources=httproutes;grpcroutes,verbs=create;update,versions=v1;v1beta1;v1alpha2,name=dast-route.security.banzaicloud.io
+
+// NewRouteValidator creates a validator of Gateway API HTTPRoutes and GRPCRoutes
+func NewRouteValidator(config ValidatorConfig) RouteValidator {
+	return NewExposureValidator(config)
+}
+
+// RouteValidator implements Handle
+type RouteValidator = ExposureValidator
@@ -0,0 +1,121 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// NewServiceValidator creates a validator, which checks the latest stored scan of services becoming externally reachable.
+// Every service is allowed if enabled is false.
+func NewServiceValidator(config ValidatorConfig, enabled bool) ServiceValidator {
+	// the admitted service isn't enrolled, patching it would conflict with the admitted change
+	config.AutoEnrolZaProxy = nil
+	return &serviceValidator{
+		backendChecker: newBackendChecker(config),
+		enabled:        enabled,
+	}
+}
+
+// ServiceValidator implements Handle
+type ServiceValidator interface {
+	Handle(context.Context, admission.Request) admission.Response
+}
+
+type serviceValidator struct {
+	*backendChecker
+	enabled bool
+	decoder *admission.Decoder
+}
+
+// Handle denies changing the type of a service to LoadBalancer or NodePort and adding external IPs,
+// unless the service has a recent passing scan below the thresholds.
+// A created service can't have a scan yet, so it's admitted with warnings, it's scanned after the creation if it's enrolled.
+func (a *serviceValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if !a.enabled {
+		return admission.Allowed("service validation is disabled")
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := a.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	service := &corev1.Service{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, service); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	oldService := &corev1.Service{}
+	if req.Operation == admissionv1beta1.Update {
+		if err := a.decoder.DecodeRaw(req.OldObject, oldService); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	reason := exposureChange(oldService, service)
+	if reason == "" {
+		return admission.Allowed("service isn't exposed externally")
+	}
+	a.Log.Info("service exposed externally", "service", service.GetName(), "namespace", service.GetNamespace(), "reason", reason)
+
+	message, details, err := a.checkBackends(ctx, obj, []map[string]string{{"name": service.GetName()}}, dryRun(req))
+	if err != nil {
+		return a.failure(ctx, req, obj, err)
+	}
+	if len(details) > 0 {
+		details = append([]string{message}, details...)
+		if req.Operation == admissionv1beta1.Create {
+			return a.warn(req, obj, reason+" before it's scanned", details)
+		}
+		return a.violation(ctx, req, obj, reason, details)
+	}
+	return a.allow(ctx, obj, message)
+}
+
+// InjectDecoder injects the decoder.
+func (a *serviceValidator) InjectDecoder(d *admission.Decoder) error {
+	a.decoder = d
+	return nil
+}
+
+// exposureChange describes how the service becomes externally reachable, empty if it doesn't
+func exposureChange(oldService, service *corev1.Service) string {
+	if externalType(service.Spec.Type) && !externalType(oldService.Spec.Type) {
+		return fmt.Sprintf("service type %s is externally reachable", service.Spec.Type)
+	}
+	existing := map[string]bool{}
+	for _, ip := range oldService.Spec.ExternalIPs {
+		existing[ip] = true
+	}
+	for _, ip := range service.Spec.ExternalIPs {
+		if !existing[ip] {
+			return fmt.Sprintf("external IP %s is added", ip)
+		}
+	}
+	return ""
+}
+
+func externalType(serviceType corev1.ServiceType) bool {
+	return serviceType == corev1.ServiceTypeLoadBalancer || serviceType == corev1.ServiceTypeNodePort
+}
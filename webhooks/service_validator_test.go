@@ -0,0 +1,107 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/banzaicloud/dast-operator/pkg/results"
+)
+
+func newServiceOfType(name string, serviceType corev1.ServiceType, externalIPs ...string) runtime.RawExtension {
+	service := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Type: serviceType, ExternalIPs: externalIPs},
+	}
+	raw, _ := json.Marshal(service)
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestServiceValidator(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(t)
+	store := results.NewConfigMapStore(c, c, 10)
+	for _, scan := range []*results.Scan{
+		{ID: "1", Namespace: "default", Service: "scanned", CompletionTime: &metav1.Time{Time: time.Now()}, Passed: true},
+		{ID: "2", Namespace: "default", Service: "stale", CompletionTime: &metav1.Time{Time: time.Now().Add(-48 * time.Hour)}, Passed: true},
+		{ID: "3", Namespace: "default", Service: "failed", CompletionTime: &metav1.Time{Time: time.Now()}, Reason: "Timeout"},
+	} {
+		if err := store.Save(ctx, scan, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decoder, err := admission.NewDecoder(clientgoscheme.Scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		operation admissionv1beta1.Operation
+		object    runtime.RawExtension
+		oldObject runtime.RawExtension
+		allowed   bool
+		warned    bool
+	}{
+		{name: "disabled", disabled: true, operation: admissionv1beta1.Update, object: newServiceOfType("unscanned", corev1.ServiceTypeLoadBalancer), oldObject: newServiceOfType("unscanned", corev1.ServiceTypeClusterIP), allowed: true},
+		{name: "cluster ip", operation: admissionv1beta1.Update, object: newServiceOfType("unscanned", corev1.ServiceTypeClusterIP), oldObject: newServiceOfType("unscanned", corev1.ServiceTypeClusterIP), allowed: true},
+		{name: "load balancer without scan", operation: admissionv1beta1.Update, object: newServiceOfType("unscanned", corev1.ServiceTypeLoadBalancer), oldObject: newServiceOfType("unscanned", corev1.ServiceTypeClusterIP)},
+		{name: "node port with scan", operation: admissionv1beta1.Update, object: newServiceOfType("scanned", corev1.ServiceTypeNodePort), oldObject: newServiceOfType("scanned", corev1.ServiceTypeClusterIP), allowed: true},
+		{name: "load balancer with stale scan", operation: admissionv1beta1.Update, object: newServiceOfType("stale", corev1.ServiceTypeLoadBalancer), oldObject: newServiceOfType("stale", corev1.ServiceTypeClusterIP)},
+		{name: "load balancer with failed scan", operation: admissionv1beta1.Update, object: newServiceOfType("failed", corev1.ServiceTypeLoadBalancer), oldObject: newServiceOfType("failed", corev1.ServiceTypeClusterIP)},
+		{name: "load balancer unchanged", operation: admissionv1beta1.Update, object: newServiceOfType("unscanned", corev1.ServiceTypeLoadBalancer), oldObject: newServiceOfType("unscanned", corev1.ServiceTypeLoadBalancer), allowed: true},
+		{name: "external ip added", operation: admissionv1beta1.Update, object: newServiceOfType("unscanned", corev1.ServiceTypeClusterIP, "10.0.0.1", "10.0.0.2"), oldObject: newServiceOfType("unscanned", corev1.ServiceTypeClusterIP, "10.0.0.1")},
+		{name: "created cluster ip", operation: admissionv1beta1.Create, object: newServiceOfType("unscanned", corev1.ServiceTypeClusterIP), allowed: true},
+		{name: "created load balancer", operation: admissionv1beta1.Create, object: newServiceOfType("unscanned", corev1.ServiceTypeLoadBalancer), allowed: true, warned: true},
+		{name: "created load balancer with scan", operation: admissionv1beta1.Create, object: newServiceOfType("scanned", corev1.ServiceTypeLoadBalancer), allowed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validator := NewServiceValidator(ValidatorConfig{Client: c, Store: store, ScanMaxAge: 24 * time.Hour, Log: zap.New()}, !test.disabled)
+			if err := validator.(*serviceValidator).InjectDecoder(decoder); err != nil {
+				t.Fatal(err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: test.operation,
+				Namespace: "default",
+				Object:    test.object,
+				OldObject: test.oldObject,
+			}}
+
+			response := validator.Handle(ctx, req)
+			if response.Allowed != test.allowed {
+				t.Fatalf("allowed is %v, expected %v: %s", response.Allowed, test.allowed, response.Result.Reason)
+			}
+			if warned := len(response.Warnings) > 0; warned != test.warned {
+				t.Errorf("unexpected warnings %v", response.Warnings)
+			}
+		})
+	}
+}